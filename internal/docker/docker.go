@@ -25,6 +25,24 @@ func Cp(src string, dest string) error {
 	return runDocker("cp", src, dest)
 }
 
+// Tag runs docker tag
+func Tag(src string, dest string) error {
+	if len(src) == 0 || len(dest) == 0 {
+		return fmt.Errorf("please provide source and target")
+	}
+
+	return runDocker("tag", src, dest)
+}
+
+// Push runs docker push
+func Push(image string) error {
+	if len(image) == 0 {
+		return fmt.Errorf("please provide image")
+	}
+
+	return runDocker("push", image)
+}
+
 // Exec runs docker exec
 func Exec(container string, command string, options ...string) error {
 	if len(container) == 0 || len(command) == 0 {
@@ -278,3 +278,54 @@ func TestExec(t *testing.T) {
 		})
 	}
 }
+
+func TestTag(t *testing.T) {
+	tests := []struct {
+		description  string
+		source       string
+		target       string
+		options      []string
+		errorMessage string
+	}{
+		{
+			description: "happy path",
+			source:      "provider:latest",
+			target:      "localhost:5000/provider:latest",
+			options:     []string{"provider:latest", "localhost:5000/provider:latest"},
+		},
+		{
+			description:  "returns an error if target is empty",
+			source:       "provider:latest",
+			errorMessage: "please provide source and target",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			runDocker = func(command string, options ...string) error {
+				require.Equal(t, "tag", command)
+				require.Equal(t, test.options, options)
+				return nil
+			}
+
+			err := Tag(test.source, test.target)
+
+			if len(test.errorMessage) == 0 {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, test.errorMessage)
+			}
+		})
+	}
+}
+
+func TestPush(t *testing.T) {
+	runDocker = func(command string, options ...string) error {
+		require.Equal(t, "push", command)
+		require.Equal(t, []string{"localhost:5000/provider:latest"}, options)
+		return nil
+	}
+
+	require.NoError(t, Push("localhost:5000/provider:latest"))
+	require.EqualError(t, Push(""), "please provide image")
+}
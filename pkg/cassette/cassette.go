@@ -0,0 +1,382 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/env"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+
+	"github.com/crossplane-contrib/xp-testing/internal/docker"
+	"github.com/crossplane-contrib/xp-testing/pkg/envvar"
+	"github.com/crossplane-contrib/xp-testing/pkg/provider"
+	resHelper "github.com/crossplane-contrib/xp-testing/pkg/resources"
+	"github.com/crossplane-contrib/xp-testing/pkg/vendored"
+)
+
+// Mode defines whether the proxy records the interactions with the real endpoints or replays them
+type Mode string
+
+const (
+	// Record forwards the requests of the provider to the real endpoints and persists the interactions per feature
+	Record Mode = "record"
+	// Replay answers the requests of the provider from the persisted interactions, unknown requests are rejected
+	Replay Mode = "replay"
+
+	// ModeEnv overwrites Config.Mode, e.g. E2E_CASSETTE_MODE=record
+	ModeEnv = "E2E_CASSETTE_MODE"
+	// DefaultImage is the mitmproxy image used, if Config.Image is not set
+	DefaultImage = "mitmproxy/mitmproxy:10.4.2"
+
+	proxyName   = "xp-testing-cassette-proxy"
+	caName      = "xp-testing-cassette-ca"
+	proxyPort   = 8080
+	caFile      = "ca.crt"
+	caPEMFile   = "mitmproxy-ca.pem"
+	caPath      = "/etc/xp-testing/cassette"
+	nodePath    = "/var/local/xp-testing/cassettes"
+	cassetteEnv = "CASSETTE"
+)
+
+// DefaultNoProxy are the hosts the provider reaches directly, i.e. the cluster internal services and the service and
+// pod CIDRs of kind and k3d. The kubernetes API server is always reached directly, see InjectProxy.
+var DefaultNoProxy = []string{"localhost", "127.0.0.1", ".svc", ".cluster.local", "10.96.0.0/16", "10.244.0.0/16", "10.43.0.0/16", "10.42.0.0/16"}
+
+// apiServerHost is expanded by kubernetes to the address of the API server the provider connects to in-cluster,
+// independent of the service CIDR of the cluster
+const apiServerHost = "$(KUBERNETES_SERVICE_HOST)"
+
+var intstrPort = intstr.FromString("proxy")
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// Config of the cassette mode. The provider is configured to send its requests through an in-cluster mitmproxy,
+// which records them to Dir per feature or replays them from there, so the suite runs hermetically.
+type Config struct {
+	// Dir is the directory the cassettes are persisted in, one <feature>.flow file per feature
+	Dir string
+	// Mode defaults to Replay and can be overwritten by ModeEnv
+	Mode Mode
+	// Image of the proxy, defaults to DefaultImage
+	Image string
+	// NoProxy are additional hosts the provider reaches directly
+	NoProxy []string
+	// ExtraArgs are passed to mitmdump, e.g. "--set", "server_replay_ignore_params=timestamp" to ignore volatile query parameters
+	ExtraArgs []string
+	// Node returns the name of the docker container of the node, which runs the proxy and the cassettes are exchanged
+	// with. ClusterSetup sets it from the cluster backend, see cluster.Node. Backends without docker access to their
+	// nodes are not supported.
+	Node func(clusterName string) string
+}
+
+// CurrentMode returns the mode of the config, considering ModeEnv
+func (c Config) CurrentMode() Mode {
+	mode := c.Mode
+	if mode == "" {
+		mode = Replay
+	}
+	return Mode(envvar.GetOrDefault(ModeEnv, string(mode)))
+}
+
+// ProxyURL is the in-cluster URL of the proxy
+func ProxyURL() string {
+	return fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", proxyName, provider.Namespace, proxyPort)
+}
+
+// InjectProxy returns a copy of the DeploymentRuntimeConfig, which routes the requests of the provider through the proxy
+// and trusts its CA. A new DeploymentRuntimeConfig is created, if drc is nil.
+// The CA replaces the system certificates of the provider, endpoints not in NoProxy are only reachable through the proxy,
+// except the kubernetes API server, whose address is added to NO_PROXY from the KUBERNETES_SERVICE_HOST of the pod.
+func (c Config) InjectProxy(drc *vendored.DeploymentRuntimeConfig) *vendored.DeploymentRuntimeConfig {
+	if drc == nil {
+		drc = &vendored.DeploymentRuntimeConfig{ObjectMeta: metav1.ObjectMeta{Name: "xp-testing-cassettes"}}
+	}
+	drc = drc.DeepCopy()
+	if drc.Spec.DeploymentTemplate == nil {
+		drc.Spec.DeploymentTemplate = &vendored.DeploymentTemplate{}
+	}
+	if drc.Spec.DeploymentTemplate.Spec == nil {
+		drc.Spec.DeploymentTemplate.Spec = &appsv1.DeploymentSpec{}
+	}
+	spec := drc.Spec.DeploymentTemplate.Spec
+	if spec.Selector == nil {
+		spec.Selector = &metav1.LabelSelector{}
+	}
+	podSpec := &spec.Template.Spec
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: caName,
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+			SecretName: caName,
+			Items:      []corev1.KeyToPath{{Key: caFile, Path: caFile}},
+		}},
+	})
+
+	// crossplane merges the container named package-runtime into the provider container
+	index := -1
+	for i := range podSpec.Containers {
+		if podSpec.Containers[i].Name == "package-runtime" {
+			index = i
+		}
+	}
+	if index < 0 {
+		podSpec.Containers = append(podSpec.Containers, corev1.Container{Name: "package-runtime"})
+		index = len(podSpec.Containers) - 1
+	}
+	container := &podSpec.Containers[index]
+	container.Env = append(container.Env,
+		corev1.EnvVar{Name: "HTTPS_PROXY", Value: ProxyURL()},
+		corev1.EnvVar{Name: "HTTP_PROXY", Value: ProxyURL()},
+		corev1.EnvVar{Name: "NO_PROXY", Value: strings.Join(c.noProxy(), ",")},
+		corev1.EnvVar{Name: "SSL_CERT_FILE", Value: filepath.Join(caPath, caFile)},
+	)
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: caName, MountPath: caPath, ReadOnly: true})
+	return drc
+}
+
+// noProxy returns the hosts the provider reaches directly
+func (c Config) noProxy() []string {
+	noProxy := append(append([]string{}, DefaultNoProxy...), apiServerHost)
+	return append(noProxy, c.NoProxy...)
+}
+
+// Setup returns an env.Func, which creates the CA of the proxy and the proxy itself, scaled to zero until a feature starts.
+// It needs to run before the provider is installed, since the provider mounts the CA. An existing CA is kept.
+func (c Config) Setup(clusterName string) env.Func {
+	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+		klog.V(4).Infof("Setup cassette proxy in %s mode", c.CurrentMode())
+		node, err := c.node(clusterName)
+		if err != nil {
+			return ctx, err
+		}
+		if err := docker.Exec(node, "mkdir", "-m", "777", "-p", nodePath); err != nil {
+			return ctx, err
+		}
+		res, err := resources.New(cfg.Client().RESTConfig())
+		if err != nil {
+			return ctx, err
+		}
+		certPEM, keyPEM, err := generateCA()
+		if err != nil {
+			return ctx, err
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: caName, Namespace: provider.Namespace},
+			Data: map[string][]byte{
+				caFile:    certPEM,
+				caPEMFile: append(append([]byte{}, keyPEM...), certPEM...),
+			},
+		}
+		if err := res.Create(ctx, secret); err != nil && !apierrors.IsAlreadyExists(err) {
+			return ctx, err
+		}
+		for _, obj := range c.proxyObjects(node) {
+			if err := resHelper.CreateOrUpdate(ctx, res, obj); err != nil {
+				return ctx, fmt.Errorf("failed to setup cassette proxy: %w", err)
+			}
+		}
+		return ctx, nil
+	}
+}
+
+// Start returns a BeforeEachFeature hook, which starts the proxy for the cassette of the feature.
+// In Replay mode the cassette is loaded from Dir and the feature fails, if it hasn't been recorded yet.
+func (c Config) Start(clusterName string) env.FeatureFunc {
+	return func(ctx context.Context, cfg *envconf.Config, t *testing.T, feature features.Feature) (context.Context, error) {
+		name := cassetteName(feature)
+		if c.CurrentMode() == Replay {
+			file := filepath.Join(c.Dir, name)
+			if _, err := os.Stat(file); err != nil {
+				return ctx, fmt.Errorf("no cassette for feature %s, record it with %s=%s: %w", feature.Name(), ModeEnv, Record, err)
+			}
+			node, err := c.node(clusterName)
+			if err != nil {
+				return ctx, err
+			}
+			if err := docker.Cp(file, fmt.Sprintf("%s:%s", node, filepath.Join(nodePath, name))); err != nil {
+				return ctx, err
+			}
+		}
+		return ctx, c.scaleProxy(ctx, cfg, name, 1)
+	}
+}
+
+// Stop returns an AfterEachFeature hook, which stops the proxy and, in Record mode, persists the cassette of the feature to Dir
+func (c Config) Stop(clusterName string) env.FeatureFunc {
+	return func(ctx context.Context, cfg *envconf.Config, t *testing.T, feature features.Feature) (context.Context, error) {
+		name := cassetteName(feature)
+		// stopping the proxy flushes the recorded interactions
+		if err := c.scaleProxy(ctx, cfg, name, 0); err != nil {
+			return ctx, err
+		}
+		if c.CurrentMode() != Record {
+			return ctx, nil
+		}
+		if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+			return ctx, err
+		}
+		node, err := c.node(clusterName)
+		if err != nil {
+			return ctx, err
+		}
+		file := filepath.Join(c.Dir, name)
+		if err := docker.Cp(fmt.Sprintf("%s:%s", node, filepath.Join(nodePath, name)), file); err != nil {
+			return ctx, err
+		}
+		t.Logf("Recorded cassette of feature %s: %s", feature.Name(), file)
+		return ctx, nil
+	}
+}
+
+// scaleProxy sets the cassette of the proxy and waits until the given number of proxy pods is running
+func (c Config) scaleProxy(ctx context.Context, cfg *envconf.Config, cassette string, replicas int32) error {
+	res, err := resources.New(cfg.Client().RESTConfig())
+	if err != nil {
+		return err
+	}
+	deployment := &appsv1.Deployment{}
+	if err := res.Get(ctx, proxyName, provider.Namespace, deployment); err != nil {
+		return err
+	}
+	deployment.Spec.Replicas = ptr.To(replicas)
+	deployment.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: cassetteEnv, Value: cassette}}
+	if err := res.Update(ctx, deployment); err != nil {
+		return err
+	}
+	err = wait.For(func(ctx context.Context) (bool, error) {
+		pods := &corev1.PodList{}
+		err := res.WithNamespace(provider.Namespace).List(ctx, pods, resources.WithLabelSelector("app.kubernetes.io/name="+proxyName))
+		if err != nil {
+			return false, err
+		}
+		ready := 0
+		for _, pod := range pods.Items {
+			if pod.DeletionTimestamp != nil {
+				return false, nil
+			}
+			for _, condition := range pod.Status.Conditions {
+				if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue && podCassette(pod) == cassette {
+					ready++
+				}
+			}
+		}
+		return len(pods.Items) == int(replicas) && ready == int(replicas), nil
+	}, wait.WithContext(ctx), wait.WithTimeout(2*time.Minute))
+	if err != nil {
+		return fmt.Errorf("cassette proxy did not scale to %d for %s: %w", replicas, cassette, err)
+	}
+	return nil
+}
+
+func podCassette(pod corev1.Pod) string {
+	for _, container := range pod.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == cassetteEnv {
+				return env.Value
+			}
+		}
+	}
+	return ""
+}
+
+// args returns the mitmdump command line of the mode
+func (c Config) args() []string {
+	args := []string{"mitmdump", "--listen-port", fmt.Sprint(proxyPort), "--set", "confdir=/tmp/ca"}
+	file := filepath.Join(nodePath, "$"+cassetteEnv)
+	if c.CurrentMode() == Record {
+		args = append(args, "--save-stream-file", file)
+	} else {
+		args = append(args, "--server-replay", file,
+			"--set", "server_replay_extra=kill",
+			"--set", "server_replay_reuse=true")
+	}
+	return append(args, c.ExtraArgs...)
+}
+
+// proxyObjects returns the deployment and service of the proxy, the deployment runs on the given node,
+// where the cassettes are exchanged with the host
+func (c Config) proxyObjects(node string) []k8s.Object {
+	labels := map[string]string{"app.kubernetes.io/name": proxyName, "app.kubernetes.io/part-of": "xp-testing"}
+	// mitmproxy writes additional files to its confdir, which can't be the read only secret mount
+	command := "mkdir -p /tmp/ca && cp " + filepath.Join(caPath, caPEMFile) + " /tmp/ca/ && exec " + strings.Join(c.args(), " ")
+	image := c.Image
+	if image == "" {
+		image = DefaultImage
+	}
+	return []k8s.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: proxyName, Namespace: provider.Namespace, Labels: labels},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: ptr.To[int32](0),
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Strategy: appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec: corev1.PodSpec{
+						NodeSelector: map[string]string{"kubernetes.io/hostname": node},
+						Tolerations: []corev1.Toleration{{
+							Key:      "node-role.kubernetes.io/control-plane",
+							Operator: corev1.TolerationOpExists,
+							Effect:   corev1.TaintEffectNoSchedule,
+						}},
+						Containers: []corev1.Container{{
+							Name:    "proxy",
+							Image:   image,
+							Command: []string{"sh", "-c", command},
+							Env:     []corev1.EnvVar{{Name: cassetteEnv}},
+							Ports:   []corev1.ContainerPort{{Name: "proxy", ContainerPort: proxyPort}},
+							ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+								TCPSocket: &corev1.TCPSocketAction{Port: intstrPort},
+							}},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "ca", MountPath: caPath, ReadOnly: true},
+								{Name: "cassettes", MountPath: nodePath},
+							},
+						}},
+						Volumes: []corev1.Volume{
+							{Name: "ca", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: caName}}},
+							{Name: "cassettes", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: nodePath}}},
+						},
+					},
+				},
+			},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: proxyName, Namespace: provider.Namespace, Labels: labels},
+			Spec: corev1.ServiceSpec{
+				Selector: labels,
+				Ports:    []corev1.ServicePort{{Name: "proxy", Port: proxyPort, TargetPort: intstrPort}},
+			},
+		},
+	}
+}
+
+// cassetteName returns the file name of the cassette of the feature
+func cassetteName(feature features.Feature) string {
+	return unsafeFileChars.ReplaceAllString(feature.Name(), "_") + ".flow"
+}
+
+// node returns the name of the docker container of the node the cassettes are exchanged with
+func (c Config) node(clusterName string) (string, error) {
+	if c.Node == nil {
+		return "", errors.New("cassettes require docker access to a node of the cluster, which the cluster backend doesn't provide")
+	}
+	return c.Node(clusterName), nil
+}
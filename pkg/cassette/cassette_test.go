@@ -0,0 +1,84 @@
+package cassette
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/vendored"
+)
+
+func Test_generateCA(t *testing.T) {
+	certPEM, keyPEM, err := generateCA()
+	require.NoError(t, err)
+	_, err = tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	require.True(t, cert.IsCA)
+}
+
+func TestConfig_CurrentMode(t *testing.T) {
+	require.Equal(t, Replay, Config{}.CurrentMode())
+	require.Equal(t, Record, Config{Mode: Record}.CurrentMode())
+	t.Setenv(ModeEnv, string(Replay))
+	require.Equal(t, Replay, Config{Mode: Record}.CurrentMode())
+}
+
+func TestConfig_InjectProxy(t *testing.T) {
+	existing := &vendored.DeploymentRuntimeConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "custom"},
+		Spec: vendored.DeploymentRuntimeConfigSpec{DeploymentTemplate: &vendored.DeploymentTemplate{Spec: &appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "package-runtime",
+				Args: []string{"--debug"},
+			}}}},
+		}}},
+	}
+	drc := Config{NoProxy: []string{"example.internal"}}.InjectProxy(existing)
+
+	require.Equal(t, "custom", drc.Name)
+	require.Empty(t, existing.Spec.DeploymentTemplate.Spec.Template.Spec.Volumes, "the given config must not be modified")
+	podSpec := drc.Spec.DeploymentTemplate.Spec.Template.Spec
+	require.Len(t, podSpec.Containers, 1)
+	container := podSpec.Containers[0]
+	require.Equal(t, []string{"--debug"}, container.Args)
+	require.Contains(t, container.Env, corev1.EnvVar{Name: "HTTPS_PROXY", Value: "http://xp-testing-cassette-proxy.crossplane-system.svc.cluster.local:8080"})
+	require.Contains(t, container.Env, corev1.EnvVar{Name: "SSL_CERT_FILE", Value: "/etc/xp-testing/cassette/ca.crt"})
+	require.Contains(t, container.Env, corev1.EnvVar{Name: "NO_PROXY", Value: "localhost,127.0.0.1,.svc,.cluster.local,10.96.0.0/16,10.244.0.0/16,10.43.0.0/16,10.42.0.0/16,$(KUBERNETES_SERVICE_HOST),example.internal"})
+	require.Equal(t, caName, podSpec.Volumes[0].Secret.SecretName)
+
+	created := Config{}.InjectProxy(nil)
+	require.Equal(t, "xp-testing-cassettes", created.Name)
+	require.NotNil(t, created.Spec.DeploymentTemplate.Spec.Selector)
+	require.Equal(t, "package-runtime", created.Spec.DeploymentTemplate.Spec.Template.Spec.Containers[0].Name)
+}
+
+func TestConfig_args(t *testing.T) {
+	record := strings.Join(Config{Mode: Record}.args(), " ")
+	require.Contains(t, record, "--save-stream-file /var/local/xp-testing/cassettes/$CASSETTE")
+
+	replay := strings.Join(Config{ExtraArgs: []string{"--set", "server_replay_ignore_params=ts"}}.args(), " ")
+	require.Contains(t, replay, "--server-replay /var/local/xp-testing/cassettes/$CASSETTE")
+	require.Contains(t, replay, "server_replay_extra=kill")
+	require.True(t, strings.HasSuffix(replay, "--set server_replay_ignore_params=ts"))
+}
+
+func TestConfig_node(t *testing.T) {
+	_, err := Config{}.node("test")
+	require.ErrorContains(t, err, "cassettes require docker access to a node of the cluster")
+
+	node, err := Config{Node: func(clusterName string) string { return "k3d-" + clusterName + "-server-0" }}.node("test")
+	require.NoError(t, err)
+	require.Equal(t, "k3d-test-server-0", node)
+	deployment := Config{}.proxyObjects(node)[0].(*appsv1.Deployment)
+	require.Equal(t, map[string]string{"kubernetes.io/hostname": "k3d-test-server-0"}, deployment.Spec.Template.Spec.NodeSelector)
+}
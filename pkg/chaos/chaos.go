@@ -0,0 +1,106 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/resources"
+)
+
+const (
+	defaultRecoveryTimeout = 5 * time.Minute
+	observeInterval        = 2 * time.Second
+)
+
+// Fault is a failure injected into the test environment, e.g. blocking the network access of a provider
+type Fault interface {
+	// Name describes the fault for the test output
+	Name() string
+	// Inject starts the fault
+	Inject(ctx context.Context, cfg *envconf.Config) error
+	// Lift stops the fault, it must succeed even if Inject failed
+	Lift(ctx context.Context, cfg *envconf.Config) error
+}
+
+// Inject returns a step which injects the fault, e.g. as setup of a feature, which is lifted again via Lift
+func Inject(fault Fault) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		t.Logf("Inject fault: %s", fault.Name())
+		if err := fault.Inject(ctx, cfg); err != nil {
+			t.Fatalf("failed to inject fault %s: %v", fault.Name(), err)
+		}
+		return ctx
+	}
+}
+
+// Lift returns a step which lifts the fault, e.g. as teardown of a feature
+func Lift(fault Fault) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		t.Logf("Lift fault: %s", fault.Name())
+		if err := fault.Lift(ctx, cfg); err != nil {
+			t.Errorf("failed to lift fault %s: %v", fault.Name(), err)
+		}
+		return ctx
+	}
+}
+
+// RecoveryTest configures a fault injection test, see AssessRecovery
+type RecoveryTest struct {
+	Fault Fault
+	// Duration is the time the fault is active. At least one resource must report an error within it, so it must
+	// exceed the poll interval of the provider.
+	Duration time.Duration
+	// Timeout for the resources to become synced and ready after the fault is lifted, defaults to 5 minutes
+	Timeout time.Duration
+}
+
+// AssessRecovery returns a step which injects the fault for the configured duration, asserts that the fault is
+// observed, i.e. at least one resource of the resource test config is Synced=False or gets a warning event, lifts it
+// and asserts that the resources recover, i.e. become synced and ready again within the timeout.
+// It is meant to run after AssessCreate, e.g. as additional step of the resource test config.
+func AssessRecovery(r *resources.ResourceTestConfig, rt RecoveryTest) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		timeout := rt.Timeout
+		if timeout == 0 {
+			timeout = defaultRecoveryTimeout
+		}
+		lifted := false
+		defer func() {
+			// lift the fault, even if the step failed
+			if !lifted {
+				if err := rt.Fault.Lift(ctx, cfg); err != nil {
+					klog.Errorf("failed to lift fault %s: %v", rt.Fault.Name(), err)
+				}
+			}
+		}()
+		injected := time.Now()
+		ctx = Inject(rt.Fault)(ctx, t, cfg)
+		err := resources.WaitForResourcesToReportErrors(ctx, cfg, r.ResourceDirectory, r.ObjFilterFunc, injected,
+			wait.WithTimeout(rt.Duration), wait.WithInterval(observeInterval))
+		if err != nil {
+			t.Fatalf("fault %s was not observed by any resource within %s: %v", rt.Fault.Name(), rt.Duration, err)
+		}
+		t.Logf("Fault %s observed after %s", rt.Fault.Name(), time.Since(injected))
+		select {
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		case <-time.After(time.Until(injected.Add(rt.Duration))):
+		}
+		lifted = true
+		ctx = Lift(rt.Fault)(ctx, t, cfg)
+
+		start := time.Now()
+		if err := resources.WaitForResourcesToBeSynced(ctx, cfg, r.ResourceDirectory, r.ObjFilterFunc, wait.WithTimeout(timeout)); err != nil {
+			resources.DumpManagedResources(ctx, t, cfg)
+			t.Fatalf("resources did not recover from fault %s: %v", rt.Fault.Name(), err)
+		}
+		t.Logf("Resources recovered from fault %s after %s", rt.Fault.Name(), time.Since(start))
+		return ctx
+	}
+}
@@ -0,0 +1,54 @@
+package chaos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	flowcontrolv1 "k8s.io/api/flowcontrol/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestEgressBlock_networkPolicy(t *testing.T) {
+	apiServer := []discoveryv1.EndpointSlice{{
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"172.18.0.2"}}},
+		Ports:       []discoveryv1.EndpointPort{{Protocol: ptr.To(corev1.ProtocolTCP), Port: ptr.To[int32](6443)}},
+	}}
+	policy := BlockEgress("provider-nop").networkPolicy("provider-nop-abc", apiServer)
+	require.Equal(t, "xp-testing-chaos-provider-nop", policy.Name)
+	require.Equal(t, "crossplane-system", policy.Namespace)
+	require.Equal(t, map[string]string{"pkg.crossplane.io/revision": "provider-nop-abc"}, policy.Spec.PodSelector.MatchLabels)
+	require.Equal(t, []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}, policy.Spec.PolicyTypes)
+	require.Len(t, policy.Spec.Egress, 2, "only the cluster DNS and the API server are allowed")
+
+	dns := policy.Spec.Egress[0]
+	require.Equal(t, map[string]string{"kubernetes.io/metadata.name": "kube-system"}, dns.To[0].NamespaceSelector.MatchLabels)
+	require.Equal(t, map[string]string{"k8s-app": "kube-dns"}, dns.To[0].PodSelector.MatchLabels)
+	require.Len(t, dns.Ports, 2)
+	require.Equal(t, 53, dns.Ports[0].Port.IntValue())
+
+	api := policy.Spec.Egress[1]
+	require.Equal(t, "172.18.0.2/32", api.To[0].IPBlock.CIDR)
+	require.Equal(t, corev1.ProtocolTCP, *api.Ports[0].Protocol)
+	require.Equal(t, 6443, api.Ports[0].Port.IntValue())
+
+	policy = BlockEgress("provider-nop", "10.0.0.0/8").networkPolicy("provider-nop-abc", apiServer)
+	require.Len(t, policy.Spec.Egress, 3)
+	require.Equal(t, "10.0.0.0/8", policy.Spec.Egress[2].To[0].IPBlock.CIDR)
+
+	require.Equal(t, "fd00::1/128", hostCIDR(discoveryv1.AddressTypeIPv6, "fd00::1"))
+}
+
+func TestAPIThrottle_objects(t *testing.T) {
+	priorityLevel, flowSchema := ThrottleAPI("provider-nop").objects("provider-nop-abc")
+	require.Equal(t, "xp-testing-chaos-provider-nop", priorityLevel.Name)
+	require.Equal(t, int32(1), *priorityLevel.Spec.Limited.NominalConcurrencyShares)
+	require.Equal(t, flowcontrolv1.LimitResponseTypeReject, priorityLevel.Spec.Limited.LimitResponse.Type)
+
+	require.Equal(t, priorityLevel.Name, flowSchema.Spec.PriorityLevelConfiguration.Name)
+	require.Equal(t, &flowcontrolv1.ServiceAccountSubject{Namespace: "crossplane-system", Name: "provider-nop-abc"},
+		flowSchema.Spec.Rules[0].Subjects[0].ServiceAccount)
+}
@@ -0,0 +1,144 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/provider"
+)
+
+const faultPrefix = "xp-testing-chaos-"
+
+// EgressBlock blocks the egress traffic of the provider pods via a NetworkPolicy.
+// The kubernetes API server and the cluster DNS stay reachable, so the provider can still report the failing
+// requests to the external API on its resources.
+// The cluster needs a CNI that enforces network policies, e.g. kindnet of kind v0.24 or newer, or calico.
+type EgressBlock struct {
+	ProviderName string
+	// AllowCIDRs are further destinations which are still reachable
+	AllowCIDRs []string
+}
+
+// BlockEgress returns a fault blocking the egress traffic of the provider pods except to the kubernetes API server,
+// the cluster DNS and the allowed CIDRs
+func BlockEgress(providerName string, allowCIDRs ...string) *EgressBlock {
+	return &EgressBlock{ProviderName: providerName, AllowCIDRs: allowCIDRs}
+}
+
+// Name implements Fault
+func (f *EgressBlock) Name() string {
+	return fmt.Sprintf("block egress of provider %s", f.ProviderName)
+}
+
+// Inject implements Fault
+func (f *EgressBlock) Inject(ctx context.Context, cfg *envconf.Config) error {
+	res := cfg.Client().Resources()
+	revision, err := provider.CurrentRevision(ctx, res, f.ProviderName)
+	if err != nil {
+		return err
+	}
+	apiServer, err := apiServerEndpoints(ctx, cfg.Client().Resources(metav1.NamespaceDefault))
+	if err != nil {
+		return err
+	}
+	return res.Create(ctx, f.networkPolicy(revision, apiServer))
+}
+
+// apiServerEndpoints returns the endpoints of the kubernetes service in the default namespace of res. Network policies
+// apply after the service address is translated, so the API server needs to be allowed by the addresses of its endpoints.
+func apiServerEndpoints(ctx context.Context, res *resources.Resources) ([]discoveryv1.EndpointSlice, error) {
+	slices := &discoveryv1.EndpointSliceList{}
+	err := res.List(ctx, slices,
+		resources.WithLabelSelector(discoveryv1.LabelServiceName+"=kubernetes"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the endpoints of the kubernetes API server: %w", err)
+	}
+	if len(slices.Items) == 0 {
+		return nil, errors.New("no endpoints of the kubernetes API server found")
+	}
+	return slices.Items, nil
+}
+
+// Lift implements Fault
+func (f *EgressBlock) Lift(ctx context.Context, cfg *envconf.Config) error {
+	err := cfg.Client().Resources().Delete(ctx, f.networkPolicy("", nil))
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (f *EgressBlock) networkPolicy(revision string, apiServer []discoveryv1.EndpointSlice) *networkingv1.NetworkPolicy {
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: faultPrefix + f.ProviderName, Namespace: provider.Namespace},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{provider.RevisionLabel: revision}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      []networkingv1.NetworkPolicyEgressRule{clusterDNSRule()},
+		},
+	}
+	for _, slice := range apiServer {
+		rule := networkingv1.NetworkPolicyEgressRule{}
+		for _, endpoint := range slice.Endpoints {
+			for _, address := range endpoint.Addresses {
+				rule.To = append(rule.To, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: hostCIDR(slice.AddressType, address)}})
+			}
+		}
+		for _, port := range slice.Ports {
+			rule.Ports = append(rule.Ports, networkingv1.NetworkPolicyPort{Protocol: port.Protocol, Port: portOf(port.Port)})
+		}
+		if len(rule.To) > 0 {
+			policy.Spec.Egress = append(policy.Spec.Egress, rule)
+		}
+	}
+	if len(f.AllowCIDRs) > 0 {
+		rule := networkingv1.NetworkPolicyEgressRule{}
+		for _, cidr := range f.AllowCIDRs {
+			rule.To = append(rule.To, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+		}
+		policy.Spec.Egress = append(policy.Spec.Egress, rule)
+	}
+	return policy
+}
+
+// clusterDNSRule allows DNS requests to the cluster DNS
+func clusterDNSRule() networkingv1.NetworkPolicyEgressRule {
+	dnsPort := intstr.FromInt32(53)
+	return networkingv1.NetworkPolicyEgressRule{
+		To: []networkingv1.NetworkPolicyPeer{{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{corev1.LabelMetadataName: metav1.NamespaceSystem}},
+			PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "kube-dns"}},
+		}},
+		Ports: []networkingv1.NetworkPolicyPort{
+			{Protocol: ptr.To(corev1.ProtocolUDP), Port: &dnsPort},
+			{Protocol: ptr.To(corev1.ProtocolTCP), Port: &dnsPort},
+		},
+	}
+}
+
+// hostCIDR returns the CIDR of the single address
+func hostCIDR(addressType discoveryv1.AddressType, address string) string {
+	if addressType == discoveryv1.AddressTypeIPv6 {
+		return address + "/128"
+	}
+	return address + "/32"
+}
+
+func portOf(port *int32) *intstr.IntOrString {
+	if port == nil {
+		return nil
+	}
+	p := intstr.FromInt32(*port)
+	return &p
+}
@@ -0,0 +1,113 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	flowcontrolv1 "k8s.io/api/flowcontrol/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/provider"
+)
+
+// matchingPrecedence of the flow schema, it has to take precedence over the default schemas, which start at 250
+const matchingPrecedence = 100
+
+// APIThrottle throttles the access of the provider to the kubernetes API via API priority and fairness.
+// All requests of the provider's service account are assigned to a priority level with minimal concurrency,
+// requests exceeding it are rejected with 429 Too Many Requests.
+type APIThrottle struct {
+	ProviderName string
+	// ConcurrencyShares is the nominal concurrency shares of the priority level, defaults to 1
+	ConcurrencyShares int32
+}
+
+// ThrottleAPI returns a fault throttling the kubernetes API access of the provider
+func ThrottleAPI(providerName string) *APIThrottle {
+	return &APIThrottle{ProviderName: providerName}
+}
+
+// Name implements Fault
+func (f *APIThrottle) Name() string {
+	return fmt.Sprintf("throttle kubernetes API access of provider %s", f.ProviderName)
+}
+
+// Inject implements Fault
+func (f *APIThrottle) Inject(ctx context.Context, cfg *envconf.Config) error {
+	res := cfg.Client().Resources()
+	pods, err := provider.Pods(ctx, res, f.ProviderName)
+	if err != nil {
+		return err
+	}
+	if len(pods) == 0 {
+		return fmt.Errorf("provider %s has no pods", f.ProviderName)
+	}
+	priorityLevel, flowSchema := f.objects(pods[0].Spec.ServiceAccountName)
+	if err := res.Create(ctx, priorityLevel); err != nil {
+		return err
+	}
+	return res.Create(ctx, flowSchema)
+}
+
+// Lift implements Fault
+func (f *APIThrottle) Lift(ctx context.Context, cfg *envconf.Config) error {
+	res := cfg.Client().Resources()
+	priorityLevel, flowSchema := f.objects("")
+	var errs []error
+	for _, obj := range []k8s.Object{flowSchema, priorityLevel} {
+		if err := res.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f *APIThrottle) objects(serviceAccount string) (*flowcontrolv1.PriorityLevelConfiguration, *flowcontrolv1.FlowSchema) {
+	name := faultPrefix + f.ProviderName
+	shares := f.ConcurrencyShares
+	if shares == 0 {
+		shares = 1
+	}
+	priorityLevel := &flowcontrolv1.PriorityLevelConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: flowcontrolv1.PriorityLevelConfigurationSpec{
+			Type: flowcontrolv1.PriorityLevelEnablementLimited,
+			Limited: &flowcontrolv1.LimitedPriorityLevelConfiguration{
+				NominalConcurrencyShares: ptr.To(shares),
+				LendablePercent:          ptr.To[int32](0),
+				BorrowingLimitPercent:    ptr.To[int32](0),
+				LimitResponse:            flowcontrolv1.LimitResponse{Type: flowcontrolv1.LimitResponseTypeReject},
+			},
+		},
+	}
+	flowSchema := &flowcontrolv1.FlowSchema{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: flowcontrolv1.FlowSchemaSpec{
+			PriorityLevelConfiguration: flowcontrolv1.PriorityLevelConfigurationReference{Name: name},
+			MatchingPrecedence:         matchingPrecedence,
+			Rules: []flowcontrolv1.PolicyRulesWithSubjects{{
+				Subjects: []flowcontrolv1.Subject{{
+					Kind:           flowcontrolv1.SubjectKindServiceAccount,
+					ServiceAccount: &flowcontrolv1.ServiceAccountSubject{Namespace: provider.Namespace, Name: serviceAccount},
+				}},
+				ResourceRules: []flowcontrolv1.ResourcePolicyRule{{
+					Verbs:        []string{flowcontrolv1.VerbAll},
+					APIGroups:    []string{flowcontrolv1.APIGroupAll},
+					Resources:    []string{flowcontrolv1.ResourceAll},
+					ClusterScope: true,
+					Namespaces:   []string{flowcontrolv1.NamespaceEvery},
+				}},
+				NonResourceRules: []flowcontrolv1.NonResourcePolicyRule{{
+					Verbs:           []string{flowcontrolv1.VerbAll},
+					NonResourceURLs: []string{flowcontrolv1.NonResourceAll},
+				}},
+			}},
+		},
+	}
+	return priorityLevel, flowSchema
+}
@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/vladimirvivien/gexe"
+	"sigs.k8s.io/e2e-framework/support"
+	"sigs.k8s.io/e2e-framework/support/k3d"
+	"sigs.k8s.io/e2e-framework/support/kind"
+	"sigs.k8s.io/e2e-framework/third_party/vcluster"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/xpenvfuncs"
+)
+
+// Exists returns if the cluster with the given name exists already and can be reused.
+// Existing clusters from a kubeconfig always exist, unknown backends never.
+func Exists(provider support.E2EClusterProvider, name string) bool {
+	switch provider.(type) {
+	case *kind.Cluster:
+		return containsLine(gexe.Run("kind get clusters"), name)
+	case *k3d.Cluster:
+		return gexe.RunProc(fmt.Sprintf("k3d cluster get %s --no-headers", name)).ExitCode() == 0
+	case *vcluster.Cluster:
+		return vclusterExists(gexe.Run("vcluster list --output json"), name)
+	case *Kubeconfig:
+		return true
+	default:
+		return false
+	}
+}
+
+// Owned returns if the clusters of the backend are created and destroyed by the tests.
+// Existing clusters from a kubeconfig are kept, so the tests need to clean up after themselves.
+func Owned(provider support.E2EClusterProvider) bool {
+	_, existing := provider.(*Kubeconfig)
+	return !existing
+}
+
+// DefaultPackageLoader returns the strategy to load packages into clusters of the backend:
+// kind and k3d packages are copied into the node, otherwise they are expected to be pullable from a registry
+func DefaultPackageLoader(provider support.E2EClusterProvider) xpenvfuncs.PackageLoader {
+	switch provider.(type) {
+	case *kind.Cluster:
+		return xpenvfuncs.KindPackageLoader()
+	case *k3d.Cluster:
+		return xpenvfuncs.K3dPackageLoader()
+	default:
+		return xpenvfuncs.RegistryPackageLoader("")
+	}
+}
+
+// Node returns the func resolving the name of the docker container of the node hosting the package cache of the
+// backend's clusters, the same node DefaultPackageLoader copies packages to. It returns false for backends without
+// docker access to their nodes.
+func Node(provider support.E2EClusterProvider) (func(clusterName string) string, bool) {
+	loader, ok := DefaultPackageLoader(provider).(xpenvfuncs.NodeCopyLoader)
+	if !ok {
+		return nil, false
+	}
+	return loader.Node, true
+}
+
+func containsLine(output string, line string) bool {
+	for _, l := range strings.Split(output, "\n") {
+		if strings.TrimSpace(l) == line {
+			return true
+		}
+	}
+	return false
+}
+
+func vclusterExists(output string, name string) bool {
+	var clusters []struct {
+		Name string `json:"Name"`
+	}
+	if err := json.Unmarshal([]byte(output), &clusters); err != nil {
+		return false
+	}
+	for _, c := range clusters {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
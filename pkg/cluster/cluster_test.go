@@ -0,0 +1,95 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/e2e-framework/support/k3d"
+	"sigs.k8s.io/e2e-framework/support/kind"
+	"sigs.k8s.io/e2e-framework/third_party/vcluster"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/xpenvfuncs"
+)
+
+const kubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com
+- name: prod
+  cluster:
+    server: https://prod.example.com
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: admin
+- name: prod
+  context:
+    cluster: prod
+    user: admin
+current-context: prod
+users:
+- name: admin
+  user:
+    token: secret
+`
+
+func TestDefaultPackageLoader(t *testing.T) {
+	require.IsType(t, xpenvfuncs.NodeCopyLoader{}, DefaultPackageLoader(&kind.Cluster{}))
+	require.Equal(t, "k3d-test-server-0", DefaultPackageLoader(&k3d.Cluster{}).(xpenvfuncs.NodeCopyLoader).Node("test"))
+	require.Equal(t, xpenvfuncs.RegistryPackageLoader(""), DefaultPackageLoader(&vcluster.Cluster{}))
+	require.Equal(t, xpenvfuncs.RegistryPackageLoader(""), DefaultPackageLoader(NewKubeconfig("", "")))
+}
+
+func TestNode(t *testing.T) {
+	node, ok := Node(&kind.Cluster{})
+	require.True(t, ok)
+	require.Equal(t, "test-control-plane", node("test"))
+	node, ok = Node(&k3d.Cluster{})
+	require.True(t, ok)
+	require.Equal(t, "k3d-test-server-0", node("test"))
+	_, ok = Node(NewKubeconfig("", ""))
+	require.False(t, ok)
+}
+
+func TestOwned(t *testing.T) {
+	require.True(t, Owned(&kind.Cluster{}))
+	require.True(t, Owned(&vcluster.Cluster{}))
+	require.False(t, Owned(NewKubeconfig("", "")))
+}
+
+func TestExists(t *testing.T) {
+	require.True(t, Exists(NewKubeconfig("", ""), "any"))
+}
+
+func Test_vclusterExists(t *testing.T) {
+	output := `[{"Name":"e2e","Namespace":"vcluster-e2e","Status":"Running"}]`
+	require.True(t, vclusterExists(output, "e2e"))
+	require.False(t, vclusterExists(output, "other"))
+	require.False(t, vclusterExists("vcluster: command not found", "e2e"))
+}
+
+func TestKubeconfig_Create(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(kubeconfig), 0o600))
+
+	k := NewKubeconfig(path, "dev")
+	file, err := k.WithName("test").Create(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, file, k.GetKubeconfig())
+	require.Equal(t, "https://dev.example.com", k.KubernetesRestConfig().Host)
+	require.NoError(t, k.Destroy(context.Background()))
+
+	k = NewKubeconfig(path, "")
+	_, err = k.Create(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "https://prod.example.com", k.KubernetesRestConfig().Host)
+
+	_, err = NewKubeconfig(path, "unknown").Create(context.Background())
+	require.EqualError(t, err, "kubeconfig has no context unknown")
+}
@@ -0,0 +1,125 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	kindAPIVersion = "kind.x-k8s.io/v1alpha4"
+	kindNodeImage  = "kindest/node"
+
+	// KindControlPlane is the role of control plane nodes of a kind cluster
+	KindControlPlane = "control-plane"
+	// KindWorker is the role of worker nodes of a kind cluster
+	KindWorker = "worker"
+)
+
+// KindConfig is the configuration a kind cluster is created with, a subset of kind.x-k8s.io/v1alpha4,
+// see https://kind.sigs.k8s.io/docs/user/configuration/. Use a configuration file for the remaining fields.
+type KindConfig struct {
+	// Nodes of the cluster, defaults to a single control plane node
+	Nodes []KindNode `json:"nodes,omitempty"`
+	// FeatureGates enabled or disabled on all Kubernetes components
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+	// RuntimeConfig enables or disables API versions of the API server, e.g. "api/alpha": "false"
+	RuntimeConfig map[string]string `json:"runtimeConfig,omitempty"`
+	// ContainerdConfigPatches are merged into the containerd configuration of all nodes
+	ContainerdConfigPatches []string `json:"containerdConfigPatches,omitempty"`
+}
+
+// KindNode is a node of a kind cluster
+type KindNode struct {
+	// Role is KindControlPlane or KindWorker
+	Role string `json:"role"`
+	// Image is the node image, which determines the Kubernetes version, see KindNodeImage
+	Image             string            `json:"image,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	ExtraMounts       []KindMount       `json:"extraMounts,omitempty"`
+	ExtraPortMappings []KindPortMapping `json:"extraPortMappings,omitempty"`
+}
+
+// KindMount mounts a directory of the host into a node
+type KindMount struct {
+	HostPath      string `json:"hostPath"`
+	ContainerPath string `json:"containerPath"`
+	ReadOnly      bool   `json:"readOnly,omitempty"`
+}
+
+// KindPortMapping maps a port of the host to a node
+type KindPortMapping struct {
+	ContainerPort int32  `json:"containerPort"`
+	HostPort      int32  `json:"hostPort,omitempty"`
+	ListenAddress string `json:"listenAddress,omitempty"`
+	// Protocol is TCP (default), UDP or SCTP
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// Marshal returns the configuration as kind configuration file
+func (c KindConfig) Marshal() ([]byte, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return PatchKindConfig(data, "")
+}
+
+// KindNodeImage returns the kind node image of the Kubernetes version, e.g. kindest/node:v1.31.0 for 1.31.0
+func KindNodeImage(kubernetesVersion string) string {
+	return fmt.Sprintf("%s:v%s", kindNodeImage, strings.TrimPrefix(kubernetesVersion, "v"))
+}
+
+// PatchKindConfig sets the node image, if not empty, and adds the mounts to all nodes of the kind configuration file.
+// Mounts of a container path already mounted on a node are skipped. A configuration without nodes gets a control plane node.
+func PatchKindConfig(data []byte, nodeImage string, mounts ...KindMount) ([]byte, error) {
+	config := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse kind config: %w", err)
+	}
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	config["kind"] = "Cluster"
+	if _, ok := config["apiVersion"]; !ok {
+		config["apiVersion"] = kindAPIVersion
+	}
+	nodes, ok := config["nodes"].([]interface{})
+	if !ok || len(nodes) == 0 {
+		nodes = []interface{}{map[string]interface{}{"role": KindControlPlane}}
+	}
+	for i, n := range nodes {
+		node, ok := n.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("failed to parse kind config: node %d is no object", i)
+		}
+		if nodeImage != "" {
+			node["image"] = nodeImage
+		}
+		extraMounts, _ := node["extraMounts"].([]interface{})
+		for _, mount := range mounts {
+			if !hasMount(extraMounts, mount.ContainerPath) {
+				extraMounts = append(extraMounts, map[string]interface{}{
+					"hostPath":      mount.HostPath,
+					"containerPath": mount.ContainerPath,
+					"readOnly":      mount.ReadOnly,
+				})
+			}
+		}
+		if len(extraMounts) > 0 {
+			node["extraMounts"] = extraMounts
+		}
+	}
+	config["nodes"] = nodes
+	return yaml.Marshal(config)
+}
+
+func hasMount(mounts []interface{}, containerPath string) bool {
+	for _, m := range mounts {
+		if mount, ok := m.(map[string]interface{}); ok && mount["containerPath"] == containerPath {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKindNodeImage(t *testing.T) {
+	require.Equal(t, "kindest/node:v1.31.0", KindNodeImage("1.31.0"))
+	require.Equal(t, "kindest/node:v1.31.0", KindNodeImage("v1.31.0"))
+}
+
+func TestKindConfig_Marshal(t *testing.T) {
+	data, err := KindConfig{
+		Nodes: []KindNode{
+			{Role: KindControlPlane, ExtraPortMappings: []KindPortMapping{{ContainerPort: 30080, HostPort: 8080}}},
+			{Role: KindWorker, Labels: map[string]string{"pool": "a"}},
+		},
+		FeatureGates: map[string]bool{"InPlacePodVerticalScaling": true},
+	}.Marshal()
+	require.NoError(t, err)
+	require.YAMLEq(t, `
+apiVersion: kind.x-k8s.io/v1alpha4
+kind: Cluster
+featureGates:
+  InPlacePodVerticalScaling: true
+nodes:
+- role: control-plane
+  extraPortMappings:
+  - containerPort: 30080
+    hostPort: 8080
+- role: worker
+  labels:
+    pool: a
+`, string(data))
+}
+
+func TestPatchKindConfig(t *testing.T) {
+	cache := KindMount{HostPath: "/tmp/xp-testing/e2e/xpkg", ContainerPath: "/cache/xpkg"}
+
+	t.Run("empty config", func(t *testing.T) {
+		data, err := PatchKindConfig(nil, "kindest/node:v1.31.0", cache)
+		require.NoError(t, err)
+		require.YAMLEq(t, `
+apiVersion: kind.x-k8s.io/v1alpha4
+kind: Cluster
+nodes:
+- role: control-plane
+  image: kindest/node:v1.31.0
+  extraMounts:
+  - hostPath: /tmp/xp-testing/e2e/xpkg
+    containerPath: /cache/xpkg
+    readOnly: false
+`, string(data))
+	})
+
+	t.Run("config file", func(t *testing.T) {
+		data, err := PatchKindConfig([]byte(`
+kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+networking:
+  disableDefaultCNI: true
+nodes:
+- role: control-plane
+  image: kindest/node:v1.30.0
+  extraMounts:
+  - hostPath: /data
+    containerPath: /data
+- role: worker
+  extraMounts:
+  - hostPath: /custom-cache
+    containerPath: /cache/xpkg
+`), "", cache)
+		require.NoError(t, err)
+		require.YAMLEq(t, `
+kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+networking:
+  disableDefaultCNI: true
+nodes:
+- role: control-plane
+  image: kindest/node:v1.30.0
+  extraMounts:
+  - hostPath: /data
+    containerPath: /data
+  - hostPath: /tmp/xp-testing/e2e/xpkg
+    containerPath: /cache/xpkg
+    readOnly: false
+- role: worker
+  extraMounts:
+  - hostPath: /custom-cache
+    containerPath: /cache/xpkg
+`, string(data))
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		_, err := PatchKindConfig([]byte("nodes: [worker]"), "", cache)
+		require.EqualError(t, err, "failed to parse kind config: node 0 is no object")
+	})
+}
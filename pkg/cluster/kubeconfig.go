@@ -0,0 +1,133 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient"
+	"sigs.k8s.io/e2e-framework/support"
+)
+
+// Kubeconfig is a support.E2EClusterProvider for an existing cluster, which is accessed with a kubeconfig.
+// The cluster is neither created nor destroyed, packages are loaded with a xpenvfuncs.RegistryLoader.
+type Kubeconfig struct {
+	// Path of the kubeconfig, defaults to $KUBECONFIG or ~/.kube/config
+	Path string
+	// Context to use, defaults to the current context of the kubeconfig
+	Context string
+
+	name       string
+	kubeconfig string
+	restConfig *rest.Config
+}
+
+var _ support.E2EClusterProvider = &Kubeconfig{}
+
+// NewKubeconfig returns a Kubeconfig provider for the given kubeconfig and context, empty values select the defaults
+func NewKubeconfig(path string, kubeContext string) *Kubeconfig {
+	return &Kubeconfig{Path: path, Context: kubeContext}
+}
+
+// WithName sets the name the cluster is referred to in the test environment
+func (k *Kubeconfig) WithName(name string) support.E2EClusterProvider {
+	k.name = name
+	return k
+}
+
+// WithVersion is a no-op, the version is given by the existing cluster
+func (k *Kubeconfig) WithVersion(string) support.E2EClusterProvider {
+	return k
+}
+
+// WithPath is a no-op, no binary is required
+func (k *Kubeconfig) WithPath(string) support.E2EClusterProvider {
+	return k
+}
+
+// WithOpts is a no-op
+func (k *Kubeconfig) WithOpts(...support.ClusterOpts) support.E2EClusterProvider {
+	return k
+}
+
+// Create returns the path of the kubeconfig, if a Context is given a kubeconfig with it as current context is written
+func (k *Kubeconfig) Create(context.Context, ...string) (string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = k.Path
+	raw, err := rules.Load()
+	if err != nil {
+		return "", fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	if k.Context != "" {
+		if _, ok := raw.Contexts[k.Context]; !ok {
+			return "", fmt.Errorf("kubeconfig has no context %s", k.Context)
+		}
+		raw.CurrentContext = k.Context
+	}
+	file, err := os.CreateTemp("", fmt.Sprintf("%s-kubecfg", k.name))
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	if err := clientcmd.WriteToFile(*raw, file.Name()); err != nil {
+		return "", err
+	}
+	k.kubeconfig = file.Name()
+	k.restConfig, err = clientcmd.BuildConfigFromFlags("", k.kubeconfig)
+	if err != nil {
+		return "", err
+	}
+	klog.V(4).Infof("Using existing cluster of context %s", raw.CurrentContext)
+	return k.kubeconfig, nil
+}
+
+// CreateWithConfig ignores the config file and behaves like Create
+func (k *Kubeconfig) CreateWithConfig(ctx context.Context, _ string) (string, error) {
+	return k.Create(ctx)
+}
+
+// GetKubeconfig returns the path of the kubeconfig written by Create
+func (k *Kubeconfig) GetKubeconfig() string {
+	return k.kubeconfig
+}
+
+// GenerateKubeconfig behaves like Create
+func (k *Kubeconfig) GenerateKubeconfig(...string) (string, error) {
+	return k.Create(context.Background())
+}
+
+// GetKubectlContext returns the context of the cluster
+func (k *Kubeconfig) GetKubectlContext() string {
+	return k.Context
+}
+
+// ExportLogs is a no-op, the logs of an existing cluster can't be exported generically
+func (k *Kubeconfig) ExportLogs(context.Context, string) error {
+	klog.V(4).Infof("Skip exporting logs of existing cluster %s", k.name)
+	return nil
+}
+
+// Destroy is a no-op, the existing cluster is kept
+func (k *Kubeconfig) Destroy(context.Context) error {
+	return nil
+}
+
+// SetDefaults is a no-op
+func (k *Kubeconfig) SetDefaults() support.E2EClusterProvider {
+	return k
+}
+
+// WaitForControlPlane is a no-op, the cluster is running already
+func (k *Kubeconfig) WaitForControlPlane(context.Context, klient.Client) error {
+	return nil
+}
+
+// KubernetesRestConfig returns the rest.Config of the cluster
+func (k *Kubeconfig) KubernetesRestConfig() *rest.Config {
+	return k.restConfig
+}
@@ -0,0 +1,254 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+)
+
+type recorderKey struct{}
+
+// WithRecorder stores the recorder in the context, so that later steps of a feature can access it
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// FromContext returns the recorder stored in the context, if any
+func FromContext(ctx context.Context) (*Recorder, bool) {
+	r, ok := ctx.Value(recorderKey{}).(*Recorder)
+	return r, ok
+}
+
+type involvedObject struct {
+	kind string
+	name string
+}
+
+type podPrefix struct {
+	namespace string
+	prefix    string
+}
+
+// Recorder records the kubernetes events of tracked objects, e.g. the managed resources of a test feature
+// and the provider pods, from the time it was started.
+type Recorder struct {
+	client kubernetes.Interface
+	now    func() time.Time
+
+	mu      sync.Mutex
+	since   time.Time
+	stop    chan struct{}
+	objects map[involvedObject]struct{}
+	pods    []podPrefix
+	events  map[types.UID]corev1.Event
+}
+
+// NewRecorder creates a recorder for the cluster of the given rest config
+func NewRecorder(cfg *rest.Config) (*Recorder, error) {
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newRecorder(client), nil
+}
+
+func newRecorder(client kubernetes.Interface) *Recorder {
+	return &Recorder{
+		client:  client,
+		now:     time.Now,
+		objects: map[involvedObject]struct{}{},
+		events:  map[types.UID]corev1.Event{},
+	}
+}
+
+// Track records the events of the given objects
+func (r *Recorder) Track(objects ...k8s.Object) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, object := range objects {
+		r.objects[involvedObject{kind: object.GetObjectKind().GroupVersionKind().Kind, name: object.GetName()}] = struct{}{}
+	}
+}
+
+// TrackPods records the events of all pods in the namespace whose names start with the prefix,
+// e.g. the pods of a provider revision, including pods created after the recorder was started
+func (r *Recorder) TrackPods(namespace string, prefix string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pods = append(r.pods, podPrefix{namespace: namespace, prefix: prefix})
+}
+
+// Start starts watching events in all namespaces, events which happened before are ignored
+func (r *Recorder) Start(ctx context.Context) error {
+	r.mu.Lock()
+	if r.stop != nil {
+		r.mu.Unlock()
+		return nil
+	}
+	// event timestamps only have a precision of seconds
+	r.since = r.now().Truncate(time.Second)
+	r.stop = make(chan struct{})
+	stop := r.stop
+	r.mu.Unlock()
+
+	factory := informers.NewSharedInformerFactory(r.client, 0)
+	informer := factory.Core().V1().Events().Informer()
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    r.record,
+		UpdateFunc: func(_, obj interface{}) { r.record(obj) },
+	})
+	if err != nil {
+		return err
+	}
+	factory.Start(stop)
+	for _, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("event informer did not sync: %w", ctx.Err())
+		}
+	}
+	return nil
+}
+
+// Stop stops watching events, the recorded events are still available
+func (r *Recorder) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+}
+
+func (r *Recorder) record(obj interface{}) {
+	event, ok := obj.(*corev1.Event)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if Time(*event).Before(r.since) || !r.tracks(event.InvolvedObject) {
+		return
+	}
+	r.events[event.UID] = *event.DeepCopy()
+}
+
+func (r *Recorder) tracks(ref corev1.ObjectReference) bool {
+	if _, ok := r.objects[involvedObject{kind: ref.Kind, name: ref.Name}]; ok {
+		return true
+	}
+	if ref.Kind != "Pod" {
+		return false
+	}
+	for _, pod := range r.pods {
+		if ref.Namespace == pod.namespace && strings.HasPrefix(ref.Name, pod.prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Events returns all recorded events, oldest first
+func (r *Recorder) Events() []corev1.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := make([]corev1.Event, 0, len(r.events))
+	for _, event := range r.events {
+		events = append(events, event)
+	}
+	Sort(events)
+	return events
+}
+
+// Warnings returns the recorded events of type Warning, restricted to the given reasons if any
+func (r *Recorder) Warnings(reasons ...string) []corev1.Event {
+	var warnings []corev1.Event
+	for _, event := range r.Events() {
+		if event.Type != corev1.EventTypeWarning {
+			continue
+		}
+		if len(reasons) == 0 || lo.Contains(reasons, event.Reason) {
+			warnings = append(warnings, event)
+		}
+	}
+	return warnings
+}
+
+// Log writes all recorded events to the test output
+func (r *Recorder) Log(t *testing.T) {
+	events := r.Events()
+	t.Logf("Recorded %d event(s)", len(events))
+	for _, event := range events {
+		t.Log(Format(event))
+	}
+}
+
+// Format returns a single line representation of the event,
+// like `2024-01-01T00:00:00Z Warning CannotCreateExternalResource NopResource/example (x2): message`
+func Format(event corev1.Event) string {
+	return fmt.Sprintf("%s %s %s %s/%s (x%d): %s",
+		Time(event).Format(time.RFC3339), event.Type, event.Reason,
+		event.InvolvedObject.Kind, event.InvolvedObject.Name, max(event.Count, 1), event.Message)
+}
+
+// NoWarnings fails the test if the recorder in the context recorded Warning events,
+// restricted to the given reasons if any, e.g. NoWarnings("CannotUpdateExternalResource")
+func NoWarnings(reasons ...string) features.Func {
+	return func(ctx context.Context, t *testing.T, _ *envconf.Config) context.Context {
+		r, ok := FromContext(ctx)
+		if !ok {
+			t.Fatal("no event recorder in context, it is started by ResourceTestConfig.Setup")
+			return ctx
+		}
+		if warnings := r.Warnings(reasons...); len(warnings) > 0 {
+			lines := make([]string, 0, len(warnings))
+			for _, warning := range warnings {
+				lines = append(lines, Format(warning))
+			}
+			t.Errorf("expected no warning events, got %d:\n%s", len(warnings), strings.Join(lines, "\n"))
+		}
+		return ctx
+	}
+}
+
+// Time returns the time the event was last observed, falling back to its creation for events without timestamps
+func Time(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// Sort sorts the events by Time, oldest first
+func Sort(events []corev1.Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return Time(events[i]).Before(Time(events[j]))
+	})
+}
+
+// Latest sorts the events and returns up to limit of the most recent ones, oldest first
+func Latest(events []corev1.Event, limit int) []corev1.Event {
+	Sort(events)
+	if len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events
+}
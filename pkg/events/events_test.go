@@ -0,0 +1,83 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func event(name string, eventType string, reason string, kind string, objectName string, at time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        reason + " message",
+		InvolvedObject: corev1.ObjectReference{Kind: kind, Name: objectName, Namespace: "crossplane-system"},
+		LastTimestamp:  metav1.NewTime(at),
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	client := fake.NewSimpleClientset(
+		event("old", corev1.EventTypeWarning, "CannotCreateExternalResource", "NopResource", "example", start.Add(-time.Minute)),
+		event("created", corev1.EventTypeNormal, "CreatedExternalResource", "NopResource", "example", start),
+	)
+	r := newRecorder(client)
+	r.now = func() time.Time { return start.Add(500 * time.Millisecond) }
+
+	object := &unstructured.Unstructured{}
+	object.SetKind("NopResource")
+	object.SetName("example")
+	r.Track(object)
+	r.TrackPods("crossplane-system", "provider-nop-abc-")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, r.Start(ctx))
+	defer r.Stop()
+
+	for _, e := range []*corev1.Event{
+		event("update", corev1.EventTypeWarning, "CannotUpdateExternalResource", "NopResource", "example", start.Add(time.Second)),
+		event("pod", corev1.EventTypeWarning, "BackOff", "Pod", "provider-nop-abc-12345-xyz", start.Add(2*time.Second)),
+		event("other", corev1.EventTypeWarning, "BackOff", "Pod", "provider-other-12345-xyz", start.Add(2*time.Second)),
+	} {
+		_, err := client.CoreV1().Events("default").Create(ctx, e, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool { return len(r.Events()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	reasons := []string{}
+	for _, e := range r.Events() {
+		reasons = append(reasons, e.Reason)
+	}
+	require.Equal(t, []string{"CreatedExternalResource", "CannotUpdateExternalResource", "BackOff"}, reasons)
+	require.Len(t, r.Warnings(), 2)
+	require.Len(t, r.Warnings("CannotUpdateExternalResource"), 1)
+	require.Empty(t, r.Warnings("CannotDeleteExternalResource"))
+}
+
+func TestFormat(t *testing.T) {
+	e := event("update", corev1.EventTypeWarning, "CannotUpdateExternalResource", "NopResource", "example", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	e.Count = 3
+	require.Equal(t, "2024-01-01T00:00:00Z Warning CannotUpdateExternalResource NopResource/example (x3): CannotUpdateExternalResource message", Format(*e))
+}
+
+func TestLatest(t *testing.T) {
+	now := time.Now()
+	got := Latest([]corev1.Event{
+		*event("third", corev1.EventTypeNormal, "third", "NopResource", "example", now.Add(3*time.Second)),
+		*event("first", corev1.EventTypeNormal, "first", "NopResource", "example", now.Add(time.Second)),
+		{ObjectMeta: metav1.ObjectMeta{Name: "created", CreationTimestamp: metav1.NewTime(now.Add(2 * time.Second))}, Reason: "second"},
+	}, 2)
+	require.Len(t, got, 2)
+	require.Equal(t, "second", got[0].Reason, "falls back to the creation time")
+	require.Equal(t, "third", got[1].Reason)
+}
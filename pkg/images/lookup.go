@@ -3,6 +3,7 @@ package images
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -19,6 +20,18 @@ type ProviderImages struct {
 	ControllerImage *string
 }
 
+// Version returns the tag or digest of the package image, e.g. `v0.2.1` for `xpkg.upbound.io/provider-nop:v0.2.1`,
+// or an empty string if the package reference has none
+func (p ProviderImages) Version() string {
+	if i := strings.LastIndex(p.Package, "@"); i >= 0 {
+		return p.Package[i+1:]
+	}
+	if i := strings.LastIndex(p.Package, ":"); i > strings.LastIndex(p.Package, "/") {
+		return p.Package[i+1:]
+	}
+	return ""
+}
+
 // GetImagesFromEnvironmentOrPanic retrieves image information from the environment and panics if `E2E_IMAGES` is not set
 // `E2E_IMAGES` is expected to be a simple json like this.
 // ```{"$PackageKey": "ImageUrlOfPackageImage", "$controllerKey": "ImageUrlOfControllerImage"}```
@@ -49,3 +49,10 @@ func (suite *LookupSuite) TestGetImagesFromJSONOrPanic() {
 	})
 	os.Unsetenv("E2E_IMAGES")
 }
+
+func (suite *LookupSuite) TestVersion() {
+	suite.Require().Equal("v0.2.1", ProviderImages{Package: "xpkg.upbound.io/crossplane-contrib/provider-nop:v0.2.1"}.Version())
+	suite.Require().Equal("v0.2.1", ProviderImages{Package: "localhost:5000/provider-nop:v0.2.1"}.Version())
+	suite.Require().Equal("sha256:abc", ProviderImages{Package: "localhost:5000/provider-nop@sha256:abc"}.Version())
+	suite.Require().Equal("", ProviderImages{Package: "localhost:5000/provider-nop"}.Version())
+}
@@ -0,0 +1,199 @@
+package mockserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+	"sigs.k8s.io/e2e-framework/pkg/types"
+)
+
+const journalPath = "__admin/requests"
+
+// Request is a call received by a mock server
+type Request struct {
+	Method     string
+	URL        string
+	Body       string
+	LoggedDate time.Time
+	// Matched is false, if no stub mapping matched the request
+	Matched bool
+	// Status is the status code of the response
+	Status int
+}
+
+func (r Request) String() string {
+	return fmt.Sprintf("%s %s -> %d", r.Method, r.URL, r.Status)
+}
+
+// journal is the response of the WireMock admin API, see https://wiremock.org/docs/verifying/
+type journal struct {
+	Requests []struct {
+		Request struct {
+			URL        string `json:"url"`
+			Method     string `json:"method"`
+			Body       string `json:"body"`
+			LoggedDate int64  `json:"loggedDate"`
+		} `json:"request"`
+		ResponseDefinition struct {
+			Status int `json:"status"`
+		} `json:"responseDefinition"`
+		WasMatched bool `json:"wasMatched"`
+	} `json:"requests"`
+}
+
+func parseJournal(data []byte) ([]Request, error) {
+	j := journal{}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("failed to parse request journal: %w", err)
+	}
+	requests := make([]Request, 0, len(j.Requests))
+	// the journal lists the latest request first
+	for i := len(j.Requests) - 1; i >= 0; i-- {
+		entry := j.Requests[i]
+		requests = append(requests, Request{
+			Method:     entry.Request.Method,
+			URL:        entry.Request.URL,
+			Body:       entry.Request.Body,
+			LoggedDate: time.UnixMilli(entry.Request.LoggedDate),
+			Matched:    entry.WasMatched,
+			Status:     entry.ResponseDefinition.Status,
+		})
+	}
+	return requests, nil
+}
+
+// Requests returns the calls received by the mock server since the last Reset in the order they were received.
+// The journal is read through the service proxy of the API server, so it works from outside the cluster.
+func Requests(ctx context.Context, cfg *rest.Config, name string) ([]Request, error) {
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	data, err := client.CoreV1().Services(Namespace).ProxyGet("http", name, "http", journalPath, nil).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get requests of mock server %s: %w", name, err)
+	}
+	return parseJournal(data)
+}
+
+// Reset clears the recorded calls of the mock server
+func Reset(ctx context.Context, cfg *rest.Config, name string) error {
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	err = client.CoreV1().RESTClient().Delete().
+		Namespace(Namespace).
+		Resource("services").
+		Name("http:" + name + ":http").
+		SubResource("proxy").
+		Suffix(journalPath).
+		Do(ctx).Error()
+	if err != nil {
+		return fmt.Errorf("failed to reset requests of mock server %s: %w", name, err)
+	}
+	return nil
+}
+
+// ResetRequests returns a BeforeEachFeature hook, which clears the recorded calls of the mock servers,
+// so the calls returned by Requests belong to the current feature. Servers not running WireMock are skipped.
+func ResetRequests(servers ...MockServer) types.FeatureEnvFunc {
+	servers = wireMockServers(servers)
+	return func(ctx context.Context, cfg *envconf.Config, t *testing.T, _ features.Feature) (context.Context, error) {
+		for _, server := range servers {
+			if err := Reset(ctx, cfg.Client().RESTConfig(), server.Name); err != nil {
+				return ctx, err
+			}
+		}
+		return ctx, nil
+	}
+}
+
+// LogRequests returns an AfterEachFeature hook, which logs the calls the mock servers received during the feature.
+// Servers not running WireMock are skipped.
+func LogRequests(servers ...MockServer) types.FeatureEnvFunc {
+	servers = wireMockServers(servers)
+	return func(ctx context.Context, cfg *envconf.Config, t *testing.T, feature features.Feature) (context.Context, error) {
+		for _, server := range servers {
+			requests, err := Requests(ctx, cfg.Client().RESTConfig(), server.Name)
+			if err != nil {
+				t.Log(err)
+				continue
+			}
+			lines := make([]string, 0, len(requests))
+			for _, request := range requests {
+				lines = append(lines, request.String())
+			}
+			t.Logf("mock server %s received %d requests during %s:\n%s", server.Name, len(requests), feature.Name(), strings.Join(lines, "\n"))
+		}
+		return ctx, nil
+	}
+}
+
+// AssertRequested fails the test, if the mock server received no request with the given method and an URL matching urlPattern
+func AssertRequested(name string, method string, urlPattern string) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		requests, err := Requests(ctx, cfg.Client().RESTConfig(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		matches, err := filter(requests, method, urlPattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) == 0 {
+			t.Errorf("mock server %s received no request %s %s, received: %v", name, method, urlPattern, requests)
+		}
+		return ctx
+	}
+}
+
+// AssertNoUnmatched fails the test, if the mock server received requests no stub mapping matched
+func AssertNoUnmatched(name string) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		requests, err := Requests(ctx, cfg.Client().RESTConfig(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, request := range requests {
+			if !request.Matched {
+				t.Errorf("mock server %s received unmatched request %s", name, request)
+			}
+		}
+		return ctx
+	}
+}
+
+// filter returns the requests with the given method and an URL matching urlPattern, an empty method matches all
+func filter(requests []Request, method string, urlPattern string) ([]Request, error) {
+	re, err := regexp.Compile(urlPattern)
+	if err != nil {
+		return nil, err
+	}
+	var matches []Request
+	for _, request := range requests {
+		if (method == "" || strings.EqualFold(method, request.Method)) && re.MatchString(request.URL) {
+			matches = append(matches, request)
+		}
+	}
+	return matches, nil
+}
+
+// TemplateData returns the data for xpenvfuncs.ApplyProviderConfigFromDirWithData,
+// the URL of a server is available as {{ index .MockServers "<name>" }}
+func TemplateData(servers ...MockServer) map[string]interface{} {
+	urls := map[string]string{}
+	for _, server := range servers {
+		urls[server.Name] = server.URL()
+	}
+	return map[string]interface{}{"MockServers": urls}
+}
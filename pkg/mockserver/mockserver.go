@@ -0,0 +1,247 @@
+package mockserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/klient/wait/conditions"
+	"sigs.k8s.io/e2e-framework/pkg/env"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+
+	resHelper "github.com/crossplane-contrib/xp-testing/pkg/resources"
+)
+
+const (
+	// Namespace is the namespace mock servers are deployed to
+	Namespace = "mock-servers"
+	// DefaultImage is the WireMock image used, if MockServer.Image is not set
+	DefaultImage = "wiremock/wiremock:3.9.1"
+	// DefaultPort is the port of WireMock
+	DefaultPort = 8080
+
+	wireMockRepository = "wiremock/wiremock"
+	// fixturesHashAnnotation on the pod template rolls the server out again, if its fixtures changed
+	fixturesHashAnnotation = "xp-testing.crossplane.io/fixtures-hash"
+
+	mappingsPath = "/home/wiremock/mappings"
+	filesPath    = "/home/wiremock/__files"
+)
+
+// MockServer is a test double of an external API, deployed into the cluster.
+// By default it is a WireMock server stubbing the responses defined by the fixtures in MappingsDir,
+// see https://wiremock.org/docs/stubbing/. Alternatively it can be any image serving HTTP, e.g. a Go handler.
+type MockServer struct {
+	// Name of the deployment and service, the server is reachable under URL
+	Name string
+	// Image of the server, defaults to DefaultImage
+	Image string
+	// Port the server listens on, defaults to DefaultPort
+	Port int32
+	// MappingsDir is a directory of WireMock stub mappings (*.json), mounted into the WireMock container
+	MappingsDir string
+	// FilesDir is a directory of response bodies referenced by the mappings via bodyFileName
+	FilesDir string
+	// Args are passed to the server container
+	Args []string
+	// WireMock marks a custom Image as WireMock, e.g. a mirror of the WireMock image. Only the requests of WireMock
+	// servers are reset and logged per feature, other images don't serve its request journal.
+	WireMock bool
+}
+
+func (m MockServer) image() string {
+	if m.Image == "" {
+		return DefaultImage
+	}
+	return m.Image
+}
+
+// wireMock returns if the server serves the WireMock admin API
+func (m MockServer) wireMock() bool {
+	return m.WireMock || m.Image == "" || strings.HasPrefix(m.Image, wireMockRepository+":") || strings.HasPrefix(m.Image, wireMockRepository+"@")
+}
+
+// wireMockServers returns the servers serving the WireMock admin API
+func wireMockServers(servers []MockServer) []MockServer {
+	var result []MockServer
+	for _, server := range servers {
+		if server.wireMock() {
+			result = append(result, server)
+		}
+	}
+	return result
+}
+
+func (m MockServer) port() int32 {
+	if m.Port == 0 {
+		return DefaultPort
+	}
+	return m.Port
+}
+
+// URL returns the in-cluster URL of the server, e.g. to be used in a ProviderConfig
+func (m MockServer) URL() string {
+	return fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", m.Name, Namespace, m.port())
+}
+
+// Deploy returns an env.Func, which deploys the mock servers and waits until they are available.
+// Existing mock servers are updated and rolled out again if their fixtures changed, since WireMock loads the
+// mappings at startup, so it can be used with reused clusters.
+func Deploy(servers ...MockServer) env.Func {
+	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+		if len(servers) == 0 {
+			return ctx, nil
+		}
+		res, err := resources.New(cfg.Client().RESTConfig())
+		if err != nil {
+			return ctx, err
+		}
+		if err := resHelper.CreateOrUpdate(ctx, res, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: Namespace}}); err != nil {
+			return ctx, err
+		}
+		for _, server := range servers {
+			klog.V(4).Infof("Deploy mock server %s", server.Name)
+			objects, err := server.objects()
+			if err != nil {
+				return ctx, err
+			}
+			for _, obj := range objects {
+				if err := resHelper.CreateOrUpdate(ctx, res, obj); err != nil {
+					return ctx, fmt.Errorf("failed to deploy mock server %s: %w", server.Name, err)
+				}
+			}
+		}
+		for _, server := range servers {
+			deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: server.Name, Namespace: Namespace}}
+			err := wait.For(conditions.New(res).ResourceMatch(deployment, func(obj k8s.Object) bool {
+				return rolledOut(obj.(*appsv1.Deployment))
+			}), wait.WithTimeout(3*time.Minute))
+			if err != nil {
+				return ctx, fmt.Errorf("mock server %s did not become available: %w", server.Name, err)
+			}
+		}
+		return ctx, nil
+	}
+}
+
+// objects returns the config maps, deployment and service of the server
+func (m MockServer) objects() ([]k8s.Object, error) {
+	labels := map[string]string{"app.kubernetes.io/name": m.Name, "app.kubernetes.io/part-of": "xp-testing"}
+	container := corev1.Container{
+		Name:  "server",
+		Image: m.image(),
+		Args:  m.Args,
+		Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: m.port()}},
+	}
+	podSpec := corev1.PodSpec{}
+	fixtures := sha256.New()
+	var objects []k8s.Object
+	for _, mount := range []struct {
+		suffix string
+		dir    string
+		path   string
+	}{{"mappings", m.MappingsDir, mappingsPath}, {"files", m.FilesDir, filesPath}} {
+		if mount.dir == "" {
+			continue
+		}
+		data, binaryData, err := readDir(mount.dir)
+		if err != nil {
+			return nil, err
+		}
+		name := m.Name + "-" + mount.suffix
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: Namespace, Labels: labels},
+			Data:       data,
+			BinaryData: binaryData,
+		}
+		if err := json.NewEncoder(fixtures).Encode(configMap); err != nil {
+			return nil, err
+		}
+		objects = append(objects, configMap)
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name:         mount.suffix,
+			VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}}},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: mount.suffix, MountPath: mount.path})
+	}
+	podSpec.Containers = []corev1.Container{container}
+
+	objects = append(objects,
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: m.Name, Namespace: Namespace, Labels: labels},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: ptr.To[int32](1),
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels:      labels,
+						Annotations: map[string]string{fixturesHashAnnotation: hex.EncodeToString(fixtures.Sum(nil))},
+					},
+					Spec: podSpec,
+				},
+			},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: m.Name, Namespace: Namespace, Labels: labels},
+			Spec: corev1.ServiceSpec{
+				Selector: labels,
+				Ports:    []corev1.ServicePort{{Name: "http", Port: m.port(), TargetPort: intstr.FromString("http")}},
+			},
+		},
+	)
+	return objects, nil
+}
+
+// rolledOut returns if all replicas of the deployment run its current pod template and are available
+func rolledOut(d *appsv1.Deployment) bool {
+	replicas := ptr.Deref(d.Spec.Replicas, 1)
+	return d.Status.ObservedGeneration >= d.Generation &&
+		d.Status.UpdatedReplicas == replicas &&
+		d.Status.Replicas == replicas &&
+		d.Status.AvailableReplicas == replicas
+}
+
+// readDir returns the content of all regular files in the directory keyed by file name, UTF-8 files as data,
+// others, like binary response bodies, as binary data, which the API server doesn't accept as data
+func readDir(dir string) (map[string]string, map[string][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	data := map[string]string{}
+	var binaryData map[string][]byte
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+		if !utf8.Valid(content) {
+			if binaryData == nil {
+				binaryData = map[string][]byte{}
+			}
+			binaryData[entry.Name()] = content
+			continue
+		}
+		data[entry.Name()] = string(content)
+	}
+	return data, binaryData, nil
+}
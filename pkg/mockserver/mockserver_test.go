@@ -0,0 +1,119 @@
+package mockserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestMockServer_objects(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "get.json"), []byte(`{"request":{"method":"GET"}}`), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested"), 0o755))
+
+	objects, err := MockServer{Name: "api", MappingsDir: dir}.objects()
+	require.NoError(t, err)
+	require.Len(t, objects, 3)
+
+	configMap := objects[0].(*corev1.ConfigMap)
+	require.Equal(t, "api-mappings", configMap.Name)
+	require.Equal(t, map[string]string{"get.json": `{"request":{"method":"GET"}}`}, configMap.Data)
+
+	deployment := objects[1].(*appsv1.Deployment)
+	container := deployment.Spec.Template.Spec.Containers[0]
+	require.Equal(t, DefaultImage, container.Image)
+	require.Equal(t, int32(DefaultPort), container.Ports[0].ContainerPort)
+	require.Equal(t, []corev1.VolumeMount{{Name: "mappings", MountPath: mappingsPath}}, container.VolumeMounts)
+
+	service := objects[2].(*corev1.Service)
+	require.Equal(t, Namespace, service.Namespace)
+	require.Equal(t, deployment.Spec.Selector.MatchLabels, service.Spec.Selector)
+}
+
+func TestMockServer_URL(t *testing.T) {
+	require.Equal(t, "http://api.mock-servers.svc.cluster.local:8080", MockServer{Name: "api"}.URL())
+	require.Equal(t, "http://api.mock-servers.svc.cluster.local:9000", MockServer{Name: "api", Port: 9000}.URL())
+	require.Equal(t,
+		map[string]interface{}{"MockServers": map[string]string{"api": "http://api.mock-servers.svc.cluster.local:8080"}},
+		TemplateData(MockServer{Name: "api"}))
+}
+
+func Test_parseJournal(t *testing.T) {
+	requests, err := parseJournal([]byte(`{"requests":[
+		{"request":{"url":"/things/1","method":"DELETE","loggedDate":2000},"responseDefinition":{"status":404},"wasMatched":false},
+		{"request":{"url":"/things","method":"POST","body":"{}","loggedDate":1000},"responseDefinition":{"status":201},"wasMatched":true}
+	]}`))
+	require.NoError(t, err)
+	require.Equal(t, []Request{
+		{Method: "POST", URL: "/things", Body: "{}", LoggedDate: time.UnixMilli(1000), Matched: true, Status: 201},
+		{Method: "DELETE", URL: "/things/1", LoggedDate: time.UnixMilli(2000), Status: 404},
+	}, requests)
+
+	matches, err := filter(requests, "post", "^/things$")
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	matches, err = filter(requests, "", "/things")
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	_, err = filter(requests, "", "(")
+	require.Error(t, err)
+}
+
+func Test_wireMockServers(t *testing.T) {
+	servers := []MockServer{
+		{Name: "default"},
+		{Name: "pinned", Image: "wiremock/wiremock:3.3.1"},
+		{Name: "mirrored", Image: "registry.example.com/wiremock:3.9.1", WireMock: true},
+		{Name: "handler", Image: "registry.example.com/api-handler:latest"},
+	}
+	var names []string
+	for _, server := range wireMockServers(servers) {
+		names = append(names, server.Name)
+	}
+	require.Equal(t, []string{"default", "pinned", "mirrored"}, names)
+}
+
+func TestMockServer_objects_fixtures(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "body.json"), []byte(`{"id":1}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "body.gz"), []byte{0x1f, 0x8b, 0xff}, 0o644))
+	hash := func() string {
+		objects, err := MockServer{Name: "api", FilesDir: dir}.objects()
+		require.NoError(t, err)
+		return objects[1].(*appsv1.Deployment).Spec.Template.Annotations[fixturesHashAnnotation]
+	}
+
+	objects, err := MockServer{Name: "api", FilesDir: dir}.objects()
+	require.NoError(t, err)
+	configMap := objects[0].(*corev1.ConfigMap)
+	require.Equal(t, map[string]string{"body.json": `{"id":1}`}, configMap.Data)
+	require.Equal(t, map[string][]byte{"body.gz": {0x1f, 0x8b, 0xff}}, configMap.BinaryData, "non UTF-8 files are binary data")
+
+	before := hash()
+	require.NotEmpty(t, before)
+	require.Equal(t, before, hash())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "body.json"), []byte(`{"id":2}`), 0o644))
+	require.NotEqual(t, before, hash(), "changed fixtures roll the server out again")
+}
+
+func Test_rolledOut(t *testing.T) {
+	deployment := func(generation int64, status appsv1.DeploymentStatus) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Generation: generation},
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](1)},
+			Status:     status,
+		}
+	}
+	require.True(t, rolledOut(deployment(2, appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1})))
+	require.False(t, rolledOut(deployment(2, appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1})),
+		"the new template isn't observed yet")
+	require.False(t, rolledOut(deployment(2, appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 1})),
+		"the old pod is still running")
+}
@@ -0,0 +1,317 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
+	"sigs.k8s.io/e2e-framework/pkg/env"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+)
+
+const (
+	// DefaultLogTailLines is the number of log lines per container written to the test output when a feature fails
+	DefaultLogTailLines = 50
+	// podPollInterval is the interval in which new provider pods are looked up, e.g. after a restart
+	podPollInterval = 2 * time.Second
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+type logCollectorKey struct{}
+
+// LogCollector streams the logs of the provider controller pods with timestamps into files of a directory,
+// one file per pod and container. Pods created while collecting, e.g. after a restart, are picked up as well.
+type LogCollector struct {
+	client kubernetes.Interface
+	pods   func(ctx context.Context) ([]corev1.Pod, error)
+	dir    string
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	streams map[string]struct{}
+	files   []string
+	// written records the timestamp of the last line written per pod and container, a dropped stream continues there
+	written map[string]time.Time
+	// failed records if the test already failed before the feature, see StopLogCollection
+	failed bool
+}
+
+// NewLogCollector creates a LogCollector for the pods of the current revision of the provider
+func NewLogCollector(cfg *envconf.Config, providerName string, dir string) (*LogCollector, error) {
+	client, err := kubernetes.NewForConfig(cfg.Client().RESTConfig())
+	if err != nil {
+		return nil, err
+	}
+	r, err := resources.New(cfg.Client().RESTConfig())
+	if err != nil {
+		return nil, err
+	}
+	return newLogCollector(client, func(ctx context.Context) ([]corev1.Pod, error) {
+		return Pods(ctx, r, providerName)
+	}, dir), nil
+}
+
+func newLogCollector(client kubernetes.Interface, pods func(ctx context.Context) ([]corev1.Pod, error), dir string) *LogCollector {
+	return &LogCollector{client: client, pods: pods, dir: dir, streams: map[string]struct{}{}, written: map[string]time.Time{}}
+}
+
+// Start starts streaming the logs written from now on, until Stop is called
+func (c *LogCollector) Start(ctx context.Context) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	ctx, c.cancel = context.WithCancel(ctx)
+	since := metav1.Now()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(podPollInterval)
+		defer ticker.Stop()
+		for {
+			c.streamNewPods(ctx, since)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops streaming and waits until all logs are written
+func (c *LogCollector) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+}
+
+// Files returns the paths of all log files written, one per pod and container
+func (c *LogCollector) Files() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.files...)
+}
+
+// Tail returns the last lines of every log file, each file preceded by its name
+func (c *LogCollector) Tail(lines int) string {
+	b := strings.Builder{}
+	for _, file := range c.Files() {
+		tail, err := tailFile(file, lines)
+		if err != nil {
+			klog.V(4).Infof("Could not read %s: %v", file, err)
+			continue
+		}
+		b.WriteString(fmt.Sprintf("==> %s <==\n", filepath.Base(file)))
+		for _, line := range tail {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func (c *LogCollector) streamNewPods(ctx context.Context, since metav1.Time) {
+	pods, err := c.pods(ctx)
+	if err != nil {
+		klog.V(4).Infof("Could not find provider pods: %v", err)
+		return
+	}
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Running == nil {
+				continue
+			}
+			key := pod.Name + "_" + status.Name
+			c.mu.Lock()
+			_, streaming := c.streams[key]
+			c.streams[key] = struct{}{}
+			c.mu.Unlock()
+			if streaming {
+				continue
+			}
+			c.wg.Add(1)
+			go func(pod corev1.Pod, container string) {
+				defer c.wg.Done()
+				if err := c.stream(ctx, pod, container, key, since); err != nil && ctx.Err() == nil {
+					klog.V(4).Infof("Streaming logs of %s failed: %v", key, err)
+				}
+				// a restarted container is picked up by the next poll, continuing where the stream ended
+				c.mu.Lock()
+				delete(c.streams, key)
+				c.mu.Unlock()
+			}(pod, status.Name)
+		}
+	}
+}
+
+func (c *LogCollector) stream(ctx context.Context, pod corev1.Pod, container string, key string, since metav1.Time) error {
+	file := filepath.Join(c.dir, unsafeFileChars.ReplaceAllString(key, "_")+".log")
+	out, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	c.mu.Lock()
+	if !lo.Contains(c.files, file) {
+		c.files = append(c.files, file)
+	}
+	last, resumed := c.written[key]
+	c.mu.Unlock()
+	if resumed {
+		// the API only supports seconds, copyLines skips the lines of that second already written
+		since = metav1.NewTime(last)
+	}
+
+	req := c.client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container:  container,
+		Follow:     true,
+		Timestamps: true,
+		SinceTime:  &since,
+	})
+	logs, err := req.Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	return c.copyLines(ctx, out, logs, key)
+}
+
+// copyLines writes the lines of the logs not written yet and records the timestamp of the last line written
+func (c *LogCollector) copyLines(ctx context.Context, out io.Writer, logs io.Reader, key string) error {
+	c.mu.Lock()
+	last := c.written[key]
+	c.mu.Unlock()
+	reader := bufio.NewReader(logs)
+	for {
+		line, err := reader.ReadString('\n')
+		// an incomplete line of a dropped stream is sent again by the next stream
+		if err != nil && err != io.EOF && ctx.Err() == nil {
+			return err
+		}
+		timestamp, ok := lineTimestamp(line)
+		switch {
+		case line == "":
+		case ok && !timestamp.After(last):
+			// already written by a previous stream
+		default:
+			if _, err := io.WriteString(out, line); err != nil {
+				return err
+			}
+			if ok {
+				last = timestamp
+				c.mu.Lock()
+				c.written[key] = timestamp
+				c.mu.Unlock()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// lineTimestamp parses the timestamp the line is prefixed with, see corev1.PodLogOptions.Timestamps
+func lineTimestamp(line string) (time.Time, bool) {
+	prefix, _, found := strings.Cut(line, " ")
+	if !found {
+		return time.Time{}, false
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, prefix)
+	return timestamp, err == nil
+}
+
+func tailFile(file string, lines int) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var tail []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		tail = append(tail, scanner.Text())
+		if len(tail) > lines {
+			tail = tail[1:]
+		}
+	}
+	return tail, scanner.Err()
+}
+
+// StartLogCollection returns a feature hook, which starts collecting the provider logs of each feature into
+// logs/<feature-name>/. It is meant to be registered via env.Environment.BeforeEachFeature.
+func StartLogCollection(providerName string) env.FeatureFunc {
+	return StartLogCollectionIn(providerName, "")
+}
+
+// StartLogCollectionIn is StartLogCollection writing into logs/<dir>/<feature-name>/, e.g. to keep the logs of
+// several environments running the same features apart
+func StartLogCollectionIn(providerName string, dir string) env.FeatureFunc {
+	return func(ctx context.Context, cfg *envconf.Config, t *testing.T, feature features.Feature) (context.Context, error) {
+		cur, err := os.Getwd()
+		if err != nil {
+			return ctx, err
+		}
+		dir := featureLogDir(cur, dir, feature.Name())
+		collector, err := NewLogCollector(cfg, providerName, dir)
+		if err != nil {
+			return ctx, err
+		}
+		collector.failed = t.Failed()
+		klog.V(4).Infof("Writing logs of provider %s to %s", providerName, dir)
+		if err := collector.Start(ctx); err != nil {
+			return ctx, err
+		}
+		return context.WithValue(ctx, logCollectorKey{}, collector), nil
+	}
+}
+
+// featureLogDir returns the directory the provider logs of the feature are written to
+func featureLogDir(cur string, dir string, featureName string) string {
+	return filepath.Join(cur, "logs", dir, unsafeFileChars.ReplaceAllString(featureName, "_"))
+}
+
+// StopLogCollection returns a feature hook, which stops collecting the provider logs started by StartLogCollection
+// and writes the last tailLines lines of each container to the test output, if the feature failed.
+// It is meant to be registered via env.Environment.AfterEachFeature.
+func StopLogCollection(tailLines int) env.FeatureFunc {
+	return func(ctx context.Context, _ *envconf.Config, t *testing.T, feature features.Feature) (context.Context, error) {
+		collector, ok := ctx.Value(logCollectorKey{}).(*LogCollector)
+		if !ok {
+			return ctx, nil
+		}
+		collector.Stop()
+		// the hooks only get the test of all features, which is failed as soon as one feature fails,
+		// once an earlier feature failed, it can't be told if this one failed as well
+		switch {
+		case t.Failed() && !collector.failed:
+			t.Logf("Feature %s failed, last provider logs:\n%s", feature.Name(), collector.Tail(tailLines))
+		case t.Failed():
+			t.Logf("Provider logs of feature %s: %s", feature.Name(), collector.dir)
+		}
+		return ctx, nil
+	}
+}
@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLogCollector(t *testing.T) {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "provider-nop-abc-12345-xyz", Namespace: Namespace},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "package-runtime", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+			{Name: "waiting", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}}},
+		}},
+	}
+	dir := filepath.Join(t.TempDir(), "feature")
+	c := newLogCollector(fake.NewSimpleClientset(&pod), func(ctx context.Context) ([]corev1.Pod, error) {
+		return []corev1.Pod{pod}, nil
+	}, dir)
+
+	require.NoError(t, c.Start(context.Background()))
+	require.Eventually(t, func() bool { return len(c.Files()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	c.Stop()
+
+	file := filepath.Join(dir, "provider-nop-abc-12345-xyz_package-runtime.log")
+	require.Equal(t, []string{file}, c.Files())
+	// the fake client always returns the same log body
+	require.Eventually(t, func() bool {
+		content, err := os.ReadFile(file)
+		return err == nil && len(content) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Contains(t, c.Tail(5), "==> provider-nop-abc-12345-xyz_package-runtime.log <==\nfake logs")
+}
+
+func Test_tailFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.log")
+	require.NoError(t, os.WriteFile(file, []byte("1\n2\n3\n4\n"), 0o644))
+
+	tail, err := tailFile(file, 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"3", "4"}, tail)
+
+	tail, err = tailFile(file, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"1", "2", "3", "4"}, tail)
+}
+
+func Test_featureLogDir(t *testing.T) {
+	require.Equal(t, "/work/logs/create_and_delete", featureLogDir("/work", "", "create and/delete"))
+	require.Equal(t, "/work/logs/v1-20-0/create_and_delete", featureLogDir("/work", "v1-20-0", "create and/delete"))
+}
+
+func TestLogCollector_copyLines_resumed(t *testing.T) {
+	c := newLogCollector(fake.NewSimpleClientset(), nil, t.TempDir())
+	out := &strings.Builder{}
+
+	dropped := io.MultiReader(
+		strings.NewReader("2025-01-01T10:00:00.1Z started\n2025-01-01T10:00:00.2Z reconciling\n2025-01-01T10:00:00.3Z recon"),
+		iotest.ErrReader(errors.New("connection reset")),
+	)
+	require.EqualError(t, c.copyLines(context.Background(), out, dropped, "pod_container"), "connection reset")
+	require.Equal(t, time.Date(2025, 1, 1, 10, 0, 0, 200000000, time.UTC), c.written["pod_container"])
+
+	// the new stream starts at the second of the last line written
+	resumed := strings.NewReader("2025-01-01T10:00:00.1Z started\n2025-01-01T10:00:00.2Z reconciling\n2025-01-01T10:00:00.3Z reconciled\n")
+	require.NoError(t, c.copyLines(context.Background(), out, resumed, "pod_container"))
+	require.Equal(t, "2025-01-01T10:00:00.1Z started\n2025-01-01T10:00:00.2Z reconciling\n2025-01-01T10:00:00.3Z reconciled\n", out.String())
+}
+
+func Test_lineTimestamp(t *testing.T) {
+	timestamp, ok := lineTimestamp("2025-01-01T10:00:00.123456789Z msg\n")
+	require.True(t, ok)
+	require.Equal(t, time.Date(2025, 1, 1, 10, 0, 0, 123456789, time.UTC), timestamp)
+
+	_, ok = lineTimestamp("fake logs")
+	require.False(t, ok)
+}
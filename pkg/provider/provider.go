@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/klient/wait/conditions"
+)
+
+const (
+	// Namespace is the namespace crossplane runs the provider pods in, the same as xpenvfuncs.CrossplaneNamespace
+	Namespace = "crossplane-system"
+	// RevisionLabel is the label crossplane sets on the pods of a provider revision
+	RevisionLabel = "pkg.crossplane.io/revision"
+)
+
+var providerGVK = schema.GroupVersionKind{Group: "pkg.crossplane.io", Version: "v1", Kind: "Provider"}
+
+// CurrentRevision returns the name of the current revision of the provider, which owns the controller deployment
+func CurrentRevision(ctx context.Context, r *resources.Resources, name string) (string, error) {
+	provider := &unstructured.Unstructured{}
+	provider.SetGroupVersionKind(providerGVK)
+	if err := r.Get(ctx, name, "", provider); err != nil {
+		return "", err
+	}
+	revision, _, err := unstructured.NestedString(provider.Object, "status", "currentRevision")
+	if err != nil {
+		return "", err
+	}
+	if revision == "" {
+		return "", fmt.Errorf("provider %s has no current revision yet", name)
+	}
+	return revision, nil
+}
+
+// Delete deletes the provider in the foreground, i.e. together with its revisions and their controller deployments and
+// CRDs, and waits until it is gone. It fails if the CRDs are blocked by managed resources, which can't be deleted anymore.
+func Delete(ctx context.Context, r *resources.Resources, name string, opts ...wait.Option) error {
+	provider := &unstructured.Unstructured{}
+	provider.SetGroupVersionKind(providerGVK)
+	provider.SetName(name)
+	err := r.Delete(ctx, provider, resources.WithDeletePropagation(string(metav1.DeletePropagationForeground)))
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := wait.For(conditions.New(r).ResourceDeleted(provider), opts...); err != nil {
+		return fmt.Errorf("provider %s was not deleted: %w", name, err)
+	}
+	return nil
+}
+
+// Deployment returns the controller deployment of the current revision of the provider, owned by the revision
+func Deployment(ctx context.Context, r *resources.Resources, name string) (*appsv1.Deployment, error) {
+	revision, err := CurrentRevision(ctx, r, name)
+	if err != nil {
+		return nil, err
+	}
+	namespaced, err := namespacedClient(r)
+	if err != nil {
+		return nil, err
+	}
+	deployments := &appsv1.DeploymentList{}
+	if err := namespaced.List(ctx, deployments); err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		if ownedByRevision(&deployments.Items[i], revision) {
+			return &deployments.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("provider %s has no deployment of revision %s", name, revision)
+}
+
+// Pods returns the controller pods of the current revision of the provider, selected by its deployment
+func Pods(ctx context.Context, r *resources.Resources, name string) ([]corev1.Pod, error) {
+	deployment, err := Deployment(ctx, r, name)
+	if err != nil {
+		return nil, err
+	}
+	return deploymentPods(ctx, r, deployment)
+}
+
+// DeletePods deletes the controller pods of the current revision of the provider, which restarts the provider,
+// and returns the deleted pods
+func DeletePods(ctx context.Context, r *resources.Resources, name string) ([]corev1.Pod, error) {
+	pods, err := Pods(ctx, r, name)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods {
+		if err := r.Delete(ctx, &pods[i]); err != nil && !apierrors.IsNotFound(err) {
+			return pods[:i], err
+		}
+	}
+	return pods, nil
+}
+
+// WaitForReplacement waits until the deployment of the provider has as many ready pods as replicas,
+// none of which is one of the replaced pods
+func WaitForReplacement(ctx context.Context, r *resources.Resources, name string, replaced []corev1.Pod, opts ...wait.Option) error {
+	return wait.For(func(ctx context.Context) (bool, error) {
+		deployment, err := Deployment(ctx, r, name)
+		if err != nil {
+			return false, nil
+		}
+		pods, err := deploymentPods(ctx, r, deployment)
+		if err != nil {
+			return false, nil
+		}
+		return replacedPodsReady(pods, replaced, replicas(deployment)), nil
+	}, opts...)
+}
+
+func deploymentPods(ctx context.Context, r *resources.Resources, deployment *appsv1.Deployment) ([]corev1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of deployment %s: %w", deployment.Name, err)
+	}
+	namespaced, err := namespacedClient(r)
+	if err != nil {
+		return nil, err
+	}
+	pods := &corev1.PodList{}
+	if err := namespaced.List(ctx, pods, resources.WithLabelSelector(selector.String())); err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// namespacedClient returns a separate client, which avoids scoping the given one to the crossplane namespace
+func namespacedClient(r *resources.Resources) (*resources.Resources, error) {
+	namespaced, err := resources.New(r.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+	return namespaced.WithNamespace(Namespace), nil
+}
+
+func ownedByRevision(deployment *appsv1.Deployment, revision string) bool {
+	for _, owner := range deployment.OwnerReferences {
+		if owner.Kind == "ProviderRevision" && owner.Name == revision {
+			return true
+		}
+	}
+	return false
+}
+
+func replicas(deployment *appsv1.Deployment) int {
+	if deployment.Spec.Replicas == nil {
+		return 1
+	}
+	return int(*deployment.Spec.Replicas)
+}
+
+// replacedPodsReady returns if at least the given number of pods are ready and not being deleted,
+// without counting the replaced pods
+func replacedPodsReady(pods []corev1.Pod, replaced []corev1.Pod, replicas int) bool {
+	old := map[types.UID]bool{}
+	for _, pod := range replaced {
+		old[pod.UID] = true
+	}
+	ready := 0
+	for _, pod := range pods {
+		if old[pod.UID] || pod.DeletionTimestamp != nil {
+			continue
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				ready++
+			}
+		}
+	}
+	return ready >= replicas
+}
@@ -0,0 +1,46 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+)
+
+func TestOwnedByRevision(t *testing.T) {
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{
+		{Kind: "ProviderRevision", Name: "provider-nop-1234"},
+	}}}
+	require.True(t, ownedByRevision(deployment, "provider-nop-1234"))
+	require.False(t, ownedByRevision(deployment, "provider-nop-5678"))
+	require.False(t, ownedByRevision(&appsv1.Deployment{}, "provider-nop-1234"))
+}
+
+func TestReplicas(t *testing.T) {
+	require.Equal(t, 1, replicas(&appsv1.Deployment{}))
+	require.Equal(t, 2, replicas(&appsv1.Deployment{Spec: appsv1.DeploymentSpec{Replicas: ptr.To[int32](2)}}))
+}
+
+func TestReplacedPodsReady(t *testing.T) {
+	pod := func(uid string, ready bool, deleting bool) corev1.Pod {
+		p := corev1.Pod{ObjectMeta: metav1.ObjectMeta{UID: types.UID(uid)}}
+		if ready {
+			p.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		}
+		if deleting {
+			p.DeletionTimestamp = &metav1.Time{}
+		}
+		return p
+	}
+	replaced := []corev1.Pod{pod("old", true, false)}
+
+	require.False(t, replacedPodsReady([]corev1.Pod{pod("old", true, true)}, replaced, 1), "only the replaced pod")
+	require.False(t, replacedPodsReady([]corev1.Pod{pod("old", true, false)}, replaced, 1), "replaced pod still ready")
+	require.False(t, replacedPodsReady([]corev1.Pod{pod("new", false, false)}, replaced, 1), "new pod not ready")
+	require.True(t, replacedPodsReady([]corev1.Pod{pod("old", true, true), pod("new", true, false)}, replaced, 1))
+	require.False(t, replacedPodsReady([]corev1.Pod{pod("new", true, false)}, replaced, 2), "fewer ready pods than replicas")
+}
@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
+)
+
+var podMetricsGVK = schema.GroupVersionKind{Group: "metrics.k8s.io", Version: "v1beta1", Kind: "PodMetrics"}
+
+// Usage is the CPU and memory usage of the provider pods, summed up over all pods and containers per sample
+type Usage struct {
+	// Samples is the number of samples taken, 0 if the metrics API is not available
+	Samples        int
+	MaxCPUMillis   int64
+	AvgCPUMillis   int64
+	MaxMemoryBytes int64
+	AvgMemoryBytes int64
+	sumCPUMillis   int64
+	sumMemoryBytes int64
+}
+
+// String returns a human-readable representation of the usage
+func (u Usage) String() string {
+	if u.Samples == 0 {
+		return "no usage samples, is the metrics API available?"
+	}
+	return fmt.Sprintf("cpu max %dm avg %dm, memory max %dMi avg %dMi (%d samples)",
+		u.MaxCPUMillis, u.AvgCPUMillis, u.MaxMemoryBytes/1024/1024, u.AvgMemoryBytes/1024/1024, u.Samples)
+}
+
+func (u *Usage) add(cpuMillis int64, memoryBytes int64) {
+	u.Samples++
+	u.sumCPUMillis += cpuMillis
+	u.sumMemoryBytes += memoryBytes
+	u.MaxCPUMillis = max(u.MaxCPUMillis, cpuMillis)
+	u.MaxMemoryBytes = max(u.MaxMemoryBytes, memoryBytes)
+	u.AvgCPUMillis = u.sumCPUMillis / int64(u.Samples)
+	u.AvgMemoryBytes = u.sumMemoryBytes / int64(u.Samples)
+}
+
+// UsageSampler samples the CPU and memory usage of the provider pods from the metrics API (metrics.k8s.io),
+// e.g. provided by metrics-server
+type UsageSampler struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu    sync.Mutex
+	usage Usage
+}
+
+// SampleUsage starts sampling the usage of the provider pods in the given interval until Stop is called.
+// If the metrics API is not available, no samples are taken.
+func SampleUsage(ctx context.Context, r *resources.Resources, providerName string, interval time.Duration) *UsageSampler {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &UsageSampler{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			cpu, memory, err := podUsage(ctx, r, providerName)
+			switch {
+			case meta.IsNoMatchError(err):
+				klog.V(4).Infof("Not sampling usage of provider %s, the metrics API is not available", providerName)
+				return
+			case err != nil:
+				klog.V(4).Infof("Could not sample usage of provider %s: %v", providerName, err)
+			default:
+				s.mu.Lock()
+				s.usage.add(cpu, memory)
+				s.mu.Unlock()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+// Stop stops sampling and returns the usage
+func (s *UsageSampler) Stop() Usage {
+	s.cancel()
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usage
+}
+
+func podUsage(ctx context.Context, r *resources.Resources, providerName string) (int64, int64, error) {
+	pods, err := Pods(ctx, r, providerName)
+	if err != nil {
+		return 0, 0, err
+	}
+	var cpuMillis, memoryBytes int64
+	for _, pod := range pods {
+		metrics := &unstructured.Unstructured{}
+		metrics.SetGroupVersionKind(podMetricsGVK)
+		if err := r.Get(ctx, pod.Name, pod.Namespace, metrics); err != nil {
+			return 0, 0, err
+		}
+		cpu, memory, err := ContainerUsage(metrics)
+		if err != nil {
+			return 0, 0, err
+		}
+		cpuMillis += cpu
+		memoryBytes += memory
+	}
+	return cpuMillis, memoryBytes, nil
+}
+
+// ContainerUsage sums up the CPU (in millicores) and memory (in bytes) usage of all containers of a PodMetrics object
+func ContainerUsage(metrics *unstructured.Unstructured) (int64, int64, error) {
+	containers, _, err := unstructured.NestedSlice(metrics.Object, "containers")
+	if err != nil {
+		return 0, 0, err
+	}
+	var cpuMillis, memoryBytes int64
+	for _, c := range containers {
+		container, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		usage, _, _ := unstructured.NestedStringMap(container, "usage")
+		if cpu, ok := usage["cpu"]; ok {
+			q, err := resource.ParseQuantity(cpu)
+			if err != nil {
+				return 0, 0, err
+			}
+			cpuMillis += q.MilliValue()
+		}
+		if memory, ok := usage["memory"]; ok {
+			q, err := resource.ParseQuantity(memory)
+			if err != nil {
+				return 0, 0, err
+			}
+			memoryBytes += q.Value()
+		}
+	}
+	return cpuMillis, memoryBytes, nil
+}
@@ -0,0 +1,29 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestContainerUsage(t *testing.T) {
+	metrics := &unstructured.Unstructured{Object: map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{"name": "package-runtime", "usage": map[string]interface{}{"cpu": "250m", "memory": "64Mi"}},
+			map[string]interface{}{"name": "sidecar", "usage": map[string]interface{}{"cpu": "1", "memory": "1Mi"}},
+		},
+	}}
+	cpu, memory, err := ContainerUsage(metrics)
+	require.NoError(t, err)
+	require.Equal(t, int64(1250), cpu)
+	require.Equal(t, int64(65*1024*1024), memory)
+}
+
+func TestUsage(t *testing.T) {
+	usage := Usage{}
+	require.Equal(t, "no usage samples, is the metrics API available?", usage.String())
+	usage.add(100, 10*1024*1024)
+	usage.add(300, 30*1024*1024)
+	require.Equal(t, "cpu max 300m avg 200m, memory max 30Mi avg 20Mi (2 samples)", usage.String())
+}
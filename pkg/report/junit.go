@@ -0,0 +1,93 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	ClassName  string          `xml:"classname,attr"`
+	Time       string          `xml:"time,attr"`
+	Failure    *junitFailure   `xml:"failure,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junit renders the summary as JUnit XML, with one test case per feature
+func junit(summary Summary) ([]byte, error) {
+	suite := junitTestSuite{
+		Name: "xp-testing",
+		Properties: []junitProperty{
+			{Name: "provider-version", Value: summary.ProviderVersion},
+			{Name: "crossplane-version", Value: summary.CrossplaneVersion},
+		},
+	}
+	var total time.Duration
+	for i, feature := range summary.Features {
+		if i == 0 {
+			suite.Timestamp = feature.Start.UTC().Format(time.RFC3339)
+		}
+		total += feature.Duration
+		testCase := junitTestCase{
+			Name:      feature.Name,
+			ClassName: strings.Join(feature.Kinds, ","),
+			Time:      seconds(feature.Duration),
+		}
+		if feature.DeleteDuration != nil {
+			testCase.Properties = append(testCase.Properties, junitProperty{Name: "time-to-delete", Value: seconds(*feature.DeleteDuration)})
+		}
+		out := strings.Builder{}
+		for _, object := range feature.Objects {
+			out.WriteString(fmt.Sprintf("%s synced after %s, ready after %s\n", object.Identifier, object.TimeToSynced, object.TimeToReady))
+		}
+		for _, deletion := range feature.Deletions {
+			out.WriteString(fmt.Sprintf("%s deleted after %s\n", deletion.Identifier, deletion.TimeToDelete))
+		}
+		testCase.SystemOut = out.String()
+		if feature.Failed {
+			suite.Failures++
+			testCase.Failure = &junitFailure{Message: "feature failed", Text: strings.Join(feature.Failures, "\n")}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Tests = len(suite.TestCases)
+	suite.Time = seconds(total)
+
+	out, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
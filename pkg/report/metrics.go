@@ -0,0 +1,156 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// Percentiles aggregates durations
+type Percentiles struct {
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// KindMetrics are the aggregated durations of all objects of a kind
+type KindMetrics struct {
+	TimeToSynced Percentiles `json:"timeToSynced"`
+	TimeToReady  Percentiles `json:"timeToReady"`
+	TimeToDelete Percentiles `json:"timeToDelete"`
+}
+
+// Metrics maps kinds to their aggregated durations, it is the format of baseline files as well
+type Metrics map[string]KindMetrics
+
+// Aggregate computes the metrics per kind over all objects of the features
+func Aggregate(features []*Feature) Metrics {
+	synced := map[string][]time.Duration{}
+	ready := map[string][]time.Duration{}
+	deleted := map[string][]time.Duration{}
+	for _, feature := range features {
+		for _, object := range feature.Objects {
+			synced[object.Kind] = append(synced[object.Kind], object.TimeToSynced)
+			ready[object.Kind] = append(ready[object.Kind], object.TimeToReady)
+		}
+		for _, deletion := range feature.Deletions {
+			deleted[deletion.Kind] = append(deleted[deletion.Kind], deletion.TimeToDelete)
+		}
+	}
+	metrics := Metrics{}
+	for _, kind := range lo.Union(lo.Keys(ready), lo.Keys(deleted)) {
+		metrics[kind] = KindMetrics{
+			TimeToSynced: percentiles(synced[kind]),
+			TimeToReady:  percentiles(ready[kind]),
+			TimeToDelete: percentiles(deleted[kind]),
+		}
+	}
+	return metrics
+}
+
+// percentiles computes the percentiles with the nearest-rank method
+func percentiles(durations []time.Duration) Percentiles {
+	if len(durations) == 0 {
+		return Percentiles{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := func(p float64) time.Duration {
+		return sorted[int(math.Ceil(p/100*float64(len(sorted))))-1]
+	}
+	return Percentiles{
+		Count: len(sorted),
+		P50:   rank(50),
+		P90:   rank(90),
+		P99:   rank(99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// Regression is a metric exceeding its baseline by more than the tolerance
+type Regression struct {
+	Kind       string        `json:"kind"`
+	Metric     string        `json:"metric"`
+	Percentile string        `json:"percentile"`
+	Baseline   time.Duration `json:"baseline"`
+	Current    time.Duration `json:"current"`
+}
+
+// String returns a human-readable representation of the regression
+func (r Regression) String() string {
+	return fmt.Sprintf("%s %s %s: %s exceeds baseline %s", r.Kind, r.Metric, r.Percentile, r.Current, r.Baseline)
+}
+
+// Compare returns all percentiles of the current metrics exceeding the baseline by more than the tolerance,
+// e.g. a tolerance of 0.2 allows 20% slower durations. Kinds and metrics missing in either are not compared.
+func Compare(current Metrics, baseline Metrics, tolerance float64) []Regression {
+	var regressions []Regression
+	kinds := make([]string, 0, len(current))
+	for kind := range current {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		base, ok := baseline[kind]
+		if !ok {
+			continue
+		}
+		cur := current[kind]
+		for _, metric := range []struct {
+			name     string
+			current  Percentiles
+			baseline Percentiles
+		}{
+			{"timeToSynced", cur.TimeToSynced, base.TimeToSynced},
+			{"timeToReady", cur.TimeToReady, base.TimeToReady},
+			{"timeToDelete", cur.TimeToDelete, base.TimeToDelete},
+		} {
+			if metric.current.Count == 0 || metric.baseline.Count == 0 {
+				continue
+			}
+			for _, p := range []struct {
+				name     string
+				current  time.Duration
+				baseline time.Duration
+			}{
+				{"p50", metric.current.P50, metric.baseline.P50},
+				{"p90", metric.current.P90, metric.baseline.P90},
+				{"p99", metric.current.P99, metric.baseline.P99},
+			} {
+				if float64(p.current) > float64(p.baseline)*(1+tolerance) {
+					regressions = append(regressions, Regression{Kind: kind, Metric: metric.name, Percentile: p.name, Baseline: p.baseline, Current: p.current})
+				}
+			}
+		}
+	}
+	return regressions
+}
+
+// LoadBaseline reads metrics from a JSON file, as written by Metrics.Save
+func LoadBaseline(path string) (Metrics, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	metrics := Metrics{}
+	if err := json.Unmarshal(raw, &metrics); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline %s: %w", path, err)
+	}
+	return metrics, nil
+}
+
+// Save writes the metrics to a JSON file, to be used as baseline of later runs
+func (m Metrics) Save(path string) error {
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0o644)
+}
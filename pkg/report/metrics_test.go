@@ -0,0 +1,72 @@
+package report
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_percentiles(t *testing.T) {
+	var durations []time.Duration
+	for i := 100; i > 0; i-- {
+		durations = append(durations, time.Duration(i)*time.Second)
+	}
+	require.Equal(t, Percentiles{Count: 100, P50: 50 * time.Second, P90: 90 * time.Second, P99: 99 * time.Second, Max: 100 * time.Second}, percentiles(durations))
+	require.Equal(t, Percentiles{Count: 1, P50: time.Second, P90: time.Second, P99: time.Second, Max: time.Second}, percentiles([]time.Duration{time.Second}))
+	require.Equal(t, Percentiles{}, percentiles(nil))
+}
+
+func TestAggregate(t *testing.T) {
+	metrics := Aggregate([]*Feature{
+		{Objects: []Object{
+			{Kind: "NopResource", TimeToSynced: time.Second, TimeToReady: 2 * time.Second},
+			{Kind: "NopResource", TimeToSynced: 3 * time.Second, TimeToReady: 4 * time.Second},
+		}},
+		{Deletions: []Deletion{{Kind: "Other", TimeToDelete: 5 * time.Second}}},
+	})
+	require.Len(t, metrics, 2)
+	require.Equal(t, 2, metrics["NopResource"].TimeToReady.Count)
+	require.Equal(t, 2*time.Second, metrics["NopResource"].TimeToReady.P50)
+	require.Equal(t, 3*time.Second, metrics["NopResource"].TimeToSynced.Max)
+	require.Equal(t, 0, metrics["NopResource"].TimeToDelete.Count)
+	require.Equal(t, 5*time.Second, metrics["Other"].TimeToDelete.P99)
+}
+
+func TestCompare(t *testing.T) {
+	baseline := Metrics{"NopResource": {
+		TimeToReady:  Percentiles{Count: 10, P50: 10 * time.Second, P90: 20 * time.Second, P99: 30 * time.Second},
+		TimeToDelete: Percentiles{Count: 10, P50: 10 * time.Second, P90: 10 * time.Second, P99: 10 * time.Second},
+	}}
+	current := Metrics{
+		"NopResource": {
+			TimeToReady: Percentiles{Count: 10, P50: 11 * time.Second, P90: 25 * time.Second, P99: 30 * time.Second},
+			// not recorded in this run
+			TimeToDelete: Percentiles{},
+		},
+		"Unknown": {TimeToReady: Percentiles{Count: 1, P50: time.Hour}},
+	}
+	regressions := Compare(current, baseline, 0.2)
+	require.Equal(t, []Regression{{Kind: "NopResource", Metric: "timeToReady", Percentile: "p90", Baseline: 20 * time.Second, Current: 25 * time.Second}}, regressions)
+	require.Equal(t, "NopResource timeToReady p90: 25s exceeds baseline 20s", regressions[0].String())
+}
+
+func TestReporter_CompareTo(t *testing.T) {
+	dir := t.TempDir()
+	baseline := filepath.Join(dir, "baseline.json")
+	r := NewReporter("v0.2.1", "1.20.0").CompareTo(baseline, 0.1)
+	r.features = []*Feature{{Name: "Nop", Objects: []Object{{Kind: "NopResource", TimeToReady: 10 * time.Second}}}}
+
+	require.NoError(t, r.Write(dir), "missing baseline is created")
+	stored, err := LoadBaseline(baseline)
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Second, stored["NopResource"].TimeToReady.P50)
+
+	require.NoError(t, r.Write(dir))
+
+	r.features[0].Objects[0].TimeToReady = 20 * time.Second
+	err = r.Write(dir)
+	require.ErrorContains(t, err, "3 metric(s) regressed compared to baseline")
+	require.ErrorContains(t, err, "NopResource timeToReady p50: 20s exceeds baseline 10s")
+}
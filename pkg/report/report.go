@@ -0,0 +1,310 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/pkg/env"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+)
+
+const (
+	// JUnitFile is the name of the JUnit XML report written by Reporter.Write
+	JUnitFile = "junit.xml"
+	// JSONFile is the name of the JSON report written by Reporter.Write
+	JSONFile = "report.json"
+)
+
+type featureKey struct{}
+
+// Reporter records the features of a test suite and writes them as JUnit XML and JSON report
+type Reporter struct {
+	ProviderVersion   string
+	CrossplaneVersion string
+	now               func() time.Time
+
+	baseline  string
+	tolerance float64
+
+	mu       sync.Mutex
+	features []*Feature
+}
+
+// NewReporter creates a reporter for a test suite of the given provider and crossplane version
+func NewReporter(providerVersion string, crossplaneVersion string) *Reporter {
+	return &Reporter{ProviderVersion: providerVersion, CrossplaneVersion: crossplaneVersion, now: time.Now}
+}
+
+// Feature is the record of a single test feature
+type Feature struct {
+	Name     string        `json:"name"`
+	Kinds    []string      `json:"kinds"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Failed   bool          `json:"failed"`
+	// Failures are the messages recorded via MarkFailed
+	Failures []string `json:"failures,omitempty"`
+	// Objects are the objects that became ready during the feature
+	Objects []Object `json:"objects,omitempty"`
+	// DeleteDuration is the time it took to delete all resources of the feature
+	DeleteDuration *time.Duration `json:"deleteDuration,omitempty"`
+	// Deletions are the objects deleted during the feature
+	Deletions []Deletion `json:"deletions,omitempty"`
+
+	mu sync.Mutex
+	// failedBefore records if the test already failed before the feature, see Reporter.EndFeature
+	failedBefore bool
+}
+
+// Object is the record of a single object of a feature
+type Object struct {
+	Identifier string `json:"identifier"`
+	Kind       string `json:"kind"`
+	// TimeToSynced is the time between the creation of the object and its Synced condition becoming True
+	TimeToSynced time.Duration `json:"timeToSynced"`
+	// TimeToReady is the time between the creation of the object and its Ready condition becoming True
+	TimeToReady time.Duration `json:"timeToReady"`
+}
+
+// Deletion is the record of a single object deleted during a feature
+type Deletion struct {
+	Identifier string `json:"identifier"`
+	Kind       string `json:"kind"`
+	// TimeToDelete is the time between the deletion request and the object being gone
+	TimeToDelete time.Duration `json:"timeToDelete"`
+}
+
+// Summary is the JSON representation of a report, all durations are in nanoseconds
+type Summary struct {
+	ProviderVersion   string     `json:"providerVersion"`
+	CrossplaneVersion string     `json:"crossplaneVersion"`
+	Features          []*Feature `json:"features"`
+	// Metrics are the aggregated durations of all features per kind
+	Metrics Metrics `json:"metrics,omitempty"`
+	// Regressions are the metrics exceeding the baseline, see Reporter.CompareTo
+	Regressions []Regression `json:"regressions,omitempty"`
+}
+
+// WithFeature stores the feature record in the context, so steps of the feature can add to it
+func WithFeature(ctx context.Context, feature *Feature) context.Context {
+	return context.WithValue(ctx, featureKey{}, feature)
+}
+
+// FeatureFromContext returns the record of the currently running feature, if a reporter is in use
+func FeatureFromContext(ctx context.Context) (*Feature, bool) {
+	feature, ok := ctx.Value(featureKey{}).(*Feature)
+	return feature, ok
+}
+
+// StartFeature returns a feature hook, which starts recording each feature.
+// It is meant to be registered via env.Environment.BeforeEachFeature.
+func (r *Reporter) StartFeature() env.FeatureFunc {
+	return func(ctx context.Context, _ *envconf.Config, t *testing.T, feature features.Feature) (context.Context, error) {
+		record := &Feature{Name: feature.Name(), Start: r.now(), failedBefore: t.Failed()}
+		r.mu.Lock()
+		r.features = append(r.features, record)
+		r.mu.Unlock()
+		return WithFeature(ctx, record), nil
+	}
+}
+
+// EndFeature returns a feature hook, which finishes recording each feature.
+// It is meant to be registered via env.Environment.AfterEachFeature.
+func (r *Reporter) EndFeature() env.FeatureFunc {
+	return func(ctx context.Context, _ *envconf.Config, t *testing.T, _ features.Feature) (context.Context, error) {
+		record, ok := FeatureFromContext(ctx)
+		if !ok {
+			return ctx, nil
+		}
+		record.mu.Lock()
+		defer record.mu.Unlock()
+		record.Duration = r.now().Sub(record.Start)
+		// the hooks only get the test of all features, once an earlier feature failed,
+		// only failures recorded via MarkFailed are known
+		if t.Failed() && !record.failedBefore {
+			record.Failed = true
+		}
+		return ctx, nil
+	}
+}
+
+// RecordReady records the time to Ready of the given objects for the feature in the context, if any
+func RecordReady(ctx context.Context, objects ...*unstructured.Unstructured) {
+	feature, ok := FeatureFromContext(ctx)
+	if !ok {
+		return
+	}
+	feature.mu.Lock()
+	defer feature.mu.Unlock()
+	for _, object := range objects {
+		kind := object.GetKind()
+		feature.addKind(kind)
+		timeToReady, ok := TimeToReady(object)
+		if !ok {
+			continue
+		}
+		timeToSynced, _ := TimeToSynced(object)
+		feature.Objects = append(feature.Objects, Object{
+			Identifier:   object.GetObjectKind().GroupVersionKind().String() + "/" + object.GetName(),
+			Kind:         kind,
+			TimeToSynced: timeToSynced,
+			TimeToReady:  timeToReady,
+		})
+	}
+}
+
+// RecordDeleted records the time it took to delete the resources of the feature in the context, if any,
+// in total and per object
+func RecordDeleted(ctx context.Context, duration time.Duration, deletions ...Deletion) {
+	feature, ok := FeatureFromContext(ctx)
+	if !ok {
+		return
+	}
+	feature.mu.Lock()
+	defer feature.mu.Unlock()
+	for _, deletion := range deletions {
+		feature.addKind(deletion.Kind)
+	}
+	feature.Deletions = append(feature.Deletions, deletions...)
+	feature.DeleteDuration = &duration
+}
+
+// MarkFailed marks the feature in the context as failed, if any
+func MarkFailed(ctx context.Context, message string) {
+	feature, ok := FeatureFromContext(ctx)
+	if !ok {
+		return
+	}
+	feature.mu.Lock()
+	defer feature.mu.Unlock()
+	feature.Failed = true
+	feature.Failures = append(feature.Failures, message)
+}
+
+func (f *Feature) addKind(kind string) {
+	if kind != "" && !lo.Contains(f.Kinds, kind) {
+		f.Kinds = append(f.Kinds, kind)
+		sort.Strings(f.Kinds)
+	}
+}
+
+// TimeToReady returns the time between creation of the object and the last transition of its Ready condition,
+// if the object is ready
+func TimeToReady(object *unstructured.Unstructured) (time.Duration, bool) {
+	return timeToCondition(object, "Ready")
+}
+
+// TimeToSynced returns the time between creation of the object and the last transition of its Synced condition,
+// if the object is synced
+func TimeToSynced(object *unstructured.Unstructured) (time.Duration, bool) {
+	return timeToCondition(object, "Synced")
+}
+
+func timeToCondition(object *unstructured.Unstructured, conditionType string) (time.Duration, bool) {
+	conditions, _, _ := unstructured.NestedSlice(object.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != conditionType || condition["status"] != "True" {
+			continue
+		}
+		transition, _ := condition["lastTransitionTime"].(string)
+		readyAt, err := time.Parse(time.RFC3339, transition)
+		if err != nil {
+			return 0, false
+		}
+		return readyAt.Sub(object.GetCreationTimestamp().Time), true
+	}
+	return 0, false
+}
+
+// CompareTo configures the reporter to compare the metrics against the baseline file with the given tolerance
+// when writing the report, e.g. a tolerance of 0.2 allows 20% slower durations.
+// If the baseline file doesn't exist yet, it is created from the current metrics.
+func (r *Reporter) CompareTo(baseline string, tolerance float64) *Reporter {
+	r.baseline = baseline
+	r.tolerance = tolerance
+	return r
+}
+
+// Summary returns the report of all recorded features
+func (r *Reporter) Summary() Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Summary{
+		ProviderVersion:   r.ProviderVersion,
+		CrossplaneVersion: r.CrossplaneVersion,
+		Features:          append([]*Feature(nil), r.features...),
+	}
+}
+
+// Write writes the JUnit XML and the JSON report into the directory.
+// If a baseline is configured, an error is returned if any metric regressed, see CompareTo.
+func (r *Reporter) Write(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	summary := r.Summary()
+	for _, feature := range summary.Features {
+		feature.mu.Lock()
+		defer feature.mu.Unlock()
+	}
+	summary.Metrics = Aggregate(summary.Features)
+	if err := r.compare(&summary); err != nil {
+		return err
+	}
+	jsonReport, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, JSONFile), jsonReport, 0o644); err != nil {
+		return err
+	}
+	junitReport, err := junit(summary)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, JUnitFile), junitReport, 0o644); err != nil {
+		return err
+	}
+	if len(summary.Regressions) > 0 {
+		lines := lo.Map(summary.Regressions, func(regression Regression, _ int) string { return regression.String() })
+		return fmt.Errorf("%d metric(s) regressed compared to baseline %s:\n%s", len(lines), r.baseline, strings.Join(lines, "\n"))
+	}
+	return nil
+}
+
+func (r *Reporter) compare(summary *Summary) error {
+	if r.baseline == "" {
+		return nil
+	}
+	baseline, err := LoadBaseline(r.baseline)
+	if os.IsNotExist(err) {
+		klog.Infof("Baseline %s doesn't exist, creating it from the current metrics", r.baseline)
+		return summary.Metrics.Save(r.baseline)
+	}
+	if err != nil {
+		return err
+	}
+	summary.Regressions = Compare(summary.Metrics, baseline, r.tolerance)
+	return nil
+}
+
+// WriteReport returns an env.Func writing the report into the directory, meant to be registered via env.Environment.Finish
+func (r *Reporter) WriteReport(dir string) env.Func {
+	return func(ctx context.Context, _ *envconf.Config) (context.Context, error) {
+		klog.Infof("Writing test report to %s", dir)
+		return ctx, r.Write(dir)
+	}
+}
@@ -0,0 +1,87 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+)
+
+func readyObject(name string, created time.Time, ready time.Time) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "Synced", "status": "True", "lastTransitionTime": created.Format(time.RFC3339)},
+			map[string]interface{}{"type": "Ready", "status": "True", "lastTransitionTime": ready.Format(time.RFC3339)},
+		}},
+	}}
+	obj.SetAPIVersion("nop.crossplane.io/v1alpha1")
+	obj.SetKind("NopResource")
+	obj.SetName(name)
+	obj.SetCreationTimestamp(metav1.NewTime(created))
+	return obj
+}
+
+func TestTimeToReady(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeToReady, ok := TimeToReady(readyObject("example", created, created.Add(42*time.Second)))
+	require.True(t, ok)
+	require.Equal(t, 42*time.Second, timeToReady)
+
+	_, ok = TimeToReady(&unstructured.Unstructured{Object: map[string]interface{}{}})
+	require.False(t, ok)
+}
+
+func TestReporter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	r := NewReporter("v0.2.1", "1.20.0")
+	r.now = func() time.Time { return now }
+
+	ctx, err := r.StartFeature()(context.Background(), nil, t, features.New("Nop").Feature())
+	require.NoError(t, err)
+	RecordReady(ctx, readyObject("example", start, start.Add(10*time.Second)))
+	RecordDeleted(ctx, 5*time.Second, Deletion{Identifier: "NopResource/example", Kind: "NopResource", TimeToDelete: 4 * time.Second})
+	now = now.Add(time.Minute)
+	_, err = r.EndFeature()(ctx, nil, t, features.New("Nop").Feature())
+	require.NoError(t, err)
+
+	ctx, err = r.StartFeature()(context.Background(), nil, t, features.New("Other").Feature())
+	require.NoError(t, err)
+	MarkFailed(ctx, "1 resource(s) did not become synced and ready")
+	_, err = r.EndFeature()(ctx, nil, t, features.New("Other").Feature())
+	require.NoError(t, err)
+
+	// without a reporter, recording is a no-op
+	RecordReady(context.Background(), readyObject("example", start, start))
+
+	dir := t.TempDir()
+	require.NoError(t, r.Write(dir))
+
+	raw, err := os.ReadFile(filepath.Join(dir, JSONFile))
+	require.NoError(t, err)
+	summary := Summary{}
+	require.NoError(t, json.Unmarshal(raw, &summary))
+	require.Equal(t, "v0.2.1", summary.ProviderVersion)
+	require.Len(t, summary.Features, 2)
+	require.Equal(t, []string{"NopResource"}, summary.Features[0].Kinds)
+	require.Equal(t, time.Minute, summary.Features[0].Duration)
+	require.Equal(t, 5*time.Second, *summary.Features[0].DeleteDuration)
+	require.Equal(t, []Object{{Identifier: "nop.crossplane.io/v1alpha1, Kind=NopResource/example", Kind: "NopResource", TimeToReady: 10 * time.Second}}, summary.Features[0].Objects)
+	require.False(t, summary.Features[0].Failed)
+	require.True(t, summary.Features[1].Failed)
+
+	junitReport, err := os.ReadFile(filepath.Join(dir, JUnitFile))
+	require.NoError(t, err)
+	require.Contains(t, string(junitReport), `<testsuite name="xp-testing" tests="2" failures="1" time="60.000" timestamp="2024-01-01T00:00:00Z">`)
+	require.Contains(t, string(junitReport), `<property name="crossplane-version" value="1.20.0"></property>`)
+	require.Contains(t, string(junitReport), `<testcase name="Nop" classname="NopResource" time="60.000">`)
+	require.Contains(t, string(junitReport), `<property name="time-to-delete" value="5.000"></property>`)
+	require.Contains(t, string(junitReport), `<failure message="feature failed">1 resource(s) did not become synced and ready</failure>`)
+}
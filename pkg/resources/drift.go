@@ -0,0 +1,103 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+)
+
+const defaultDriftTimeout = 5 * time.Minute
+
+// DriftTest configures a drift detection step, which modifies the external state of managed resources
+// and expects the provider to correct it, see ResourceTestConfig.AssessDrift
+type DriftTest struct {
+	// Drift modifies the external system, or the spec of a managed resource, after the resources became ready
+	Drift func(ctx context.Context, cfg *envconf.Config) error
+	// Observe returns true once the external system is back in the desired state, it is polled until it does.
+	// It is required, since the managed resources stay synced and ready until the provider observes the drift,
+	// so their conditions alone can't tell if the drift was corrected.
+	Observe func(ctx context.Context, cfg *envconf.Config) (bool, error)
+	// Timeout for the drift to be corrected, defaults to 5 minutes
+	Timeout time.Duration
+	// Interval in which Observe is polled, defaults to the interval of wait.For
+	Interval time.Duration
+}
+
+// AssessDrift returns a step, which applies the drift and waits until it is corrected by the provider,
+// verified via DriftTest.Observe and the managed resources being synced and ready afterwards.
+// It is meant to run after AssessCreate, e.g. registered via WithDrift.
+func (r *ResourceTestConfig) AssessDrift(d DriftTest) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		timeout := d.Timeout
+		if timeout == 0 {
+			timeout = defaultDriftTimeout
+		}
+		opts := []wait.Option{wait.WithTimeout(timeout)}
+		if d.Interval > 0 {
+			opts = append(opts, wait.WithInterval(d.Interval))
+		}
+
+		if err := d.validate(); err != nil {
+			t.Fatalf("invalid drift test of %s: %v", r.Kind, err)
+		}
+		t.Logf("Drift %s", r.Kind)
+		if err := d.Drift(ctx, cfg); err != nil {
+			t.Fatalf("failed to drift %s: %v", r.Kind, err)
+		}
+		if err := wait.For(func(ctx context.Context) (bool, error) { return d.Observe(ctx, cfg) }, opts...); err != nil {
+			DumpManagedResources(ctx, t, cfg)
+			t.Fatalf("drift of %s was not corrected: %v", r.Kind, err)
+		}
+		if err := WaitForResourcesToBeSynced(ctx, cfg, r.ResourceDirectory, r.ObjFilterFunc, opts...); err != nil {
+			DumpManagedResources(ctx, t, cfg)
+			t.Fatal(err)
+		}
+		return ctx
+	}
+}
+
+func (d DriftTest) validate() error {
+	switch {
+	case d.Drift == nil:
+		return errors.New("the Drift func is required")
+	case d.Observe == nil:
+		return errors.New("the Observe func is required to verify the drift was corrected")
+	default:
+		return nil
+	}
+}
+
+// WithDrift registers a drift detection step as additional step, see AssessDrift
+func (r *ResourceTestConfig) WithDrift(name string, d DriftTest) *ResourceTestConfig {
+	if r.AdditionalSteps == nil {
+		r.AdditionalSteps = map[string]func(context.Context, *testing.T, *envconf.Config) context.Context{}
+	}
+	r.AdditionalSteps[name] = r.AssessDrift(d)
+	return r
+}
+
+// FeatureBuilder returns a feature builder, which creates the resources, runs the AdditionalSteps
+// in the order of their names and deletes the resources again
+func (r *ResourceTestConfig) FeatureBuilder() *features.FeatureBuilder {
+	builder := features.New(r.Kind).
+		WithLabel("kind", r.Kind).
+		Setup(r.Setup).
+		Assess("create", r.AssessCreate)
+	names := make([]string, 0, len(r.AdditionalSteps))
+	for name := range r.AdditionalSteps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		builder = builder.Assess(name, r.AdditionalSteps[name])
+	}
+	return builder.
+		Assess("delete", r.AssessDelete).
+		Teardown(r.Teardown)
+}
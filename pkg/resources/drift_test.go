@@ -0,0 +1,46 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/types"
+)
+
+func TestResourceTestConfig_FeatureBuilder(t *testing.T) {
+	noop := func(ctx context.Context, _ *testing.T, _ *envconf.Config) context.Context { return ctx }
+	r := NewResourceTestConfig(nil, "Nop")
+	r.AdditionalSteps = map[string]func(context.Context, *testing.T, *envconf.Config) context.Context{
+		"update":  noop,
+		"observe": noop,
+	}
+	r.WithDrift("drift", DriftTest{Drift: func(context.Context, *envconf.Config) error { return nil }})
+
+	feature := r.FeatureBuilder().Feature()
+	require.Equal(t, "Nop", feature.Name())
+	require.Equal(t, []string{"Nop"}, feature.Labels()["kind"])
+
+	var names []string
+	var levels []types.Level
+	for _, step := range feature.Steps() {
+		names = append(names, step.Name())
+		levels = append(levels, step.Level())
+	}
+	require.Equal(t, []string{"Nop-setup", "create", "drift", "observe", "update", "delete", "Nop-teardown"}, names)
+	require.Equal(t, []types.Level{types.LevelSetup, types.LevelAssess, types.LevelAssess, types.LevelAssess, types.LevelAssess, types.LevelAssess, types.LevelTeardown}, levels)
+}
+
+func TestResourceTestConfig_WithDrift(t *testing.T) {
+	r := NewResourceTestConfig(nil, "Nop").WithDrift("drift", DriftTest{})
+	require.Contains(t, r.AdditionalSteps, "drift")
+}
+
+func TestDriftTest_validate(t *testing.T) {
+	drift := func(context.Context, *envconf.Config) error { return nil }
+	observe := func(context.Context, *envconf.Config) (bool, error) { return true, nil }
+	require.EqualError(t, DriftTest{Observe: observe}.validate(), "the Drift func is required")
+	require.EqualError(t, DriftTest{Drift: drift}.validate(), "the Observe func is required to verify the drift was corrected")
+	require.NoError(t, DriftTest{Drift: drift, Observe: observe}.validate())
+}
@@ -0,0 +1,303 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apimachinerywait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/decoder"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+	"sigs.k8s.io/e2e-framework/pkg/types"
+)
+
+const (
+	// FeatureIDLabel labels the namespace and the objects imported during a feature, see IsolateFeature
+	FeatureIDLabel = "xp-testing.crossplane.io/feature-id"
+	// DefaultFeatureCleanupTimeout is the time CleanupFeature waits for the objects of a feature to be deleted
+	DefaultFeatureCleanupTimeout = 5 * time.Minute
+
+	featureCleanupInterval = 2 * time.Second
+)
+
+var nonDNSLabelChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+type featureScopeKey struct{}
+
+// FeatureScope identifies the objects of a feature, see IsolateFeature
+type FeatureScope struct {
+	// ID is the value of the FeatureIDLabel of the objects of the feature
+	ID string
+	// Namespace is the namespace namespaced objects of the feature are imported into
+	Namespace string
+
+	kinds *scopedKinds
+}
+
+// scopedKinds records the kinds of the objects MutateScope labelled in a feature, which CleanupFeature looks for
+type scopedKinds struct {
+	mu    sync.Mutex
+	kinds map[schema.GroupVersionKind]bool
+}
+
+func (k *scopedKinds) add(gvk schema.GroupVersionKind) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.kinds[gvk] = true
+}
+
+func (k *scopedKinds) list() []schema.GroupVersionKind {
+	if k == nil {
+		return nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return lo.Keys(k.kinds)
+}
+
+// WithFeatureScope returns a context with the scope of the current feature
+func WithFeatureScope(ctx context.Context, scope FeatureScope) context.Context {
+	if scope.kinds == nil {
+		scope.kinds = &scopedKinds{kinds: map[schema.GroupVersionKind]bool{}}
+	}
+	return context.WithValue(ctx, featureScopeKey{}, scope)
+}
+
+// FeatureScopeFromContext returns the scope of the current feature, if the feature is isolated
+func FeatureScopeFromContext(ctx context.Context) (FeatureScope, bool) {
+	scope, ok := ctx.Value(featureScopeKey{}).(FeatureScope)
+	return scope, ok
+}
+
+// Namespace returns the namespace of the current feature, or the namespace of the environment if the feature isn't isolated
+func Namespace(ctx context.Context, cfg *envconf.Config) string {
+	if scope, ok := FeatureScopeFromContext(ctx); ok {
+		return scope.Namespace
+	}
+	return cfg.Namespace()
+}
+
+// MutateScope returns a decoder option, which moves namespaced objects into Namespace and labels them with the
+// FeatureIDLabel of the current feature. Cluster scoped objects, e.g. crossplane v1 managed resources, are only labelled.
+func MutateScope(ctx context.Context, cfg *envconf.Config) decoder.DecodeOption {
+	return decoder.MutateOption(scoper(ctx, cfg))
+}
+
+func scoper(ctx context.Context, cfg *envconf.Config) func(obj k8s.Object) error {
+	return scopeFunc(ctx, restMapper(cfg), Namespace(ctx, cfg))
+}
+
+func scopeFunc(ctx context.Context, mapper meta.RESTMapper, namespace string) func(obj k8s.Object) error {
+	scope, isolated := FeatureScopeFromContext(ctx)
+	return func(obj k8s.Object) error {
+		if isNamespaced(mapper, obj.GetObjectKind().GroupVersionKind()) {
+			obj.SetNamespace(namespace)
+		} else {
+			obj.SetNamespace("")
+		}
+		if isolated {
+			if scope.kinds != nil {
+				scope.kinds.add(obj.GetObjectKind().GroupVersionKind())
+			}
+			labels := obj.GetLabels()
+			if labels == nil {
+				labels = map[string]string{}
+			}
+			labels[FeatureIDLabel] = scope.ID
+			obj.SetLabels(labels)
+		}
+		return nil
+	}
+}
+
+func restMapper(cfg *envconf.Config) meta.RESTMapper {
+	return cfg.Client().Resources().GetControllerRuntimeClient().RESTMapper()
+}
+
+// isNamespaced returns whether the kind is namespaced, unknown kinds are treated as namespaced
+func isNamespaced(mapper meta.RESTMapper, gvk schema.GroupVersionKind) bool {
+	if mapper == nil {
+		return true
+	}
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		klog.V(4).Infof("Could not determine the scope of %s, treating it as namespaced: %v", gvk, err)
+		return true
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace
+}
+
+// featureID returns a unique DNS label for the feature
+func featureID(name string) string {
+	prefix := strings.Trim(nonDNSLabelChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(prefix) > 40 {
+		prefix = strings.TrimRight(prefix[:40], "-")
+	}
+	if prefix == "" {
+		prefix = "feature"
+	}
+	return envconf.RandomName(prefix, len(prefix)+7)
+}
+
+// IsolateFeature is a BeforeEachFeature hook, which creates a namespace for the feature. Objects imported during the
+// feature are moved into it and labelled with its FeatureIDLabel, so CleanupFeature can delete them after the feature.
+func IsolateFeature(ctx context.Context, cfg *envconf.Config, _ *testing.T, feature features.Feature) (context.Context, error) {
+	id := featureID(feature.Name())
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: id, Labels: map[string]string{FeatureIDLabel: id}}}
+	if err := cfg.Client().Resources().Create(ctx, ns); err != nil {
+		return ctx, fmt.Errorf("failed to create namespace of feature %s: %w", feature.Name(), err)
+	}
+	klog.V(4).Infof("Isolating feature %s in namespace %s", feature.Name(), id)
+	return WithFeatureScope(ctx, FeatureScope{ID: id, Namespace: id}), nil
+}
+
+// CleanupFeature returns an AfterEachFeature hook, which deletes the namespace and the cluster scoped objects of a feature
+// isolated by IsolateFeature. Objects which are not deleted within the timeout, e.g. since a finalizer blocks their
+// deletion, fail the test.
+func CleanupFeature(timeout time.Duration) types.FeatureEnvFunc {
+	return func(ctx context.Context, cfg *envconf.Config, t *testing.T, feature features.Feature) (context.Context, error) {
+		scope, ok := FeatureScopeFromContext(ctx)
+		if !ok {
+			return ctx, nil
+		}
+		leftovers, err := deleteFeature(ctx, cfg, scope, timeout)
+		if err != nil {
+			return ctx, err
+		}
+		if len(leftovers) > 0 {
+			t.Errorf("feature %s left %d objects behind:\n%s", feature.Name(), len(leftovers), describeLeftovers(leftovers))
+		}
+		return context.WithValue(ctx, featureScopeKey{}, nil), nil
+	}
+}
+
+// deleteFeature deletes the objects of the feature and returns the ones still existing after the timeout.
+// Only the namespace and the kinds labelled by MutateScope are looked for.
+func deleteFeature(ctx context.Context, cfg *envconf.Config, scope FeatureScope, timeout time.Duration) ([]unstructured.Unstructured, error) {
+	kinds := scopedResources(restMapper(cfg), scope.kinds.list())
+	cl, err := dynamic.NewForConfig(cfg.Client().RESTConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := featureObjects(ctx, cl, kinds, scope)
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range objects {
+		if object.GetNamespace() != "" {
+			// namespaced objects are deleted with the namespace
+			continue
+		}
+		err := cl.Resource(kinds[object.GroupVersionKind()].gvr).Delete(ctx, object.GetName(), metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	var leftovers []unstructured.Unstructured
+	err = apimachinerywait.PollUntilContextTimeout(ctx, featureCleanupInterval, timeout, true, func(ctx context.Context) (bool, error) {
+		remaining, err := featureObjects(ctx, cl, kinds, scope)
+		if err != nil {
+			return false, err
+		}
+		leftovers = blockingObjects(remaining)
+		return len(remaining) == 0, nil
+	})
+	if err != nil && !apimachinerywait.Interrupted(err) {
+		return nil, err
+	}
+	if err == nil {
+		klog.V(4).Infof("Deleted the objects of feature %s", scope.ID)
+		return nil, nil
+	}
+	return leftovers, nil
+}
+
+type deletableResource struct {
+	gvr        schema.GroupVersionResource
+	namespaced bool
+}
+
+// scopedResources returns the resources of the namespace and of the given kinds, kinds unknown to the mapper are skipped
+func scopedResources(mapper meta.RESTMapper, gvks []schema.GroupVersionKind) map[schema.GroupVersionKind]deletableResource {
+	kinds := map[schema.GroupVersionKind]deletableResource{
+		corev1.SchemeGroupVersion.WithKind("Namespace"): {gvr: corev1.SchemeGroupVersion.WithResource("namespaces")},
+	}
+	for _, gvk := range gvks {
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			klog.V(4).Infof("Skipping cleanup of %s: %v", gvk, err)
+			continue
+		}
+		kinds[gvk] = deletableResource{gvr: mapping.Resource, namespaced: mapping.Scope.Name() == meta.RESTScopeNameNamespace}
+	}
+	return kinds
+}
+
+// featureObjects returns the objects of the feature namespace and the cluster scoped objects labelled with the feature ID
+func featureObjects(ctx context.Context, cl dynamic.Interface, kinds map[schema.GroupVersionKind]deletableResource, scope FeatureScope) ([]unstructured.Unstructured, error) {
+	var objects []unstructured.Unstructured
+	for _, kind := range kinds {
+		var list *unstructured.UnstructuredList
+		var err error
+		if kind.namespaced {
+			list, err = cl.Resource(kind.gvr).Namespace(scope.Namespace).List(ctx, metav1.ListOptions{})
+		} else {
+			list, err = cl.Resource(kind.gvr).List(ctx, metav1.ListOptions{LabelSelector: FeatureIDLabel + "=" + scope.ID})
+		}
+		if err != nil {
+			if errors.IsNotFound(err) || errors.IsForbidden(err) || errors.IsMethodNotSupported(err) {
+				continue
+			}
+			return nil, err
+		}
+		objects = append(objects, list.Items...)
+	}
+	return objects, nil
+}
+
+// blockingObjects returns the cluster scoped objects and the namespaced objects with finalizers,
+// namespaced objects without finalizers are just waiting for the deletion of their namespace
+func blockingObjects(objects []unstructured.Unstructured) []unstructured.Unstructured {
+	var blocking []unstructured.Unstructured
+	for _, object := range objects {
+		if object.GetNamespace() == "" || len(object.GetFinalizers()) > 0 {
+			blocking = append(blocking, object)
+		}
+	}
+	return blocking
+}
+
+func describeLeftovers(objects []unstructured.Unstructured) string {
+	lines := make([]string, 0, len(objects))
+	for i := range objects {
+		line := Identifier(&objects[i])
+		if objects[i].GetNamespace() != "" {
+			line = fmt.Sprintf("%s (namespace %s)", line, objects[i].GetNamespace())
+		}
+		if finalizers := objects[i].GetFinalizers(); len(finalizers) > 0 {
+			line = fmt.Sprintf("%s blocked by finalizers %s", line, strings.Join(finalizers, ", "))
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,112 @@
+package resources
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+)
+
+var (
+	namespacedKind = schema.GroupVersionKind{Group: "nop.crossplane.io", Version: "v1alpha1", Kind: "NopResource"}
+	clusterKind    = schema.GroupVersionKind{Group: "nop.crossplane.io", Version: "v1alpha1", Kind: "ClusterNopResource"}
+)
+
+func testMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(namespacedKind, meta.RESTScopeNamespace)
+	mapper.Add(clusterKind, meta.RESTScopeRoot)
+	return mapper
+}
+
+func object(gvk schema.GroupVersionKind, namespace string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName("test")
+	obj.SetNamespace(namespace)
+	return obj
+}
+
+func TestScopeFunc(t *testing.T) {
+	t.Run("without feature scope only namespaced objects are moved into the namespace", func(t *testing.T) {
+		scope := scopeFunc(context.Background(), testMapper(), "env-ns")
+
+		namespaced := object(namespacedKind, "other")
+		require.NoError(t, scope(namespaced))
+		require.Equal(t, "env-ns", namespaced.GetNamespace())
+		require.Empty(t, namespaced.GetLabels())
+
+		cluster := object(clusterKind, "other")
+		require.NoError(t, scope(cluster))
+		require.Empty(t, cluster.GetNamespace())
+	})
+
+	t.Run("unknown kinds are treated as namespaced", func(t *testing.T) {
+		unknown := object(schema.GroupVersionKind{Group: "unknown.io", Version: "v1", Kind: "Unknown"}, "")
+		require.NoError(t, scopeFunc(context.Background(), testMapper(), "env-ns")(unknown))
+		require.Equal(t, "env-ns", unknown.GetNamespace())
+	})
+
+	t.Run("objects of an isolated feature are labelled", func(t *testing.T) {
+		ctx := WithFeatureScope(context.Background(), FeatureScope{ID: "feature-abc", Namespace: "feature-abc"})
+		require.Equal(t, "feature-abc", Namespace(ctx, envconf.New().WithNamespace("env-ns")))
+		scope := scopeFunc(ctx, testMapper(), Namespace(ctx, envconf.New()))
+
+		namespaced := object(namespacedKind, "")
+		namespaced.SetLabels(map[string]string{"keep": "me"})
+		require.NoError(t, scope(namespaced))
+		require.Equal(t, "feature-abc", namespaced.GetNamespace())
+		require.Equal(t, map[string]string{"keep": "me", FeatureIDLabel: "feature-abc"}, namespaced.GetLabels())
+
+		cluster := object(clusterKind, "")
+		require.NoError(t, scope(cluster))
+		require.Empty(t, cluster.GetNamespace())
+		require.Equal(t, map[string]string{FeatureIDLabel: "feature-abc"}, cluster.GetLabels())
+
+		featureScope, _ := FeatureScopeFromContext(ctx)
+		require.ElementsMatch(t, []schema.GroupVersionKind{namespacedKind, clusterKind}, featureScope.kinds.list())
+	})
+}
+
+func TestNamespace_withoutFeatureScope(t *testing.T) {
+	require.Equal(t, "env-ns", Namespace(context.Background(), envconf.New().WithNamespace("env-ns")))
+}
+
+func Test_featureID(t *testing.T) {
+	id := featureID("Create NopResource & delete it")
+	require.True(t, strings.HasPrefix(id, "create-nopresource-delete-it-"), id)
+	require.Len(t, id, len("create-nopresource-delete-it")+7)
+	require.NotEqual(t, id, featureID("Create NopResource & delete it"))
+
+	require.True(t, strings.HasPrefix(featureID("!!!"), "feature-"))
+	require.LessOrEqual(t, len(featureID(strings.Repeat("a", 100))), 47)
+}
+
+func Test_scopedResources(t *testing.T) {
+	unknown := schema.GroupVersionKind{Group: "unknown.io", Version: "v1", Kind: "Unknown"}
+	kinds := scopedResources(testMapper(), []schema.GroupVersionKind{namespacedKind, clusterKind, unknown})
+	require.Equal(t, map[schema.GroupVersionKind]deletableResource{
+		{Version: "v1", Kind: "Namespace"}: {gvr: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}},
+		namespacedKind:                     {gvr: namespacedKind.GroupVersion().WithResource("nopresources"), namespaced: true},
+		clusterKind:                        {gvr: clusterKind.GroupVersion().WithResource("clusternopresources")},
+	}, kinds)
+}
+
+func Test_blockingObjects(t *testing.T) {
+	waiting := object(namespacedKind, "feature-abc")
+	finalized := object(namespacedKind, "feature-abc")
+	finalized.SetName("finalized")
+	finalized.SetFinalizers([]string{"finalizer.managedresource.crossplane.io"})
+	cluster := object(clusterKind, "")
+
+	blocking := blockingObjects([]unstructured.Unstructured{*waiting, *finalized, *cluster})
+	require.Len(t, blocking, 2)
+	require.Equal(t, `nop.crossplane.io/v1alpha1, Kind=ClusterNopResource/test
+nop.crossplane.io/v1alpha1, Kind=NopResource/finalized (namespace feature-abc) blocked by finalizers finalizer.managedresource.crossplane.io`,
+		describeLeftovers(blocking))
+}
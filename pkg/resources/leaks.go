@@ -0,0 +1,219 @@
+package resources
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1extensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	apimachinerywait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/pkg/env"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+)
+
+const (
+	leakPollInterval = 2 * time.Second
+	// DefaultLeakGracePeriod is the GracePeriod of LeakOptions, if not set
+	DefaultLeakGracePeriod = time.Minute
+)
+
+// LeakOptions configures the leak detection of DetectLeakedResources
+type LeakOptions struct {
+	// ForceClean deletes leaked managed resources and removes their finalizers, if they aren't gone after the GracePeriod
+	ForceClean bool
+	// GracePeriod is the time leaked managed resources get to be deleted, before their finalizers are removed,
+	// defaults to DefaultLeakGracePeriod
+	GracePeriod time.Duration
+}
+
+func (o LeakOptions) gracePeriod() time.Duration {
+	if o.GracePeriod <= 0 {
+		return DefaultLeakGracePeriod
+	}
+	return o.GracePeriod
+}
+
+type managedResourceSnapshotKey struct{}
+
+// SnapshotManagedResources is an env.Func, which records the managed resources existing at suite start,
+// so DetectLeakedResources only reports the ones created by the suite
+func SnapshotManagedResources(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+	existing, err := listManagedResources(ctx, cfg)
+	if err != nil {
+		return ctx, err
+	}
+	snapshot := make(map[types.UID]bool, len(existing))
+	for _, resource := range existing {
+		snapshot[resource.object.GetUID()] = true
+	}
+	klog.V(4).Infof("Recorded %d managed resources existing at suite start", len(snapshot))
+	return context.WithValue(ctx, managedResourceSnapshotKey{}, snapshot), nil
+}
+
+// DetectLeakedResources returns an env.Func for Finish, which fails with an error listing the managed resources, that
+// didn't exist when SnapshotManagedResources ran and are still left, e.g. since a feature failed before deleting them.
+// Without a snapshot every managed resource counts as leaked.
+// With LeakOptions.ForceClean the leaked resources are deleted, if need be by removing their finalizers, the error is
+// returned nonetheless.
+func DetectLeakedResources(opts LeakOptions) env.Func {
+	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+		snapshot, _ := ctx.Value(managedResourceSnapshotKey{}).(map[types.UID]bool)
+		current, err := listManagedResources(ctx, cfg)
+		if err != nil {
+			return ctx, err
+		}
+		leaked := leakedResources(current, snapshot)
+		if len(leaked) == 0 {
+			klog.V(4).Info("No leaked managed resources")
+			return ctx, nil
+		}
+		leakErr := fmt.Errorf("%d managed resources leaked:\n%s", len(leaked), describeLeaks(leaked))
+		klog.Error(leakErr)
+		if !opts.ForceClean {
+			return ctx, leakErr
+		}
+		cl, err := dynamic.NewForConfig(cfg.Client().RESTConfig())
+		if err != nil {
+			return ctx, goerrors.Join(leakErr, err)
+		}
+		return ctx, goerrors.Join(leakErr, forceClean(ctx, cl, leaked, opts.gracePeriod()))
+	}
+}
+
+type managedResource struct {
+	gvr    schema.GroupVersionResource
+	object unstructured.Unstructured
+}
+
+// listManagedResources lists the objects of all CRDs of the managed category in their storage version
+func listManagedResources(ctx context.Context, cfg *envconf.Config) ([]managedResource, error) {
+	crds, err := managedCRDs(ctx, resClient(cfg))
+	if err != nil {
+		return nil, err
+	}
+	cl, err := dynamic.NewForConfig(cfg.Client().RESTConfig())
+	if err != nil {
+		return nil, err
+	}
+	var resources []managedResource
+	for _, crd := range crds {
+		gvr := storageResource(crd)
+		objects, err := getResourcesDynamically(ctx, cl, gvr.Group, gvr.Version, gvr.Resource)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, object := range objects {
+			resources = append(resources, managedResource{gvr: gvr, object: object})
+		}
+	}
+	return resources, nil
+}
+
+func storageResource(crd v1extensions.CustomResourceDefinition) schema.GroupVersionResource {
+	version := crd.Spec.Versions[0].Name
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			version = v.Name
+		}
+	}
+	return schema.GroupVersionResource{Group: crd.Spec.Group, Version: version, Resource: crd.Spec.Names.Plural}
+}
+
+func leakedResources(current []managedResource, snapshot map[types.UID]bool) []managedResource {
+	var leaked []managedResource
+	for _, resource := range current {
+		if !snapshot[resource.object.GetUID()] {
+			leaked = append(leaked, resource)
+		}
+	}
+	return leaked
+}
+
+func describeLeaks(leaked []managedResource) string {
+	lines := make([]string, 0, len(leaked))
+	for _, resource := range leaked {
+		lines = append(lines, describeLeak(resource.object))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// describeLeak returns the identifier and deletion state of the leaked resource
+func describeLeak(object unstructured.Unstructured) string {
+	id := Identifier(&object)
+	if object.GetNamespace() != "" {
+		id = fmt.Sprintf("%s (namespace %s)", id, object.GetNamespace())
+	}
+	if object.GetDeletionTimestamp() == nil {
+		return fmt.Sprintf("%s: not deleted", id)
+	}
+	return fmt.Sprintf("%s: deleting since %s, blocked by finalizers [%s]",
+		id, object.GetDeletionTimestamp().UTC().Format(time.RFC3339), strings.Join(object.GetFinalizers(), ", "))
+}
+
+// forceClean deletes the leaked resources and removes the finalizers of the ones still existing after the grace period
+func forceClean(ctx context.Context, cl dynamic.Interface, leaked []managedResource, gracePeriod time.Duration) error {
+	for _, resource := range leaked {
+		if resource.object.GetDeletionTimestamp() != nil {
+			continue
+		}
+		err := cl.Resource(resource.gvr).Namespace(resource.object.GetNamespace()).Delete(ctx, resource.object.GetName(), metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	remaining := leaked
+	err := apimachinerywait.PollUntilContextTimeout(ctx, leakPollInterval, gracePeriod, true, func(ctx context.Context) (bool, error) {
+		var err error
+		remaining, err = stillExisting(ctx, cl, remaining)
+		return len(remaining) == 0, err
+	})
+	if err == nil {
+		klog.Infof("Deleted %d leaked managed resources", len(leaked))
+		return nil
+	}
+	if !apimachinerywait.Interrupted(err) {
+		return err
+	}
+
+	patch := []byte(`{"metadata":{"finalizers":null}}`)
+	for _, resource := range remaining {
+		klog.Warningf("Removing finalizers of leaked %s", Identifier(&resource.object))
+		_, err := cl.Resource(resource.gvr).Namespace(resource.object.GetNamespace()).
+			Patch(ctx, resource.object.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// stillExisting returns the resources, which still exist
+func stillExisting(ctx context.Context, cl dynamic.Interface, resources []managedResource) ([]managedResource, error) {
+	var left []managedResource
+	for _, resource := range resources {
+		_, err := cl.Resource(resource.gvr).Namespace(resource.object.GetNamespace()).Get(ctx, resource.object.GetName(), metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		left = append(left, resource)
+	}
+	return left, nil
+}
@@ -0,0 +1,108 @@
+package resources
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1extensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var nopResources = schema.GroupVersionResource{Group: "nop.crossplane.io", Version: "v1alpha1", Resource: "nopresources"}
+
+func managedObject(name string, uid types.UID) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetGroupVersionKind(nopResources.GroupVersion().WithKind("NopResource"))
+	obj.SetName(name)
+	obj.SetUID(uid)
+	return obj
+}
+
+func Test_storageResource(t *testing.T) {
+	crd := v1extensions.CustomResourceDefinition{Spec: v1extensions.CustomResourceDefinitionSpec{
+		Group: "nop.crossplane.io",
+		Names: v1extensions.CustomResourceDefinitionNames{Plural: "nopresources"},
+		Versions: []v1extensions.CustomResourceDefinitionVersion{
+			{Name: "v1alpha1"},
+			{Name: "v1beta1", Storage: true},
+		},
+	}}
+	require.Equal(t, nopResources.GroupResource().WithVersion("v1beta1"), storageResource(crd))
+}
+
+func Test_leakedResources(t *testing.T) {
+	current := []managedResource{
+		{gvr: nopResources, object: managedObject("existing", "1")},
+		{gvr: nopResources, object: managedObject("leaked", "2")},
+	}
+	leaked := leakedResources(current, map[types.UID]bool{"1": true})
+	require.Len(t, leaked, 1)
+	require.Equal(t, "leaked", leaked[0].object.GetName())
+
+	require.Len(t, leakedResources(current, nil), 2, "without snapshot every resource is leaked")
+}
+
+func Test_describeLeaks(t *testing.T) {
+	deleting := managedObject("deleting", "1")
+	deleting.SetDeletionTimestamp(&metav1.Time{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)})
+	deleting.SetFinalizers([]string{"finalizer.managedresource.crossplane.io"})
+	namespaced := managedObject("namespaced", "2")
+	namespaced.SetNamespace("test")
+
+	require.Equal(t, `nop.crossplane.io/v1alpha1, Kind=NopResource/deleting: deleting since 2024-01-02T03:04:05Z, blocked by finalizers [finalizer.managedresource.crossplane.io]
+nop.crossplane.io/v1alpha1, Kind=NopResource/namespaced (namespace test): not deleted`,
+		describeLeaks([]managedResource{{object: namespaced}, {object: deleting}}))
+}
+
+func fakeDynamicClient(objects ...unstructured.Unstructured) *fakedynamic.FakeDynamicClient {
+	runtimeObjects := make([]runtime.Object, 0, len(objects))
+	for i := range objects {
+		runtimeObjects = append(runtimeObjects, &objects[i])
+	}
+	return fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{nopResources: "NopResourceList"}, runtimeObjects...)
+}
+
+func TestLeakOptions_gracePeriod(t *testing.T) {
+	require.Equal(t, DefaultLeakGracePeriod, LeakOptions{}.gracePeriod())
+	require.Equal(t, time.Second, LeakOptions{GracePeriod: time.Second}.gracePeriod())
+}
+
+func Test_forceClean(t *testing.T) {
+	t.Run("deleted within the grace period", func(t *testing.T) {
+		leaked := managedObject("leaked", "1")
+		cl := fakeDynamicClient(leaked)
+		require.NoError(t, forceClean(context.Background(), cl, []managedResource{{gvr: nopResources, object: leaked}}, time.Second))
+		require.Equal(t, []string{"delete", "get"}, verbs(cl.Actions()))
+	})
+
+	t.Run("finalizers are removed after the grace period", func(t *testing.T) {
+		leaked := managedObject("leaked", "1")
+		leaked.SetFinalizers([]string{"finalizer.managedresource.crossplane.io"})
+		cl := fakeDynamicClient(leaked)
+		// the finalizer blocks the deletion
+		cl.PrependReactor("delete", "nopresources", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, nil
+		})
+		require.NoError(t, forceClean(context.Background(), cl, []managedResource{{gvr: nopResources, object: leaked}}, 10*time.Millisecond))
+		actions := cl.Actions()
+		require.Equal(t, "patch", actions[len(actions)-1].GetVerb())
+		require.JSONEq(t, `{"metadata":{"finalizers":null}}`, string(actions[len(actions)-1].(k8stesting.PatchAction).GetPatch()))
+	})
+}
+
+func verbs(actions []k8stesting.Action) []string {
+	result := make([]string, 0, len(actions))
+	for _, action := range actions {
+		result = append(result, action.GetVerb())
+	}
+	return result
+}
@@ -0,0 +1,237 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/decoder"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/provider"
+	"github.com/crossplane-contrib/xp-testing/pkg/report"
+	"github.com/crossplane-contrib/xp-testing/pkg/xpconditions"
+)
+
+const (
+	defaultLoadTestTimeout = 10 * time.Minute
+	usageSampleInterval    = 10 * time.Second
+)
+
+// LoadTestConfig configures a load test, which stamps out copies of a template manifest, waits until all of them
+// are synced and ready and deletes them again, see RunLoadTest
+type LoadTestConfig struct {
+	// Template is the path of a manifest file with a single object, e.g. a managed resource.
+	// It must not set an external name, as all copies would refer to the same external resource.
+	Template string
+	// Count is the number of copies, named <NamePrefix>-<index>
+	Count int
+	// NamePrefix defaults to the name of the template object
+	NamePrefix string
+	// WaveSize is the number of copies created per wave, defaults to Count
+	WaveSize int
+	// WaveInterval is the pause between two waves
+	WaveInterval time.Duration
+	// RateLimit limits the number of creations per second, 0 means unlimited
+	RateLimit float64
+	// ProviderName enables sampling the CPU and memory usage of the provider pods from the metrics API during the run
+	ProviderName string
+	// Timeout applies to the copies becoming ready as well as to their deletion, defaults to 10 minutes
+	Timeout time.Duration
+	// MaxErrorRate is the rate of copies that may fail to be created or become ready in AssessLoad, e.g. 0.01 for 1%
+	MaxErrorRate float64
+}
+
+// LoadTestResult is the outcome of a load test
+type LoadTestResult struct {
+	Count int
+	// CreateErrors is the number of copies that couldn't be created
+	CreateErrors int
+	// NotReady is the number of created copies that were not synced and ready in time
+	NotReady int
+	// CreateDuration is the time it took to create all copies, including waves and rate limiting
+	CreateDuration time.Duration
+	// ReadyDuration is the time from the first creation until all copies were ready
+	ReadyDuration time.Duration
+	// DeleteDuration is the time it took to delete all copies
+	DeleteDuration time.Duration
+	// Usage is the CPU and memory usage of the provider pods during the run, if sampled
+	Usage provider.Usage
+}
+
+// Throughput returns the number of copies that became ready per second
+func (r LoadTestResult) Throughput() float64 {
+	if r.ReadyDuration <= 0 {
+		return 0
+	}
+	return float64(r.Count-r.CreateErrors-r.NotReady) / r.ReadyDuration.Seconds()
+}
+
+// ErrorRate returns the rate of copies that couldn't be created or did not become ready
+func (r LoadTestResult) ErrorRate() float64 {
+	if r.Count == 0 {
+		return 0
+	}
+	return float64(r.CreateErrors+r.NotReady) / float64(r.Count)
+}
+
+// String returns a human-readable summary of the result
+func (r LoadTestResult) String() string {
+	return fmt.Sprintf("%d copies: %d create errors, %d not ready (error rate %.2f%%), created in %s, ready in %s (%.2f/s), deleted in %s, provider %s",
+		r.Count, r.CreateErrors, r.NotReady, r.ErrorRate()*100, r.CreateDuration, r.ReadyDuration, r.Throughput(), r.DeleteDuration, r.Usage)
+}
+
+// AssessLoad runs the load test and fails if the error rate exceeds MaxErrorRate
+func (c LoadTestConfig) AssessLoad(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+	result, err := RunLoadTest(ctx, cfg, c)
+	t.Logf("Load test of %s: %s", c.Template, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ErrorRate() > c.MaxErrorRate {
+		t.Errorf("error rate %.2f%% exceeds %.2f%%", result.ErrorRate()*100, c.MaxErrorRate*100)
+	}
+	return ctx
+}
+
+// RunLoadTest creates the copies of the template in waves, waits until they are synced and ready and deletes them.
+// Copies which couldn't be created or did not become ready are counted in the result, an error is only returned if the
+// test couldn't be run, e.g. the template is invalid or the copies couldn't be deleted.
+func RunLoadTest(ctx context.Context, cfg *envconf.Config, c LoadTestConfig) (result LoadTestResult, err error) {
+	result.Count = c.Count
+	template := &unstructured.Unstructured{}
+	if err := decoder.DecodeFile(os.DirFS(filepath.Dir(c.Template)), filepath.Base(c.Template), template); err != nil {
+		return result, fmt.Errorf("failed to decode template %s: %w", c.Template, err)
+	}
+	copies, err := stampCopies(template, orDefault(c.NamePrefix, template.GetName()), c.Count, scoper(ctx, cfg))
+	if err != nil {
+		return result, fmt.Errorf("failed to stamp copies of %s: %w", c.Template, err)
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultLoadTestTimeout
+	}
+
+	res := cfg.Client().Resources()
+	if c.ProviderName != "" {
+		sampler := provider.SampleUsage(ctx, res, c.ProviderName, usageSampleInterval)
+		// the named result is completed after any return
+		defer func() { result.Usage = sampler.Stop() }()
+	}
+
+	start := time.Now()
+	created := createInWaves(ctx, res, copies, c.WaveSize, c.WaveInterval, c.RateLimit)
+	result.CreateDuration = time.Since(start)
+	result.CreateErrors = len(copies) - len(created)
+
+	refs := lo.Map(created, func(object k8s.Object, _ int) xpconditions.ObjectReference { return xpconditions.RefOf(object) })
+	err = xpconditions.AwaitMatch(xpconditions.FromContext(ctx, res), xpconditions.ManagedResourceReady(), refs, wait.WithTimeout(timeout))
+	result.ReadyDuration = time.Since(start)
+	var matchErr *xpconditions.MatchError
+	switch {
+	case errors.As(err, &matchErr):
+		result.NotReady = len(matchErr.Mismatches)
+		klog.V(4).Info(err)
+	case err != nil:
+		return result, err
+	}
+	recordReady(ctx, res, created)
+
+	deleteStart := time.Now()
+	for _, object := range created {
+		if err := res.Delete(ctx, object); err != nil && !apierrors.IsNotFound(err) {
+			return result, err
+		}
+	}
+	deletions := map[string]report.Deletion{}
+	if err := wait.For(resourcesDeleted(res, created, deleteStart, deletions), wait.WithTimeout(timeout)); err != nil {
+		return result, fmt.Errorf("copies of %s not deleted: %w", c.Template, err)
+	}
+	result.DeleteDuration = time.Since(deleteStart)
+	report.RecordDeleted(ctx, result.DeleteDuration, lo.Values(deletions)...)
+	return result, nil
+}
+
+// stampCopies creates count copies of the template, named <prefix>-<index>
+func stampCopies(template *unstructured.Unstructured, prefix string, count int, scope func(obj k8s.Object) error) ([]*unstructured.Unstructured, error) {
+	copies := make([]*unstructured.Unstructured, 0, count)
+	for i := 0; i < count; i++ {
+		stamped := template.DeepCopy()
+		stamped.SetName(fmt.Sprintf("%s-%d", prefix, i))
+		// like ImportResources, only namespaced objects are moved into the namespace
+		if err := scope(stamped); err != nil {
+			return nil, err
+		}
+		copies = append(copies, stamped)
+	}
+	return copies, nil
+}
+
+// createInWaves creates the objects in waves of waveSize with a pause of waveInterval in between,
+// limited to rateLimit creations per second. It returns the objects created successfully.
+func createInWaves(ctx context.Context, res createClient, objects []*unstructured.Unstructured, waveSize int, waveInterval time.Duration, rateLimit float64) []k8s.Object {
+	if waveSize <= 0 {
+		waveSize = len(objects)
+	}
+	var throttle <-chan time.Time
+	if rateLimit > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rateLimit))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+	var created []k8s.Object
+	for i, object := range objects {
+		if i > 0 && i%waveSize == 0 && waveInterval > 0 {
+			klog.V(4).Infof("Created %d of %d objects, waiting %s for the next wave", i, len(objects), waveInterval)
+			if !sleep(ctx, waveInterval) {
+				return created
+			}
+		}
+		if throttle != nil {
+			select {
+			case <-ctx.Done():
+				return created
+			case <-throttle:
+			}
+		}
+		if err := res.Create(ctx, object); err != nil {
+			klog.V(4).Infof("Could not create %s: %v", Identifier(object), err)
+			continue
+		}
+		created = append(created, object)
+	}
+	return created
+}
+
+type createClient interface {
+	Create(ctx context.Context, obj k8s.Object, opts ...resources.CreateOption) error
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func orDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,79 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
+)
+
+type fakeCreateClient struct {
+	created []string
+	times   []time.Time
+	fail    map[string]bool
+}
+
+func (f *fakeCreateClient) Create(_ context.Context, obj k8s.Object, _ ...resources.CreateOption) error {
+	if f.fail[obj.GetName()] {
+		return errors.New("boom")
+	}
+	f.created = append(f.created, obj.GetName())
+	f.times = append(f.times, time.Now())
+	return nil
+}
+
+func Test_stampCopies(t *testing.T) {
+	template := &unstructured.Unstructured{}
+	template.SetAPIVersion("nop.crossplane.io/v1alpha1")
+	template.SetKind("NopResource")
+	template.SetName("template")
+	template.SetLabels(map[string]string{"load": "true"})
+
+	copies, err := stampCopies(template, "load", 3, scopeFunc(context.Background(), nil, "test-ns"))
+	require.NoError(t, err)
+	require.Len(t, copies, 3)
+	for i, name := range []string{"load-0", "load-1", "load-2"} {
+		require.Equal(t, name, copies[i].GetName())
+		require.Equal(t, "test-ns", copies[i].GetNamespace())
+		require.Equal(t, "NopResource", copies[i].GetKind())
+		require.Equal(t, map[string]string{"load": "true"}, copies[i].GetLabels())
+	}
+	require.Equal(t, "template", template.GetName(), "template is not modified")
+
+	_, err = stampCopies(template, "load", 3, func(k8s.Object) error { return errors.New("boom") })
+	require.EqualError(t, err, "boom")
+}
+
+func Test_createInWaves(t *testing.T) {
+	template := &unstructured.Unstructured{}
+	template.SetName("template")
+	copies, err := stampCopies(template, "load", 5, scopeFunc(context.Background(), nil, ""))
+	require.NoError(t, err)
+
+	cl := &fakeCreateClient{fail: map[string]bool{"load-3": true}}
+	created := createInWaves(context.Background(), cl, copies, 2, 50*time.Millisecond, 0)
+	require.Len(t, created, 4)
+	require.Equal(t, []string{"load-0", "load-1", "load-2", "load-4"}, cl.created)
+	require.GreaterOrEqual(t, cl.times[2].Sub(cl.times[1]), 50*time.Millisecond, "second wave waits")
+	require.GreaterOrEqual(t, cl.times[3].Sub(cl.times[2]), 50*time.Millisecond, "third wave waits")
+
+	cl = &fakeCreateClient{}
+	start := time.Now()
+	createInWaves(context.Background(), cl, copies, 0, 0, 100)
+	require.Len(t, cl.created, 5)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond, "rate limited to one creation per 10ms")
+}
+
+func TestLoadTestResult(t *testing.T) {
+	result := LoadTestResult{Count: 100, CreateErrors: 2, NotReady: 3, ReadyDuration: 10 * time.Second}
+	require.InDelta(t, 9.5, result.Throughput(), 0.001)
+	require.InDelta(t, 0.05, result.ErrorRate(), 0.001)
+	require.Contains(t, result.String(), "100 copies: 2 create errors, 3 not ready (error rate 5.00%)")
+	require.Zero(t, LoadTestResult{}.ErrorRate())
+	require.Zero(t, LoadTestResult{}.Throughput())
+}
@@ -7,11 +7,13 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
 	v1extensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -19,6 +21,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	apimachinerywait "k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/e2e-framework/klient/decoder"
@@ -29,6 +32,9 @@ import (
 	"sigs.k8s.io/e2e-framework/pkg/envconf"
 	"sigs.k8s.io/yaml"
 
+	"github.com/crossplane-contrib/xp-testing/pkg/events"
+	"github.com/crossplane-contrib/xp-testing/pkg/provider"
+	"github.com/crossplane-contrib/xp-testing/pkg/report"
 	"github.com/crossplane-contrib/xp-testing/pkg/xpconditions"
 )
 
@@ -41,7 +47,7 @@ var (
 func ImportResources(ctx context.Context, t *testing.T, cfg *envconf.Config, dir string, decoderOptions ...decoder.DecodeOption) {
 	r := resClient(cfg)
 
-	r.WithNamespace(cfg.Namespace())
+	r.WithNamespace(Namespace(ctx, cfg))
 
 	if exists, err := checkAtLeastOneYamlFile(dir); err != nil {
 		t.Fatal(err)
@@ -49,8 +55,7 @@ func ImportResources(ctx context.Context, t *testing.T, cfg *envconf.Config, dir
 		t.Fatalf("No yaml files found for %s", dir)
 		return
 	}
-	decoderOptions = append(decoderOptions, decoder.MutateNamespace(cfg.Namespace()))
-	// managed resources fare cluster scoped, so if we patched them with the test namespace it won't do anything
+	decoderOptions = append(decoderOptions, MutateScope(ctx, cfg))
 	errdecode := decoder.DecodeEachFile(
 		ctx, os.DirFS(dir), "*",
 		decoder.CreateIgnoreAlreadyExists(r),
@@ -81,7 +86,9 @@ func checkAtLeastOneYamlFile(dir string) (bool, error) {
 	return len(files) > 0, nil
 }
 
-// WaitForResourcesToBeSynced waits until all managed resources are synced and available
+// WaitForResourcesToBeSynced waits until all managed resources are synced and available.
+// If the resources don't converge in time, a *ResourcesNotSyncedError is returned, which lists
+// every object that is not synced and ready together with its conditions and recent events.
 func WaitForResourcesToBeSynced(
 	ctx context.Context,
 	cfg *envconf.Config,
@@ -97,11 +104,69 @@ func WaitForResourcesToBeSynced(
 	klog.V(4).Infof("Waiting for the following objects to become synced and ready\n %s", identifiers(objects))
 
 	res := cfg.Client().Resources()
+	c := xpconditions.FromContext(ctx, res)
 
-	err = wait.For(
-		xpconditions.New(res).ManagedResourcesReadyAndReady(&mockList{Items: objects}), opts...,
+	err = c.For(
+		c.ManagedResourcesReadyAndReady(&mockList{Items: objects}), opts...,
 	)
-	return err
+	if err != nil {
+		err = notSyncedError(ctx, res, objects, err)
+		report.MarkFailed(ctx, err.Error())
+		return err
+	}
+	recordReady(ctx, res, objects)
+	return nil
+}
+
+// WaitForResourcesToBeStable waits like WaitForResourcesToBeSynced, but tracks the condition history of every object.
+// It fails fast if an object is stuck with a terminal reason or flaps, and requires objects to stay synced and ready
+// for HistoryOptions.Stability, see xpconditions.AwaitMatchWithHistory.
+func WaitForResourcesToBeStable(
+	ctx context.Context,
+	cfg *envconf.Config,
+	dir string,
+	objFilterFunc ObjFilterFunc,
+	history xpconditions.HistoryOptions,
+	opts ...wait.Option,
+) error {
+	objects, err := filteredObjects(ctx, cfg, dir, objFilterFunc)
+	if err != nil {
+		return err
+	}
+
+	klog.V(4).Infof("Waiting for the following objects to become stable\n %s", identifiers(objects))
+
+	res := cfg.Client().Resources()
+	refs := make([]xpconditions.ObjectReference, 0, len(objects))
+	for _, object := range objects {
+		refs = append(refs, xpconditions.RefOf(object))
+	}
+	err = xpconditions.AwaitMatchWithHistory(xpconditions.FromContext(ctx, res), xpconditions.ManagedResourceReady(), refs, history, opts...)
+	if err != nil {
+		err = notSyncedError(ctx, res, objects, err)
+		report.MarkFailed(ctx, err.Error())
+		return err
+	}
+	recordReady(ctx, res, objects)
+	return nil
+}
+
+// recordReady records the time to Ready of the objects, if the feature is recorded by a report.Reporter
+func recordReady(ctx context.Context, res *resources.Resources, objects []k8s.Object) {
+	if _, ok := report.FeatureFromContext(ctx); !ok {
+		return
+	}
+	current := make([]*unstructured.Unstructured, 0, len(objects))
+	for _, object := range objects {
+		us := &unstructured.Unstructured{}
+		us.SetGroupVersionKind(object.GetObjectKind().GroupVersionKind())
+		if err := res.Get(ctx, object.GetName(), object.GetNamespace(), us); err != nil {
+			klog.V(4).Infof("Could not retrieve %s for the report: %v", Identifier(object), err)
+			continue
+		}
+		current = append(current, us)
+	}
+	report.RecordReady(ctx, current...)
 }
 
 // WaitForResourcesToBePaused waits until all managed resources are synced false with reason ReconcilePaused
@@ -112,8 +177,8 @@ func WaitForResourcesToBePaused(ctx context.Context, cfg *envconf.Config, dir st
 	}
 	klog.V(4).Infof("Waiting for the following objects to be paused\n %s", identifiers(objects))
 	res := cfg.Client().Resources()
-	c := xpconditions.New(res)
-	return wait.For(c.ResourcesMatch(&mockList{Items: objects}, c.IsPaused), opts...)
+	c := xpconditions.FromContext(ctx, res)
+	return c.For(c.ResourcesMatch(&mockList{Items: objects}, c.IsPaused), opts...)
 }
 
 func filteredObjects(ctx context.Context, cfg *envconf.Config, dir string, objFilterFunc ObjFilterFunc) ([]k8s.Object, error) {
@@ -142,17 +207,17 @@ func Identifier(object k8s.Object) string {
 }
 
 func identifiers(objects []k8s.Object) string {
-	val := ""
+	val := strings.Builder{}
 	for _, object := range objects {
-		val = fmt.Sprintf("%s\n", Identifier(object))
+		val.WriteString(fmt.Sprintf("%s\n", Identifier(object)))
 	}
-	return val
+	return val.String()
 }
 
 func getObjectsToImport(ctx context.Context, cfg *envconf.Config, dirs []string) ([]k8s.Object, error) {
 	r := resClient(cfg)
 
-	r.WithNamespace(cfg.Namespace())
+	r.WithNamespace(Namespace(ctx, cfg))
 
 	objects := make([]k8s.Object, 0)
 	for _, dir := range dirs {
@@ -162,7 +227,7 @@ func getObjectsToImport(ctx context.Context, cfg *envconf.Config, dirs []string)
 				objects = append(objects, obj)
 				return nil
 			},
-			decoder.MutateNamespace(cfg.Namespace()),
+			MutateScope(ctx, cfg),
 		)
 
 		if err != nil {
@@ -196,30 +261,41 @@ func dumpProviders(ctx context.Context, t *testing.T, client *resources.Resource
 }
 
 func dumpWithCRDs(ctx context.Context, t *testing.T, cfg *envconf.Config, client *resources.Resources) {
+	relevantCRDs, err := managedCRDs(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("Dumping all managed resources")
+	dynamiq := dynamic.NewForConfigOrDie(cfg.Client().RESTConfig())
+	for _, crd := range relevantCRDs {
+		for _, version := range crd.Spec.Versions {
+			dumpResourcesOfCRDs(ctx, t, dynamiq, crd, version)
+		}
+	}
+}
+
+// managedCRDs returns the CRDs of the managed category
+func managedCRDs(ctx context.Context, client *resources.Resources) ([]v1extensions.CustomResourceDefinition, error) {
 	var crds v1extensions.CustomResourceDefinitionList
 
+	var err error
 	addToSchemeOnce.Do(func() {
-		if err := v1extensions.AddToScheme(client.GetScheme()); err != nil {
-			t.Fatal(err)
-		}
+		err = v1extensions.AddToScheme(client.GetScheme())
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	if err := client.List(ctx, &crds); err != nil {
-		t.Fatal(err)
+		return nil, err
 	}
-	t.Log("Dumping all managed resources")
 	var relevantCRDs []v1extensions.CustomResourceDefinition
 	for _, crd := range crds.Items {
 		if lo.Contains(crd.Spec.Names.Categories, "managed") {
 			relevantCRDs = append(relevantCRDs, crd)
 		}
 	}
-	dynamiq := dynamic.NewForConfigOrDie(cfg.Client().RESTConfig())
-	for _, crd := range relevantCRDs {
-		for _, version := range crd.Spec.Versions {
-			dumpResourcesOfCRDs(ctx, t, dynamiq, crd, version)
-		}
-	}
+	return relevantCRDs, nil
 }
 
 func getResourcesDynamically(
@@ -278,28 +354,62 @@ func DeleteResourcesFromDirs(ctx context.Context, t *testing.T, cfg *envconf.Con
 	if err != nil {
 		t.Fatal(objects)
 	}
+	start := time.Now()
 	if err = deleteObjects(ctx, cfg, manifestDirs); err != nil && !errors.IsNotFound(err) {
+		report.MarkFailed(ctx, err.Error())
 		t.Fatal(err)
 	}
 
+	deletions := map[string]report.Deletion{}
 	if err = wait.For(
-		conditions.New(r).ResourcesDeleted(&mockList{Items: objects}),
+		resourcesDeleted(r, objects, start, deletions),
 		timeout,
 	); err != nil {
+		report.MarkFailed(ctx, fmt.Sprintf("resources not deleted: %v", err))
 		t.Fatal(err)
 	}
+	report.RecordDeleted(ctx, time.Since(start), lo.Values(deletions)...)
 	return ctx
 }
 
+// resourcesDeleted checks if all objects are deleted and records the time each object was observed to be gone
+func resourcesDeleted(r *resources.Resources, objects []k8s.Object, start time.Time, deletions map[string]report.Deletion) apimachinerywait.ConditionWithContextFunc {
+	return func(ctx context.Context) (bool, error) {
+		done := true
+		for _, object := range objects {
+			identifier := Identifier(object)
+			if _, ok := deletions[identifier]; ok {
+				continue
+			}
+			current := &unstructured.Unstructured{}
+			current.SetGroupVersionKind(object.GetObjectKind().GroupVersionKind())
+			err := r.Get(ctx, object.GetName(), object.GetNamespace(), current)
+			if err == nil {
+				done = false
+				continue
+			}
+			if !errors.IsNotFound(err) {
+				return false, err
+			}
+			deletions[identifier] = report.Deletion{
+				Identifier:   identifier,
+				Kind:         object.GetObjectKind().GroupVersionKind().Kind,
+				TimeToDelete: time.Since(start),
+			}
+		}
+		return done, nil
+	}
+}
+
 func deleteObjects(ctx context.Context, cfg *envconf.Config, dirs []string) error {
 	r := resClient(cfg)
-	r.WithNamespace(cfg.Namespace())
+	r.WithNamespace(Namespace(ctx, cfg))
 
 	for _, dir := range dirs {
 		err := decoder.DecodeEachFile(
 			ctx, os.DirFS(dir), "*",
 			decoder.DeleteHandler(r),
-			decoder.MutateNamespace(cfg.Namespace()),
+			MutateScope(ctx, cfg),
 		)
 
 		if err != nil {
@@ -374,6 +484,11 @@ type ResourceTestConfig struct {
 	ObjFilterFunc     ObjFilterFunc
 	AdditionalSteps   map[string]func(context.Context, *testing.T, *envconf.Config) context.Context
 	ResourceDirectory string
+	// ProviderName, if set, makes the event recorder started in Setup record the events of the provider pods as well
+	ProviderName string
+	// History, if set, makes AssessCreate track the condition history of the resources,
+	// see WaitForResourcesToBeStable
+	History *xpconditions.HistoryOptions
 }
 
 // NewResourceTestConfig constructs a simple version of ResourceTestConfig
@@ -393,21 +508,63 @@ func DefaultCRFolder(kind string) string {
 }
 
 // Setup creates the resource in the cluster.
+// It starts an events.Recorder for the resources and the provider pods, which is stored in the context.
 func (r *ResourceTestConfig) Setup(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
 	t.Logf("Apply %s", r.Kind)
+	ctx = r.startEventRecorder(ctx, t, cfg)
 	ImportResources(ctx, t, cfg, r.ResourceDirectory)
 
 	return ctx
 }
 
-// Teardown does nothing for now but exists here for completeness.
+// Teardown stops the event recorder and writes the recorded events to the test output, if the feature failed.
 func (r *ResourceTestConfig) Teardown(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+	if recorder, ok := events.FromContext(ctx); ok {
+		if t.Failed() {
+			recorder.Log(t)
+		}
+		recorder.Stop()
+	}
 	return ctx
 }
 
+// startEventRecorder starts recording events, as events are only diagnostics, failures are logged only
+func (r *ResourceTestConfig) startEventRecorder(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+	objects, err := getObjectsToImport(ctx, cfg, []string{r.ResourceDirectory})
+	if err != nil {
+		t.Logf("Not recording events, could not decode resources: %v", err)
+		return ctx
+	}
+	recorder, err := events.NewRecorder(cfg.Client().RESTConfig())
+	if err != nil {
+		t.Logf("Not recording events: %v", err)
+		return ctx
+	}
+	recorder.Track(objects...)
+	if r.ProviderName != "" {
+		revision, err := provider.CurrentRevision(ctx, resClient(cfg), r.ProviderName)
+		if err != nil {
+			t.Logf("Not recording events of provider %s: %v", r.ProviderName, err)
+		} else {
+			recorder.TrackPods(provider.Namespace, revision+"-")
+		}
+	}
+	if err := recorder.Start(ctx); err != nil {
+		t.Logf("Not recording events: %v", err)
+		return ctx
+	}
+	return events.WithRecorder(ctx, recorder)
+}
+
 // AssessCreate checks that the resource was created successfully.
 func (r *ResourceTestConfig) AssessCreate(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
-	if err := WaitForResourcesToBeSynced(ctx, cfg, r.ResourceDirectory, r.ObjFilterFunc, wait.WithTimeout(time.Minute*5)); err != nil {
+	var err error
+	if r.History != nil {
+		err = WaitForResourcesToBeStable(ctx, cfg, r.ResourceDirectory, r.ObjFilterFunc, *r.History, wait.WithTimeout(time.Minute*5))
+	} else {
+		err = WaitForResourcesToBeSynced(ctx, cfg, r.ResourceDirectory, r.ObjFilterFunc, wait.WithTimeout(time.Minute*5))
+	}
+	if err != nil {
 		DumpManagedResources(ctx, t, cfg)
 		t.Fatal(err)
 	}
@@ -436,8 +593,8 @@ func ResumeResources(ctx context.Context, t *testing.T, cfg *envconf.Config, dir
 
 func updatePauseAnnotation(ctx context.Context, t *testing.T, cfg *envconf.Config, dir string, pauseValue string, decoderOptions ...decoder.DecodeOption) {
 	r := resClient(cfg)
-	r.WithNamespace(cfg.Namespace())
-	decoderOptions = append(decoderOptions, decoder.MutateNamespace(cfg.Namespace()))
+	r.WithNamespace(Namespace(ctx, cfg))
+	decoderOptions = append(decoderOptions, MutateScope(ctx, cfg))
 	err := decoder.DecodeEachFile(
 		ctx, os.DirFS(dir), "*",
 		PauseAnnotationHandler(r, pauseValue),
@@ -489,3 +646,34 @@ func ReplaceHandler(r *resources.Resources, opts ...resources.UpdateOption) deco
 		return r.Update(ctx, obj, opts...)
 	}
 }
+
+// CreateOrUpdateHandler returns a HandlerFunc that will create objects or update them, if they exist, see CreateOrUpdate
+func CreateOrUpdateHandler(r *resources.Resources) decoder.HandlerFunc {
+	return func(ctx context.Context, obj k8s.Object) error {
+		return CreateOrUpdate(ctx, r, obj)
+	}
+}
+
+// CreateOrUpdate creates the object or updates it, if it exists. Existing namespaces are left as they are
+// and the immutable cluster IP of existing services is kept.
+func CreateOrUpdate(ctx context.Context, r *resources.Resources, obj k8s.Object) error {
+	err := r.Create(ctx, obj)
+	if !errors.IsAlreadyExists(err) {
+		return err
+	}
+	if _, ok := obj.(*corev1.Namespace); ok {
+		return nil
+	}
+	existing, ok := obj.DeepCopyObject().(k8s.Object)
+	if !ok {
+		return fmt.Errorf("unexpected object %T", obj)
+	}
+	if err := r.Get(ctx, obj.GetName(), obj.GetNamespace(), existing); err != nil {
+		return err
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	if svc, ok := obj.(*corev1.Service); ok {
+		svc.Spec.ClusterIP = existing.(*corev1.Service).Spec.ClusterIP
+	}
+	return r.Update(ctx, obj)
+}
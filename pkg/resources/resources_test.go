@@ -0,0 +1,70 @@
+package resources
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/xpconditions"
+)
+
+func nopResource(name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("nop.crossplane.io/v1alpha1")
+	obj.SetKind("NopResource")
+	obj.SetName(name)
+	return obj
+}
+
+func Test_identifiers(t *testing.T) {
+	got := identifiers([]k8s.Object{nopResource("a"), nopResource("b")})
+	require.Equal(t, "nop.crossplane.io/v1alpha1, Kind=NopResource/a\nnop.crossplane.io/v1alpha1, Kind=NopResource/b\n", got)
+}
+
+func TestResourcesNotSyncedError(t *testing.T) {
+	waitErr := errors.New("context deadline exceeded")
+	err := &ResourcesNotSyncedError{
+		Err: waitErr,
+		Resources: []ResourceStatus{
+			{
+				Identifier: "nop.crossplane.io/v1alpha1, Kind=NopResource/a",
+				Found:      true,
+				Synced:     xpconditions.ConditionStatus{Type: "Synced", Status: "False", Reason: "ReconcileError", Message: "boom"},
+				Ready:      xpconditions.ConditionStatus{Type: "Ready"},
+				Events: []corev1.Event{
+					{Type: corev1.EventTypeWarning, Reason: "CannotObserveExternalResource", Message: "boom", Count: 3},
+				},
+			},
+			{
+				Identifier: "nop.crossplane.io/v1alpha1, Kind=NopResource/b",
+			},
+		},
+	}
+
+	require.ErrorIs(t, err, waitErr)
+	require.Equal(t, `2 resource(s) did not become synced and ready: context deadline exceeded
+  nop.crossplane.io/v1alpha1, Kind=NopResource/a: Synced=False (ReconcileError): boom, Ready=<none>
+    Warning CannotObserveExternalResource (x3): boom
+  nop.crossplane.io/v1alpha1, Kind=NopResource/b: not found`, err.Error())
+}
+
+func TestHasWarningSince(t *testing.T) {
+	injected := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	event := func(eventType string, at time.Time) corev1.Event {
+		return corev1.Event{Type: eventType, LastTimestamp: metav1.NewTime(at)}
+	}
+
+	require.False(t, hasWarningSince(nil, injected))
+	require.False(t, hasWarningSince([]corev1.Event{event(corev1.EventTypeWarning, injected.Add(-time.Second))}, injected), "warning before the fault")
+	require.False(t, hasWarningSince([]corev1.Event{event(corev1.EventTypeNormal, injected.Add(time.Second))}, injected), "normal event")
+	require.True(t, hasWarningSince([]corev1.Event{
+		event(corev1.EventTypeNormal, injected.Add(time.Second)),
+		event(corev1.EventTypeWarning, injected),
+	}, injected))
+}
@@ -0,0 +1,126 @@
+package resources
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/provider"
+)
+
+const (
+	annotationCreatePending   = "crossplane.io/external-create-pending"
+	annotationCreateSucceeded = "crossplane.io/external-create-succeeded"
+	annotationCreateFailed    = "crossplane.io/external-create-failed"
+
+	defaultRestartInterval = 10 * time.Second
+)
+
+// RestartTest configures a provider restart resilience test, see ResourceTestConfig.AssessCreateWithRestarts
+type RestartTest struct {
+	// ProviderName is the name of the provider whose controller pods are deleted
+	ProviderName string
+	// Restarts is the number of times the controller pods are deleted, defaults to 1
+	Restarts int
+	// Interval is the time before each restart, defaults to 10 seconds. Each restart waits for the replacement pods to
+	// become ready before the interval starts.
+	Interval time.Duration
+	// Timeout for the replacement pods of each restart and for all resources to become ready after the last restart,
+	// defaults to 5 minutes
+	Timeout time.Duration
+	// CheckDuplicates returns an error if duplicate external resources were created, e.g. by listing them
+	// through the API of the external system. It is optional.
+	CheckDuplicates func(ctx context.Context, cfg *envconf.Config) error
+}
+
+// AssessCreateWithRestarts replaces AssessCreate in a restart resilience test. While the resources imported by Setup
+// are being created, it deletes the provider controller pods one or more times and waits for their replacements, then asserts every resource still
+// becomes synced and ready, is not stuck with a pending creation and has no duplicate external resources.
+func (r *ResourceTestConfig) AssessCreateWithRestarts(rt RestartTest) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		restarts := max(rt.Restarts, 1)
+		interval := rt.Interval
+		if interval == 0 {
+			interval = defaultRestartInterval
+		}
+		timeout := rt.Timeout
+		if timeout == 0 {
+			timeout = 5 * time.Minute
+		}
+
+		res := resClient(cfg)
+		for i := 1; i <= restarts; i++ {
+			if !sleep(ctx, interval) {
+				t.Fatal(ctx.Err())
+			}
+			deleted, err := provider.DeletePods(ctx, res, rt.ProviderName)
+			if err != nil {
+				t.Fatalf("failed to restart provider %s: %v", rt.ProviderName, err)
+			}
+			if len(deleted) == 0 {
+				t.Fatalf("failed to restart provider %s: no controller pods found", rt.ProviderName)
+			}
+			t.Logf("Restart %d/%d of provider %s, deleted %d pod(s)", i, restarts, rt.ProviderName, len(deleted))
+			if err := provider.WaitForReplacement(ctx, res, rt.ProviderName, deleted, wait.WithTimeout(timeout)); err != nil {
+				t.Fatalf("provider %s has no ready pods after restart %d/%d: %v", rt.ProviderName, i, restarts, err)
+			}
+		}
+
+		if err := WaitForResourcesToBeSynced(ctx, cfg, r.ResourceDirectory, r.ObjFilterFunc, wait.WithTimeout(timeout)); err != nil {
+			if pending := r.pendingCreations(ctx, cfg); len(pending) > 0 {
+				t.Errorf("resources are stuck with a pending creation after the restart: %s", strings.Join(pending, ", "))
+			}
+			DumpManagedResources(ctx, t, cfg)
+			t.Fatal(err)
+		}
+		if rt.CheckDuplicates != nil {
+			if err := rt.CheckDuplicates(ctx, cfg); err != nil {
+				t.Errorf("duplicate external resources after restart of provider %s: %v", rt.ProviderName, err)
+			}
+		}
+		return ctx
+	}
+}
+
+// pendingCreations returns the identifiers of all resources, whose creation is pending
+func (r *ResourceTestConfig) pendingCreations(ctx context.Context, cfg *envconf.Config) []string {
+	objects, err := filteredObjects(ctx, cfg, r.ResourceDirectory, r.ObjFilterFunc)
+	if err != nil {
+		return nil
+	}
+	res := resClient(cfg)
+	var pending []string
+	for _, object := range objects {
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(object.GetObjectKind().GroupVersionKind())
+		if err := res.Get(ctx, object.GetName(), object.GetNamespace(), current); err != nil {
+			continue
+		}
+		if creationPending(current) {
+			pending = append(pending, Identifier(object))
+		}
+	}
+	return pending
+}
+
+// creationPending returns if the managed resource is marked as being created, without a later success or failure.
+// The provider can't tell if such a creation happened, e.g. because it was restarted in between, and doesn't proceed.
+func creationPending(object *unstructured.Unstructured) bool {
+	annotations := object.GetAnnotations()
+	pending, err := time.Parse(time.RFC3339, annotations[annotationCreatePending])
+	if err != nil {
+		return false
+	}
+	for _, annotation := range []string{annotationCreateSucceeded, annotationCreateFailed} {
+		if completed, err := time.Parse(time.RFC3339, annotations[annotation]); err == nil && !completed.Before(pending) {
+			return false
+		}
+	}
+	return true
+}
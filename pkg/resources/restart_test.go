@@ -0,0 +1,54 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_creationPending(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{name: "no annotations", want: false},
+		{
+			name:        "pending",
+			annotations: map[string]string{annotationCreatePending: "2024-01-01T00:00:00Z"},
+			want:        true,
+		},
+		{
+			name: "succeeded",
+			annotations: map[string]string{
+				annotationCreatePending:   "2024-01-01T00:00:00Z",
+				annotationCreateSucceeded: "2024-01-01T00:00:01Z",
+			},
+			want: false,
+		},
+		{
+			name: "failed",
+			annotations: map[string]string{
+				annotationCreatePending: "2024-01-01T00:00:00Z",
+				annotationCreateFailed:  "2024-01-01T00:00:00Z",
+			},
+			want: false,
+		},
+		{
+			name: "pending again after earlier success",
+			annotations: map[string]string{
+				annotationCreatePending:   "2024-01-01T00:01:00Z",
+				annotationCreateSucceeded: "2024-01-01T00:00:00Z",
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object := &unstructured.Unstructured{}
+			object.SetAnnotations(tt.annotations)
+			require.Equal(t, tt.want, creationPending(object))
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/events"
+	"github.com/crossplane-contrib/xp-testing/pkg/xpconditions"
+)
+
+// maxEventsPerObject limits the number of kubernetes events reported per object that did not converge
+const maxEventsPerObject = 5
+
+// ResourceStatus describes the state of an object that did not converge in time
+type ResourceStatus struct {
+	// Identifier of the object, see Identifier
+	Identifier string
+	// Found is false if the object couldn't be retrieved from the cluster
+	Found  bool
+	Synced xpconditions.ConditionStatus
+	Ready  xpconditions.ConditionStatus
+	// Events are the last few kubernetes events recorded for the object, oldest first
+	Events []corev1.Event
+}
+
+// String returns a human-readable, multi-line representation of the status
+func (s ResourceStatus) String() string {
+	b := strings.Builder{}
+	if !s.Found {
+		b.WriteString(fmt.Sprintf("%s: not found", s.Identifier))
+	} else {
+		b.WriteString(fmt.Sprintf("%s: %s, %s", s.Identifier, s.Synced, s.Ready))
+	}
+	for _, event := range s.Events {
+		b.WriteString(fmt.Sprintf("\n    %s %s (x%d): %s", event.Type, event.Reason, max(event.Count, 1), event.Message))
+	}
+	return b.String()
+}
+
+// ResourcesNotSyncedError is returned by WaitForResourcesToBeSynced if not all resources
+// became synced and ready in time. It lists every object that did not converge.
+type ResourcesNotSyncedError struct {
+	// Err is the error returned by the underlying wait
+	Err       error
+	Resources []ResourceStatus
+}
+
+// Error implements error
+func (e *ResourcesNotSyncedError) Error() string {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("%d resource(s) did not become synced and ready: %v", len(e.Resources), e.Err))
+	for _, res := range e.Resources {
+		b.WriteString("\n  ")
+		b.WriteString(res.String())
+	}
+	return b.String()
+}
+
+// Unwrap returns the error of the underlying wait
+func (e *ResourcesNotSyncedError) Unwrap() error {
+	return e.Err
+}
+
+// notSyncedError collects the status of every object which is not synced and ready yet
+func notSyncedError(ctx context.Context, res *resources.Resources, objects []k8s.Object, waitErr error) error {
+	xpc := xpconditions.New(res)
+	result := &ResourcesNotSyncedError{Err: waitErr}
+	for _, object := range objects {
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(object.GetObjectKind().GroupVersionKind())
+		status := ResourceStatus{Identifier: Identifier(object)}
+		if err := res.Get(ctx, object.GetName(), object.GetNamespace(), current); err != nil {
+			klog.V(4).Infof("Could not retrieve %s: %v", status.Identifier, err)
+		} else {
+			if xpc.IsManagedResourceReadyAndReady(current) {
+				continue
+			}
+			status.Found = true
+			status.Synced, _ = xpconditions.GetCondition(current, "Synced")
+			status.Ready, _ = xpconditions.GetCondition(current, "Ready")
+		}
+		status.Events = lastEvents(ctx, res, object, maxEventsPerObject)
+		result.Resources = append(result.Resources, status)
+	}
+	return result
+}
+
+// lastEvents returns up to limit of the most recent events involving the given object, oldest first
+func lastEvents(ctx context.Context, res *resources.Resources, object k8s.Object, limit int) []corev1.Event {
+	eventList := &corev1.EventList{}
+	selector := fields.Set{
+		"involvedObject.kind": object.GetObjectKind().GroupVersionKind().Kind,
+		"involvedObject.name": object.GetName(),
+	}
+	// events of cluster scoped objects are recorded in the default namespace, so the given
+	// resources are expected to be unscoped to look through all namespaces
+	err := res.List(ctx, eventList, resources.WithFieldSelector(selector.AsSelector().String()))
+	if err != nil {
+		klog.V(4).Infof("Could not list events of %s: %v", Identifier(object), err)
+		return nil
+	}
+	return events.Latest(eventList.Items, limit)
+}
+
+// WaitForResourcesToReportErrors waits until at least one of the managed resources reports an error, i.e. it is
+// Synced=False or got a warning event since the given time. It asserts that a fault injected at that time is observed.
+func WaitForResourcesToReportErrors(
+	ctx context.Context,
+	cfg *envconf.Config,
+	dir string,
+	objFilterFunc ObjFilterFunc,
+	since time.Time,
+	opts ...wait.Option,
+) error {
+	objects, err := filteredObjects(ctx, cfg, dir, objFilterFunc)
+	if err != nil {
+		return err
+	}
+	res := cfg.Client().Resources()
+	return wait.For(func(ctx context.Context) (bool, error) {
+		for _, object := range objects {
+			if reportsError(ctx, res, object, since) {
+				klog.V(4).Infof("%s reports an error", Identifier(object))
+				return true, nil
+			}
+		}
+		return false, nil
+	}, opts...)
+}
+
+func reportsError(ctx context.Context, res *resources.Resources, object k8s.Object, since time.Time) bool {
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(object.GetObjectKind().GroupVersionKind())
+	if err := res.Get(ctx, object.GetName(), object.GetNamespace(), current); err == nil {
+		if synced, ok := xpconditions.GetCondition(current, "Synced"); ok && synced.Status == string(corev1.ConditionFalse) {
+			return true
+		}
+	}
+	return hasWarningSince(lastEvents(ctx, res, object, maxEventsPerObject), since)
+}
+
+// hasWarningSince returns if any of the events is a warning observed at or after the given time
+func hasWarningSince(list []corev1.Event, since time.Time) bool {
+	for _, event := range list {
+		if event.Type == corev1.EventTypeWarning && !events.Time(event).Before(since) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package setup
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	log "k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/pkg/env"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+)
+
+// environment is one of several environments run by a Matrix or Pool, each with a cluster of its own
+type environment struct {
+	// label names the environment in cluster and test names
+	label   string
+	cfg     *envconf.Config
+	steps   configuration
+	ctx     context.Context
+	testEnv env.Environment
+	err     error
+}
+
+// environments runs the steps of several environments, like env.Environment.Run does for a single one
+type environments struct {
+	// parallel sets up and tears down the environments concurrently
+	parallel bool
+	list     []*environment
+}
+
+func (es *environments) add(label string, cfg *envconf.Config, steps configuration) {
+	es.list = append(es.list, &environment{label: label, cfg: cfg, steps: steps, ctx: context.Background()})
+}
+
+// run sets up the environments, runs the tests and tears the environments down, it returns the exit code of the tests,
+// which fail as well if a finish step failing the suite did, see ClusterSetup.Run
+func (es *environments) run(m interface{ Run() int }) (code int) {
+	defer func() {
+		es.finish()
+		if code == 0 && es.failed() {
+			log.Error("a finish step failed the suite")
+			code = 1
+		}
+	}()
+	es.forEach(func(e *environment) {
+		e.ctx, e.err = runSteps(e.ctx, e.cfg, e.steps.setup)
+	})
+	for _, e := range es.list {
+		if e.err != nil {
+			log.Errorf("setup of environment %s failed: %s", e.label, e.err)
+			return 1
+		}
+		var err error
+		if e.testEnv, err = env.NewWithContext(e.ctx, e.cfg); err != nil {
+			log.Errorf("failed to create environment %s: %s", e.label, err)
+			return 1
+		}
+		e.steps.applyHooks(e.testEnv)
+	}
+	return m.Run()
+}
+
+// finish runs the finish steps of all environments, failing steps are logged like by env.Environment.Run
+func (es *environments) finish() {
+	es.forEach(func(e *environment) {
+		for _, step := range e.steps.finish {
+			if step == nil {
+				continue
+			}
+			var err error
+			if e.ctx, err = step(e.ctx, e.cfg); err != nil {
+				log.V(2).ErrorS(err, "Cleanup failed", "environment", e.label)
+			}
+		}
+	})
+}
+
+// failed returns whether a finish step failing the suite failed in any environment
+func (es *environments) failed() bool {
+	for _, e := range es.list {
+		if e.steps.failed != nil && e.steps.failed.Load() {
+			return true
+		}
+	}
+	return false
+}
+
+// forEach calls fn for every environment, concurrently if the environments run in parallel
+func (es *environments) forEach(fn func(e *environment)) {
+	if !es.parallel {
+		for _, e := range es.list {
+			fn(e)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	for _, e := range es.list {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(e)
+		}()
+	}
+	wg.Wait()
+}
+
+// environmentSetup returns a copy of the setup for the environment of the given label, the ReportDir is a
+// subdirectory named after the label. The runtime configs and cassettes are copied, since the environments of
+// a parallel Matrix or a Pool set up concurrently and must not share them.
+func environmentSetup(s ClusterSetup, label string) ClusterSetup {
+	if s.ReportDir != "" {
+		s.ReportDir = filepath.Join(s.ReportDir, label)
+	}
+	if s.ControllerConfig != nil {
+		s.ControllerConfig = s.ControllerConfig.DeepCopy()
+	}
+	if s.DeploymentRuntimeConfig != nil {
+		s.DeploymentRuntimeConfig = s.DeploymentRuntimeConfig.DeepCopy()
+	}
+	if s.Cassettes != nil {
+		cassettes := *s.Cassettes
+		cassettes.NoProxy = slices.Clone(cassettes.NoProxy)
+		cassettes.ExtraArgs = slices.Clone(cassettes.ExtraArgs)
+		s.Cassettes = &cassettes
+	}
+	return s
+}
+
+// runSteps runs the steps in order and stops at the first error
+func runSteps(ctx context.Context, cfg *envconf.Config, steps []env.Func) (context.Context, error) {
+	for _, step := range steps {
+		if step == nil {
+			continue
+		}
+		var err error
+		if ctx, err = step(ctx, cfg); err != nil {
+			return ctx, err
+		}
+	}
+	return ctx, nil
+}
+
+// copyConfig returns a new config with the flag values of base, every environment needs its own config,
+// since creating a cluster changes the kubeconfig of the config
+func copyConfig(base *envconf.Config) *envconf.Config {
+	cfg := envconf.New().
+		WithNamespace(base.Namespace()).
+		WithLabels(base.Labels()).
+		WithSkipLabels(base.SkipLabels()).
+		WithKubeContext(base.KubeContext())
+	if r := base.FeatureRegex(); r != nil {
+		cfg.WithFeatureRegex(r.String())
+	}
+	if r := base.SkipFeatureRegex(); r != nil {
+		cfg.WithSkipFeatureRegex(r.String())
+	}
+	if r := base.AssessmentRegex(); r != nil {
+		cfg.WithAssessmentRegex(r.String())
+	}
+	if r := base.SkipAssessmentRegex(); r != nil {
+		cfg.WithSkipAssessmentRegex(r.String())
+	}
+	if base.ParallelTestEnabled() {
+		cfg.WithParallelTestEnabled()
+	}
+	if base.FailFast() {
+		cfg.WithFailFast()
+	}
+	if base.DryRunMode() {
+		cfg.WithDryRunMode()
+	}
+	if base.DisableGracefulTeardown() {
+		cfg.WithDisableGracefulTeardown()
+	}
+	return cfg
+}
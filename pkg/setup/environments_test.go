@@ -0,0 +1,125 @@
+package setup
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/e2e-framework/pkg/env"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/cassette"
+	"github.com/crossplane-contrib/xp-testing/pkg/vendored"
+)
+
+func Test_copyConfig(t *testing.T) {
+	base := envconf.New().
+		WithNamespace("test").
+		WithFeatureRegex("nop").
+		WithSkipAssessmentRegex("slow").
+		WithLabels(map[string][]string{"type": {"nop"}}).
+		WithFailFast()
+	cfg := copyConfig(base)
+	require.NotSame(t, base, cfg)
+	require.Equal(t, "test", cfg.Namespace())
+	require.Equal(t, "nop", cfg.FeatureRegex().String())
+	require.Equal(t, "slow", cfg.SkipAssessmentRegex().String())
+	require.Nil(t, cfg.AssessmentRegex())
+	require.Equal(t, map[string][]string{"type": {"nop"}}, cfg.Labels())
+	require.True(t, cfg.FailFast())
+	require.False(t, cfg.DryRunMode())
+}
+
+func Test_runSteps(t *testing.T) {
+	var called []string
+	step := func(name string, err error) env.Func {
+		return func(ctx context.Context, _ *envconf.Config) (context.Context, error) {
+			called = append(called, name)
+			return ctx, err
+		}
+	}
+	_, err := runSteps(context.Background(), envconf.New(), []env.Func{step("first", nil), nil, step("second", errors.New("failed")), step("third", nil)})
+	require.EqualError(t, err, "failed")
+	require.Equal(t, []string{"first", "second"}, called)
+}
+
+func Test_environments_forEach(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		es := environments{parallel: parallel, list: []*environment{{label: "1-20-0"}, {label: "2-0-0"}}}
+		var count atomic.Int32
+		es.forEach(func(*environment) { count.Add(1) })
+		require.Equal(t, int32(2), count.Load())
+	}
+}
+
+func Test_environments_finish(t *testing.T) {
+	var called []string
+	es := environments{}
+	es.add("2-0-0", envconf.New(), configuration{finish: []env.Func{
+		func(ctx context.Context, _ *envconf.Config) (context.Context, error) {
+			called = append(called, "failing")
+			return ctx, errors.New("failed")
+		},
+		nil,
+		func(ctx context.Context, _ *envconf.Config) (context.Context, error) {
+			called = append(called, "next")
+			return ctx, nil
+		},
+	}})
+	es.finish()
+	require.Equal(t, []string{"failing", "next"}, called, "finish continues after a failing step")
+}
+
+type exitCode int
+
+func (c exitCode) Run() int { return int(c) }
+
+func Test_environments_run_failSuite(t *testing.T) {
+	failing := func(ctx context.Context, _ *envconf.Config) (context.Context, error) {
+		return ctx, errors.New("leaked")
+	}
+	for name, tc := range map[string]struct {
+		code      exitCode
+		finish    env.Func
+		failSuite bool
+		want      int
+	}{
+		"passes":                  {want: 0},
+		"failing finish step":     {finish: failing, want: 0},
+		"failing suite":           {finish: failing, failSuite: true, want: 1},
+		"failing tests and suite": {code: 2, finish: failing, failSuite: true, want: 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			steps := configuration{failed: &atomic.Bool{}}
+			if tc.failSuite {
+				tc.finish = steps.failSuite(tc.finish)
+			}
+			steps.finish = []env.Func{tc.finish}
+			es := environments{}
+			es.add("2-0-0", envconf.New(), steps)
+			require.Equal(t, tc.want, es.run(tc.code))
+		})
+	}
+}
+
+func Test_environmentSetup(t *testing.T) {
+	base := ClusterSetup{
+		ReportDir:               "reports",
+		ControllerConfig:        &vendored.ControllerConfig{},
+		DeploymentRuntimeConfig: &vendored.DeploymentRuntimeConfig{},
+		Cassettes:               &cassette.Config{Dir: "cassettes", NoProxy: []string{"localhost"}},
+	}
+	s := environmentSetup(base, "1-20-0")
+	require.Equal(t, filepath.Join("reports", "1-20-0"), s.ReportDir)
+	require.NotSame(t, base.ControllerConfig, s.ControllerConfig)
+	require.NotSame(t, base.DeploymentRuntimeConfig, s.DeploymentRuntimeConfig)
+	require.NotSame(t, base.Cassettes, s.Cassettes)
+	require.Equal(t, base.Cassettes.Dir, s.Cassettes.Dir)
+
+	s.Cassettes.NoProxy[0] = "changed"
+	require.Equal(t, []string{"localhost"}, base.Cassettes.NoProxy)
+	require.Equal(t, "reports", base.ReportDir)
+}
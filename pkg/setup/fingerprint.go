@@ -0,0 +1,199 @@
+package setup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/docker/docker/client"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	log "k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
+	"sigs.k8s.io/e2e-framework/pkg/env"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/xpenvfuncs"
+)
+
+const (
+	fingerprintConfigMap = "xp-testing-fingerprint"
+	crossplaneComponent  = "crossplane"
+	providerComponent    = "provider"
+)
+
+// fingerprint maps the components of the setup to a hash of their configuration
+type fingerprint map[string]string
+
+// changed returns the components whose hash differs from the stored fingerprint
+func (f fingerprint) changed(stored fingerprint) map[string]bool {
+	changed := map[string]bool{}
+	for component, hash := range f {
+		changed[component] = stored[component] != hash
+	}
+	return changed
+}
+
+type fingerprintKey struct{}
+
+type fingerprintState struct {
+	current fingerprint
+	changed map[string]bool
+}
+
+// imageID returns the id of the image in the local docker daemon or the image itself, if it isn't available locally
+var imageID = func(ctx context.Context, img string) string {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return img
+	}
+	defer cli.Close()
+	inspect, err := cli.ImageInspect(ctx, img)
+	if err != nil {
+		return img
+	}
+	return inspect.ID
+}
+
+// fingerprint returns the fingerprint of the crossplane and provider configuration
+func (s *ClusterSetup) fingerprint(ctx context.Context) (fingerprint, error) {
+	crossplane, err := hash(struct {
+		Version               string
+		ChartRef              string
+		ChartRepoURL          string
+		Registry              string
+		SignatureVerification bool
+		CustomInstaller       bool
+		CustomInstallerID     string
+	}{
+		Version:               s.CrossplaneSetup.Version,
+		ChartRef:              s.CrossplaneSetup.ChartRef,
+		ChartRepoURL:          s.CrossplaneSetup.ChartRepoURL,
+		Registry:              s.CrossplaneSetup.Registry,
+		SignatureVerification: s.CrossplaneSetup.SignatureVerification,
+		CustomInstaller:       s.CrossplaneInstallFunc != nil,
+		CustomInstallerID:     s.CrossplaneInstallFingerprint,
+	})
+	if err != nil {
+		return nil, err
+	}
+	providerConfig := struct {
+		Name                    string
+		Package                 string
+		PackageID               string
+		ControllerImage         *string
+		ControllerImageID       string
+		ControllerConfig        interface{}
+		DeploymentRuntimeConfig interface{}
+	}{
+		Name:                    s.ProviderName,
+		Package:                 s.Images.Package,
+		PackageID:               imageID(ctx, s.Images.Package),
+		ControllerImage:         s.Images.ControllerImage,
+		ControllerConfig:        s.ControllerConfig,
+		DeploymentRuntimeConfig: s.deploymentRuntimeConfig(),
+	}
+	if s.Images.ControllerImage != nil {
+		providerConfig.ControllerImageID = imageID(ctx, *s.Images.ControllerImage)
+	}
+	provider, err := hash(providerConfig)
+	if err != nil {
+		return nil, err
+	}
+	return fingerprint{crossplaneComponent: crossplane, providerComponent: provider}, nil
+}
+
+func hash(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// checkFingerprint returns an env.Func, which compares the fingerprint of the setup to the one stored in a reused
+// cluster and keeps the changed components in the context, see whenChanged. On the first setup every component counts as changed.
+func (s *ClusterSetup) checkFingerprint(clusterName string, firstSetup bool) env.Func {
+	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+		current, err := s.fingerprint(ctx)
+		if err != nil {
+			return ctx, err
+		}
+		stored := fingerprint{}
+		if !firstSetup {
+			stored, err = readFingerprint(ctx, cfg)
+			if err != nil {
+				return ctx, err
+			}
+		}
+		changed := current.changed(stored)
+		if !firstSetup {
+			for _, component := range []string{crossplaneComponent, providerComponent} {
+				if changed[component] {
+					log.Infof("Updating %s of reused cluster %s, its configuration changed", component, clusterName)
+				} else {
+					log.Infof("Reusing %s of cluster %s", component, clusterName)
+				}
+			}
+		}
+		return context.WithValue(ctx, fingerprintKey{}, fingerprintState{current: current, changed: changed}), nil
+	}
+}
+
+// whenChanged returns an env.Func, which only runs fn if the component changed according to checkFingerprint
+func whenChanged(component string, fn env.Func) env.Func {
+	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+		state, ok := ctx.Value(fingerprintKey{}).(fingerprintState)
+		if ok && !state.changed[component] {
+			return ctx, nil
+		}
+		return fn(ctx, cfg)
+	}
+}
+
+// storeFingerprint is an env.Func, which stores the fingerprint determined by checkFingerprint in the cluster
+func storeFingerprint(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+	state, ok := ctx.Value(fingerprintKey{}).(fingerprintState)
+	if !ok {
+		return ctx, nil
+	}
+	r, err := resources.New(cfg.Client().RESTConfig())
+	if err != nil {
+		return ctx, err
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: fingerprintConfigMap, Namespace: xpenvfuncs.CrossplaneNamespace},
+		Data:       state.current,
+	}
+	err = r.Create(ctx, cm)
+	if apierrors.IsAlreadyExists(err) {
+		existing := &corev1.ConfigMap{}
+		if err := r.Get(ctx, fingerprintConfigMap, xpenvfuncs.CrossplaneNamespace, existing); err != nil {
+			return ctx, err
+		}
+		existing.Data = state.current
+		err = r.Update(ctx, existing)
+	}
+	return ctx, err
+}
+
+// readFingerprint returns the fingerprint stored in the cluster, which is empty if none is stored
+func readFingerprint(ctx context.Context, cfg *envconf.Config) (fingerprint, error) {
+	r, err := resources.New(cfg.Client().RESTConfig())
+	if err != nil {
+		return nil, err
+	}
+	cm := &corev1.ConfigMap{}
+	err = r.Get(ctx, fingerprintConfigMap, xpenvfuncs.CrossplaneNamespace, cm)
+	if apierrors.IsNotFound(err) {
+		log.Info("No fingerprint found in reused cluster, updating all components")
+		return fingerprint{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cm.Data, nil
+}
@@ -0,0 +1,131 @@
+package setup
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/images"
+)
+
+func stubImageID(t *testing.T, ids map[string]string) {
+	original := imageID
+	imageID = func(_ context.Context, img string) string {
+		return ids[img]
+	}
+	t.Cleanup(func() { imageID = original })
+}
+
+func TestClusterSetup_fingerprint(t *testing.T) {
+	ids := map[string]string{"provider-nop:latest": "sha256:1"}
+	stubImageID(t, ids)
+	s := &ClusterSetup{
+		ProviderName:    "provider-nop",
+		Images:          images.ProviderImages{Package: "provider-nop:latest"},
+		CrossplaneSetup: CrossplaneSetup{Version: "v2.0.0"},
+	}
+	initial, err := s.fingerprint(context.Background())
+	require.NoError(t, err)
+
+	unchanged, err := s.fingerprint(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{crossplaneComponent: false, providerComponent: false}, unchanged.changed(initial))
+
+	ids["provider-nop:latest"] = "sha256:2"
+	rebuilt, err := s.fingerprint(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{crossplaneComponent: false, providerComponent: true}, rebuilt.changed(initial))
+
+	s.CrossplaneSetup.Version = "v2.1.0"
+	upgraded, err := s.fingerprint(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{crossplaneComponent: true, providerComponent: false}, upgraded.changed(rebuilt))
+
+	require.Equal(t, map[string]bool{crossplaneComponent: true, providerComponent: true}, upgraded.changed(fingerprint{}))
+
+	s.CrossplaneInstallFunc = func(ctx context.Context, _ *envconf.Config) (context.Context, error) { return ctx, nil }
+	s.CrossplaneInstallFingerprint = "crossplane-2.1.0.tgz"
+	custom, err := s.fingerprint(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{crossplaneComponent: true, providerComponent: false}, custom.changed(upgraded))
+
+	s.CrossplaneInstallFingerprint = "crossplane-2.1.1.tgz"
+	customUpgraded, err := s.fingerprint(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{crossplaneComponent: true, providerComponent: false}, customUpgraded.changed(custom))
+}
+
+func TestWhenChanged(t *testing.T) {
+	stubImageID(t, nil)
+	var called []string
+	record := func(component string) func(context.Context, *envconf.Config) (context.Context, error) {
+		return func(ctx context.Context, _ *envconf.Config) (context.Context, error) {
+			called = append(called, component)
+			return ctx, nil
+		}
+	}
+
+	// without a checked fingerprint every component runs
+	_, err := whenChanged(crossplaneComponent, record(crossplaneComponent))(context.Background(), envconf.New())
+	require.NoError(t, err)
+	require.Equal(t, []string{crossplaneComponent}, called)
+
+	called = nil
+	ctx := context.WithValue(context.Background(), fingerprintKey{}, fingerprintState{
+		changed: map[string]bool{crossplaneComponent: false, providerComponent: true},
+	})
+	for _, component := range []string{crossplaneComponent, providerComponent} {
+		_, err := whenChanged(component, record(component))(ctx, envconf.New())
+		require.NoError(t, err)
+	}
+	require.Equal(t, []string{providerComponent}, called)
+}
+
+func TestClusterSetup_checkFingerprint_firstSetup(t *testing.T) {
+	stubImageID(t, nil)
+	s := &ClusterSetup{ProviderName: "provider-nop"}
+	// the first setup must not read the fingerprint from the cluster
+	ctx, err := s.checkFingerprint("test-cluster", true)(context.Background(), envconf.New())
+	require.NoError(t, err)
+	state := ctx.Value(fingerprintKey{}).(fingerprintState)
+	require.Equal(t, map[string]bool{crossplaneComponent: true, providerComponent: true}, state.changed)
+}
+
+func TestStoreFingerprint_unchecked(t *testing.T) {
+	_, err := storeFingerprint(context.Background(), envconf.New())
+	require.NoError(t, err)
+}
+
+func TestClusterSetup_installProvider_reusedCluster(t *testing.T) {
+	ids := map[string]string{"provider-nop:latest": "sha256:1"}
+	stubImageID(t, ids)
+	var deleted []string
+	original := deleteProvider
+	deleteProvider = func(_ context.Context, _ *envconf.Config, name string) error {
+		deleted = append(deleted, name)
+		return errors.New("deleted")
+	}
+	t.Cleanup(func() { deleteProvider = original })
+
+	s := &ClusterSetup{ProviderName: "provider-nop", Images: images.ProviderImages{Package: "provider-nop:latest"}}
+	stored, err := s.fingerprint(context.Background())
+	require.NoError(t, err)
+	reuse := func() error {
+		current, err := s.fingerprint(context.Background())
+		require.NoError(t, err)
+		ctx := context.WithValue(context.Background(), fingerprintKey{}, fingerprintState{current: current, changed: current.changed(stored)})
+		_, err = whenChanged(providerComponent, s.installProvider("test-cluster", false))(ctx, envconf.New())
+		return err
+	}
+
+	require.NoError(t, reuse())
+	require.Empty(t, deleted, "an unchanged provider is kept")
+
+	// the rebuilt image has the same reference, so the provider has to be deleted to pick it up
+	ids["provider-nop:latest"] = "sha256:2"
+	require.EqualError(t, reuse(), "deleted")
+	require.Equal(t, []string{"provider-nop"}, deleted)
+}
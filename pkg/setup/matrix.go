@@ -0,0 +1,109 @@
+package setup
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	log "k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+	"sigs.k8s.io/e2e-framework/support"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/envvar"
+)
+
+var nonLabelChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Matrix runs the same features against several crossplane versions, and optionally kubernetes versions, in one go test
+// invocation. Each version gets an environment with a cluster of its own, the results are labelled with the version:
+// features run as subtests named after it and reports are written to a subdirectory of ClusterSetup.ReportDir.
+//
+//	var matrix = &setup.Matrix{Setup: clusterSetup, Versions: []string{"1.20.0", "2.0.0"}, NewClusterProvider: ...}
+//	func TestMain(m *testing.M) { os.Exit(matrix.Run(m)) }
+//	func TestNop(t *testing.T) { matrix.Test(t, feature) }
+type Matrix struct {
+	// Setup is the setup of every environment, its CrossplaneSetup.Version is replaced by the version of the environment
+	Setup ClusterSetup
+	// Versions of crossplane to run the features against, defaults to the version of the Setup
+	Versions []string
+	// KubernetesVersions of the kind nodes to run the features against, e.g. 1.31.0, each is combined with each
+	// of the Versions, see ClusterSetup.KubernetesVersion
+	KubernetesVersions []string
+	// NewClusterProvider returns the cluster provider of an environment, e.g. a new kind.Cluster, since every
+	// environment needs a provider of its own
+	NewClusterProvider func() support.E2EClusterProvider
+	// Parallel sets up the environments and runs the features against them in parallel, the environments share the
+	// LocalRegistry and PackageLoader of the Setup like the clusters of a Pool
+	Parallel bool
+
+	environments environments
+}
+
+// Run sets up an environment per combination of the versions, runs the tests and tears the environments down.
+// It returns the exit code like env.Environment.Run.
+func (x *Matrix) Run(m *testing.M) int {
+	base, err := envconf.NewFromFlags()
+	if err != nil {
+		log.Errorf("failed to parse flags: %s", err)
+		return 1
+	}
+	reuseCluster := envvar.CheckEnvVarExists(reuseClusterEnv)
+	prefix := clusterName(reuseCluster)
+	x.environments = environments{parallel: x.Parallel}
+	versions := x.Versions
+	if len(versions) == 0 {
+		versions = []string{x.Setup.CrossplaneSetup.Version}
+	}
+	kubernetesVersions := x.KubernetesVersions
+	if len(kubernetesVersions) == 0 {
+		kubernetesVersions = []string{x.Setup.KubernetesVersion}
+	}
+	for _, version := range versions {
+		for _, kubernetesVersion := range kubernetesVersions {
+			label := x.label(version, kubernetesVersion)
+			s := environmentSetup(x.Setup, label)
+			s.CrossplaneSetup.Version = version
+			s.KubernetesVersion = kubernetesVersion
+			name := fmt.Sprintf("%s-%s", prefix, label)
+			x.environments.add(label, copyConfig(base), s.configuration(x.NewClusterProvider(), name, reuseCluster, label))
+		}
+	}
+	return x.environments.run(m)
+}
+
+// label returns the label of the environment, the kubernetes version is only part of it if the matrix spans KubernetesVersions
+func (x *Matrix) label(version string, kubernetesVersion string) string {
+	switch {
+	case len(x.KubernetesVersions) == 0:
+		return versionLabel(version)
+	case len(x.Versions) == 0:
+		return "k8s-" + versionLabel(kubernetesVersion)
+	default:
+		return versionLabel(version) + "-k8s-" + versionLabel(kubernetesVersion)
+	}
+}
+
+// Test runs the features against every environment, as subtest named after the crossplane and kubernetes version
+func (x *Matrix) Test(t *testing.T, testFeatures ...features.Feature) {
+	for _, e := range x.environments.list {
+		t.Run(e.label, func(t *testing.T) {
+			if e.testEnv == nil {
+				t.Skipf("environment %s is not set up", e.label)
+			}
+			if x.Parallel {
+				t.Parallel()
+			}
+			e.testEnv.Test(t, testFeatures...)
+		})
+	}
+}
+
+// versionLabel returns the version as DNS label, usable in cluster and test names
+func versionLabel(version string) string {
+	if version == "" {
+		return "latest"
+	}
+	return strings.Trim(nonLabelChars.ReplaceAllString(strings.ToLower(version), "-"), "-")
+}
@@ -0,0 +1,24 @@
+package setup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_versionLabel(t *testing.T) {
+	require.Equal(t, "latest", versionLabel(""))
+	require.Equal(t, "v2-0-0", versionLabel("v2.0.0"))
+	require.Equal(t, "1-20-0-rc-1", versionLabel("1.20.0-rc.1"))
+}
+
+func TestMatrix_Test_notSetUp(t *testing.T) {
+	x := &Matrix{environments: environments{list: []*environment{{label: "2-0-0"}}}}
+	x.Test(t)
+}
+
+func TestMatrix_label(t *testing.T) {
+	require.Equal(t, "2-0-0", (&Matrix{Versions: []string{"2.0.0"}}).label("2.0.0", ""))
+	require.Equal(t, "k8s-1-31-0", (&Matrix{KubernetesVersions: []string{"1.31.0"}}).label("", "1.31.0"))
+	require.Equal(t, "2-0-0-k8s-1-31-0", (&Matrix{Versions: []string{"2.0.0"}, KubernetesVersions: []string{"1.31.0"}}).label("2.0.0", "1.31.0"))
+}
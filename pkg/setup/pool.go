@@ -0,0 +1,108 @@
+package setup
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync/atomic"
+	"testing"
+
+	log "k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+	"sigs.k8s.io/e2e-framework/support"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/envvar"
+)
+
+// Pool shards the features of a large suite across a pool of identically configured clusters.
+// The clusters are set up and torn down concurrently, including DumpLogs per cluster, and the features assigned
+// to a cluster run in parallel like in an env.NewParallel environment.
+// The clusters share the LocalRegistry, which is started once, and the PackageLoader of the Setup, which
+// therefore has to be safe for concurrent use like the loaders of xpenvfuncs are.
+//
+//	var pool = &setup.Pool{Setup: clusterSetup, Size: 4, NewClusterProvider: ...}
+//	func TestMain(m *testing.M) { os.Exit(pool.Run(m)) }
+//	func TestKinds(t *testing.T) { pool.Test(t, features...) }
+type Pool struct {
+	// Setup is the setup of every cluster of the pool
+	Setup ClusterSetup
+	// Size is the number of clusters
+	Size int
+	// NewClusterProvider returns the cluster provider of a cluster, e.g. a new kind.Cluster, since every
+	// cluster needs a provider of its own
+	NewClusterProvider func() support.E2EClusterProvider
+	// AssignByLabel assigns features with the same value of this label to the same cluster, e.g. to keep features
+	// depending on each other together. Features without the label are assigned round-robin.
+	AssignByLabel string
+
+	environments environments
+	next         atomic.Uint64
+}
+
+// Run sets up the clusters of the pool, runs the tests and tears the clusters down.
+// It returns the exit code like env.Environment.Run.
+func (p *Pool) Run(m *testing.M) int {
+	base, err := envconf.NewFromFlags()
+	if err != nil {
+		log.Errorf("failed to parse flags: %s", err)
+		return 1
+	}
+	reuseCluster := envvar.CheckEnvVarExists(reuseClusterEnv)
+	prefix := clusterName(reuseCluster)
+	p.environments = environments{parallel: true}
+	for i := 0; i < p.size(); i++ {
+		label, s := p.shard(i)
+		name := fmt.Sprintf("%s-%s", prefix, label)
+		cfg := copyConfig(base).WithParallelTestEnabled()
+		p.environments.add(label, cfg, s.configuration(p.NewClusterProvider(), name, reuseCluster, label))
+	}
+	return p.environments.run(m)
+}
+
+// shard returns the label and setup of the i-th cluster of the pool
+func (p *Pool) shard(i int) (string, ClusterSetup) {
+	label := fmt.Sprintf("shard-%d", i)
+	return label, environmentSetup(p.Setup, label)
+}
+
+// Test assigns the features to the clusters of the pool and runs them concurrently,
+// as subtests named after the shard of the cluster
+func (p *Pool) Test(t *testing.T, testFeatures ...features.Feature) {
+	shards := p.assign(testFeatures)
+	for i, e := range p.environments.list {
+		if len(shards[i]) == 0 {
+			continue
+		}
+		t.Run(e.label, func(t *testing.T) {
+			if e.testEnv == nil {
+				t.Skipf("environment %s is not set up", e.label)
+			}
+			t.Parallel()
+			e.testEnv.TestInParallel(t, shards[i]...)
+		})
+	}
+}
+
+func (p *Pool) size() int {
+	if p.Size < 1 {
+		return 1
+	}
+	return p.Size
+}
+
+// assign returns the features per cluster, by the value of the AssignByLabel or round-robin
+func (p *Pool) assign(testFeatures []features.Feature) [][]features.Feature {
+	shards := make([][]features.Feature, p.size())
+	for _, feature := range testFeatures {
+		var shard int
+		if values := feature.Labels()[p.AssignByLabel]; p.AssignByLabel != "" && len(values) > 0 {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(values[0]))
+			shard = int(h.Sum32() % uint32(len(shards)))
+		} else {
+			shard = int((p.next.Add(1) - 1) % uint64(len(shards)))
+		}
+		shards[shard] = append(shards[shard], feature)
+	}
+	return shards
+}
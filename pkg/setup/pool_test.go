@@ -0,0 +1,92 @@
+package setup
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/e2e-framework/pkg/env"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/cassette"
+	"github.com/crossplane-contrib/xp-testing/pkg/vendored"
+)
+
+func names(shards [][]features.Feature) [][]string {
+	result := make([][]string, 0, len(shards))
+	for _, shard := range shards {
+		var shardNames []string
+		for _, feature := range shard {
+			shardNames = append(shardNames, feature.Name())
+		}
+		result = append(result, shardNames)
+	}
+	return result
+}
+
+func TestPool_assign(t *testing.T) {
+	t.Run("round-robin across Test calls", func(t *testing.T) {
+		p := &Pool{Size: 2}
+		require.Equal(t, [][]string{{"a", "c"}, {"b"}}, names(p.assign([]features.Feature{
+			features.New("a").Feature(), features.New("b").Feature(), features.New("c").Feature(),
+		})))
+		require.Equal(t, [][]string{{"e"}, {"d"}}, names(p.assign([]features.Feature{
+			features.New("d").Feature(), features.New("e").Feature(),
+		})))
+	})
+
+	t.Run("by label", func(t *testing.T) {
+		p := &Pool{Size: 3, AssignByLabel: "group"}
+		shards := p.assign([]features.Feature{
+			features.New("a").WithLabel("group", "network").Feature(),
+			features.New("b").WithLabel("group", "storage").Feature(),
+			features.New("c").WithLabel("group", "network").Feature(),
+			features.New("d").Feature(),
+		})
+		var total int
+		for _, shard := range names(shards) {
+			total += len(shard)
+			if slices.Contains(shard, "a") {
+				require.Contains(t, shard, "c", "features with the same label share a cluster")
+			}
+		}
+		require.Equal(t, 4, total)
+	})
+
+	t.Run("size defaults to one cluster", func(t *testing.T) {
+		require.Len(t, (&Pool{}).assign([]features.Feature{features.New("a").Feature()}), 1)
+	})
+}
+
+func TestPool_Test_notSetUp(t *testing.T) {
+	p := &Pool{Size: 1, environments: environments{list: []*environment{{label: "shard-0"}}}}
+	p.Test(t, features.New("a").Feature())
+}
+
+// TestPool_shard_concurrentSetup is meant to run with -race, the shards change their configs concurrently
+func TestPool_shard_concurrentSetup(t *testing.T) {
+	p := &Pool{Size: 4, Setup: ClusterSetup{
+		ReportDir:               "reports",
+		ControllerConfig:        &vendored.ControllerConfig{},
+		DeploymentRuntimeConfig: &vendored.DeploymentRuntimeConfig{},
+		Cassettes:               &cassette.Config{NoProxy: []string{"localhost"}},
+	}}
+	es := environments{parallel: true}
+	for i := 0; i < p.size(); i++ {
+		label, s := p.shard(i)
+		es.add(label, envconf.New(), configuration{setup: []env.Func{
+			func(ctx context.Context, _ *envconf.Config) (context.Context, error) {
+				s.ControllerConfig.Name = label
+				s.DeploymentRuntimeConfig.Name = label
+				s.Cassettes.NoProxy[0] = label
+				return ctx, nil
+			},
+		}})
+	}
+	require.Equal(t, 0, es.run(exitCode(0)))
+	require.Empty(t, p.Setup.ControllerConfig.Name)
+	require.Empty(t, p.Setup.DeploymentRuntimeConfig.Name)
+	require.Equal(t, []string{"localhost"}, p.Setup.Cassettes.NoProxy)
+}
@@ -2,21 +2,36 @@ package setup
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
-	"strings"
+	"path"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
 
-	"github.com/vladimirvivien/gexe"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	log "k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/wait"
 	"sigs.k8s.io/e2e-framework/pkg/env"
 	"sigs.k8s.io/e2e-framework/pkg/envconf"
 	"sigs.k8s.io/e2e-framework/pkg/envfuncs"
+	"sigs.k8s.io/e2e-framework/pkg/types"
+	"sigs.k8s.io/e2e-framework/support"
 	"sigs.k8s.io/e2e-framework/support/kind"
 
+	"github.com/crossplane-contrib/xp-testing/pkg/cassette"
+	"github.com/crossplane-contrib/xp-testing/pkg/cluster"
 	"github.com/crossplane-contrib/xp-testing/pkg/envvar"
 	"github.com/crossplane-contrib/xp-testing/pkg/vendored"
 
 	"github.com/crossplane-contrib/xp-testing/pkg/images"
+	"github.com/crossplane-contrib/xp-testing/pkg/mockserver"
+	"github.com/crossplane-contrib/xp-testing/pkg/provider"
+	"github.com/crossplane-contrib/xp-testing/pkg/report"
+	"github.com/crossplane-contrib/xp-testing/pkg/resources"
 	"github.com/crossplane-contrib/xp-testing/pkg/xpenvfuncs"
 )
 
@@ -25,6 +40,9 @@ const (
 	clusterNameEnv  = "E2E_CLUSTER_NAME"
 	defaultPrefix   = "e2e"
 
+	// providerDeletionTimeout is the time the provider of a reused cluster has to be deleted before it is reinstalled
+	providerDeletionTimeout = 5 * time.Minute
+
 	// DockerRegistry is the default docker registry, which can be passed to the crossplane setup (prior to v2)
 	DockerRegistry = "index.docker.io"
 )
@@ -57,6 +75,14 @@ type CrossplaneSetup struct {
 	// https://charts.crossplane.io/stable helm repository URL. Ignored when
 	// ChartRef is set.
 	ChartRepoURL string
+	// ImageConfigs are applied right after crossplane is installed, the replacement of Registry in crossplane v2,
+	// e.g. to rewrite package images to a mirror or to pull them with a secret,
+	// see xpenvfuncs.RewriteImages and xpenvfuncs.PullSecret
+	ImageConfigs []vendored.ImageConfig
+	// PullSecrets are created in the crossplane namespace before the ImageConfigs, see xpenvfuncs.DockerConfigSecret
+	PullSecrets []*corev1.Secret
+	// SignatureVerification enables the verification of package signatures configured by ImageConfigs
+	SignatureVerification bool
 }
 
 // Options returns configurtion as options pattern to be passed on to installation process step
@@ -68,20 +94,49 @@ func (c CrossplaneSetup) Options() []xpenvfuncs.CrossplaneOpt {
 	if c.Registry != "" {
 		opts = append(opts, xpenvfuncs.Registry(c.Registry))
 	}
+	if c.SignatureVerification {
+		opts = append(opts, xpenvfuncs.EnableSignatureVerification())
+	}
 	return opts
 }
 
+// applyImageConfigs returns the env.Func that applies the pull secrets and image configs
+func (c CrossplaneSetup) applyImageConfigs() env.Func {
+	return xpenvfuncs.ApplyImageConfigs(c.PullSecrets, c.ImageConfigs...)
+}
+
+// the crossplane installation entry points, replaced by tests
+var (
+	installCrossplane          = xpenvfuncs.InstallCrossplane
+	installCrossplaneFromChart = xpenvfuncs.InstallCrossplaneFromChart
+	installCrossplaneFromRepo  = xpenvfuncs.InstallCrossplaneFromRepo
+)
+
 // installCrossplaneFunc returns the env.Func that performs the crossplane
 // installation, branching on ChartRef / ChartRepoURL to pick the right entry
 // point. ChartRef takes precedence over ChartRepoURL when both are set.
 func (c CrossplaneSetup) installCrossplaneFunc(clusterName string) env.Func {
 	switch {
 	case c.ChartRef != "":
-		return xpenvfuncs.InstallCrossplaneFromChart(clusterName, c.ChartRef, c.Options()...)
+		return installCrossplaneFromChart(clusterName, c.ChartRef, c.Options()...)
 	case c.ChartRepoURL != "":
-		return xpenvfuncs.InstallCrossplaneFromRepo(clusterName, c.ChartRepoURL, c.Options()...)
+		return installCrossplaneFromRepo(clusterName, c.ChartRepoURL, c.Options()...)
 	default:
-		return xpenvfuncs.InstallCrossplane(clusterName, c.Options()...)
+		return installCrossplane(clusterName, c.Options()...)
+	}
+}
+
+// UpgradeCrossplaneFunc returns the env.Func that upgrades an existing crossplane
+// installation to this setup via `helm upgrade`, honouring ChartRef / ChartRepoURL
+// the same way the installation does.
+func (c CrossplaneSetup) UpgradeCrossplaneFunc(clusterName string) env.Func {
+	switch {
+	case c.ChartRef != "":
+		return xpenvfuncs.UpgradeCrossplaneFromChart(clusterName, c.ChartRef, c.Options()...)
+	case c.ChartRepoURL != "":
+		return xpenvfuncs.UpgradeCrossplaneFromRepo(clusterName, c.ChartRepoURL, c.Options()...)
+	default:
+		return xpenvfuncs.UpgradeCrossplane(clusterName, c.Options()...)
 	}
 }
 
@@ -93,19 +148,21 @@ type ClusterSetup struct {
 	// CrossplaneInstallFunc, if non-nil, replaces the bundled InstallCrossplane
 	// step in Configure. The function is invoked once per Configure call (when
 	// firstSetup is true, i.e., not reusing an existing cluster) before
-	// InstallCrossplaneProvider runs.
+	// InstallCrossplaneProvider runs. On a reused cluster it is invoked again
+	// if the CrossplaneSetup or CrossplaneInstallFingerprint changed, so it needs
+	// to handle an existing installation.
 	//
 	// The replacement is responsible for satisfying the package-cache contract:
 	//
 	//   1. Create namespace "crossplane-system".
-	//   2. Set up a PV+PVC backed by /cache/xpkg on the kind control-plane node
+	//   2. Set up a PV+PVC backed by /cache/xpkg on the control-plane node
 	//      (see SetupCrossplanePackageCache when that helper is exported, or
 	//      replicate inline using xpenvfuncs.setupCrossplanePackageCache's
 	//      logic).
 	//   3. `helm install crossplane <chartRef> --set packageCache.pvc=<name>`
 	//      where <name> matches the PVC created in step 2.
 	//
-	// Without these, InstallCrossplaneProvider's loadCrossplanePackageToCluster
+	// Without these, InstallCrossplaneProvider's NodeCopyLoader
 	// deposits the xpkg into a host directory the Crossplane pod can't read,
 	// and providers fail Healthy with:
 	//
@@ -121,29 +178,154 @@ type ClusterSetup struct {
 	// The CrossplaneSetup.Version / Registry / ChartRef / ChartRepoURL fields
 	// are ignored when CrossplaneInstallFunc is set — the caller has full
 	// control.
-	CrossplaneInstallFunc   env.Func
+	CrossplaneInstallFunc env.Func
+	// CrossplaneInstallFingerprint identifies what the CrossplaneInstallFunc installs, e.g. the chart version
+	// or digest. The function can't be compared between runs, so on a reused cluster it is only invoked again
+	// if this fingerprint changed.
+	CrossplaneInstallFingerprint string
+	// PackageLoader loads the provider package and controller image into the cluster,
+	// defaults to the strategy of the cluster backend, see cluster.DefaultPackageLoader
+	PackageLoader xpenvfuncs.PackageLoader
+	// LocalRegistry starts a local registry attached to the kind network, the package and controller image are pushed to
+	// and pulled from it, unless a PackageLoader is set
+	LocalRegistry *xpenvfuncs.LocalRegistry
+	// ClusterConfigPath is the configuration file of the cluster backend the cluster is created with,
+	// e.g. a kind or k3d config with additional nodes or port mappings
+	ClusterConfigPath string
+	// KindConfig is the configuration of a kind cluster, an alternative to a ClusterConfigPath.
+	// With either, the package cache /cache/xpkg of all kind nodes is mounted from a shared directory of the host,
+	// so crossplane finds locally loaded packages independent of the node it is scheduled to.
+	KindConfig *cluster.KindConfig
+	// KubernetesVersion of the kind nodes, e.g. 1.31.0, sets the node image of all nodes, see cluster.KindNodeImage
+	KubernetesVersion       string
 	ControllerConfig        *vendored.ControllerConfig
 	DeploymentRuntimeConfig *vendored.DeploymentRuntimeConfig
 	ProviderCredential      *ProviderCredentials
 	AddToSchemaFuncs        []func(s *runtime.Scheme) error
 	postSetupFuncs          []ClusterAwareFunc
 	ProviderConfigDir       *string
+	// WatchConditions enables the informer based xpconditions.Watcher for all waits of this library,
+	// which reduces the load on the API server for suites with many managed resources.
+	WatchConditions bool
+	// CollectProviderLogs streams the logs of the provider pods of each feature into logs/<feature-name>/,
+	// logs/<label>/<feature-name>/ for the environments of a Matrix or Pool, and writes their tail to the test output,
+	// if the feature fails
+	CollectProviderLogs bool
+	// IsolateFeatures creates a namespace per feature, which the resources of the feature are imported into.
+	// Cluster scoped resources are labelled with the feature ID, both are deleted after the feature and
+	// resources blocking their deletion fail the test, see resources.IsolateFeature and resources.CleanupFeature.
+	// Namespaced managed resources need to reference a ClusterProviderConfig, since the ProviderConfig is
+	// applied into the namespace of the environment.
+	IsolateFeatures bool
+	// LeakDetection fails the suite on managed resources, which are left at Finish but didn't exist at suite start,
+	// and optionally force-cleans them, see resources.DetectLeakedResources and Run
+	LeakDetection *resources.LeakOptions
+	// ReportDir enables recording of all features, e.g. the tested kinds and the time to Ready per object,
+	// which are written as JUnit XML and JSON report into the directory at Finish, see report.Reporter
+	ReportDir string
+	// PerformanceBaseline is a metrics file the time to Synced, Ready and deletion per kind are compared against,
+	// the suite fails at Finish if any percentile exceeds it by more than PerformanceTolerance. Requires ReportDir.
+	// If the file doesn't exist, it is created from the metrics of the run.
+	PerformanceBaseline string
+	// PerformanceTolerance is the relative slowdown compared to PerformanceBaseline that is accepted, e.g. 0.2 for 20%
+	PerformanceTolerance float64
+	// MockServers are deployed into the cluster before the ProviderConfig is applied,
+	// which can reference their URL as template, e.g. {{ index .MockServers "<name>" }}.
+	// The recorded calls of WireMock servers are reset before each feature and logged after it.
+	MockServers []mockserver.MockServer
+	// Cassettes routes the requests of the provider through an in-cluster proxy, which records them per feature
+	// or replays them, see cassette.Config. The proxy is injected into the DeploymentRuntimeConfig.
+	Cassettes *cassette.Config
+
+	// failed is set by the Finish steps of the environment configured by Configure, which fail the suite
+	failed *atomic.Bool
 }
 
-// Configure optionally creates the kind cluster and takes care about the rest of the setup,
+// Configure optionally creates the cluster and takes care about the rest of the setup,
 // There are two relevant Environment Variables that influence its behavior
 // * E2E_REUSE_CLUSTER: if set, the cluster, crossplane and provider will be reused and not deleted after test.
+// A fingerprint of the setup is stored in the cluster, crossplane is upgraded and the provider is deleted and
+// installed again if theirs changed.
 // If set, CLUSTER_NAME will be ignored
 // * E2E_CLUSTER_NAME: overwrites the cluster name
-// Currently requires a kind.Cluster, only for kind we can detect if a cluster is reusable
-// nolint:interfacer
-func (s *ClusterSetup) Configure(testEnv env.Environment, cluster *kind.Cluster) string {
+// The cluster can be any support.E2EClusterProvider, e.g. a kind.Cluster, a k3d.Cluster or a cluster.Kubeconfig
+// of an existing cluster, see cluster.Exists for the backends that can be reused
+func (s *ClusterSetup) Configure(testEnv env.Environment, clusterProvider support.E2EClusterProvider) string {
 	reuseCluster := envvar.CheckEnvVarExists(reuseClusterEnv)
 	log.V(4).Info("Reusing cluster: ", reuseCluster)
 	name := clusterName(reuseCluster)
+	c := s.configuration(clusterProvider, name, reuseCluster, "")
+	c.apply(testEnv)
+	s.failed = c.failed
+	return name
+}
+
+// Run runs the tests of the environment configured by Configure and returns the exit code like env.Environment.Run.
+// Other than env.Environment.Run, which only logs failing Finish steps, it fails if a Finish step failing the suite
+// did, e.g. the leak detection found leaked resources.
+//
+//	func TestMain(m *testing.M) {
+//		clusterSetup.Configure(testenv, kind.NewCluster(name))
+//		os.Exit(clusterSetup.Run(m, testenv))
+//	}
+func (s *ClusterSetup) Run(m *testing.M, testEnv env.Environment) int {
+	code := testEnv.Run(m)
+	if code == 0 && s.failed != nil && s.failed.Load() {
+		log.Error("a finish step failed the suite")
+		return 1
+	}
+	return code
+}
+
+// configuration holds the steps of an environment set up by a ClusterSetup
+type configuration struct {
+	setup      []env.Func
+	beforeEach []types.FeatureEnvFunc
+	afterEach  []types.FeatureEnvFunc
+	finish     []env.Func
+	// failed is set, if a finish step wrapped by failSuite fails
+	failed *atomic.Bool
+}
+
+// failSuite wraps the finish step, so its error fails the suite, see ClusterSetup.Run
+func (c configuration) failSuite(fn env.Func) env.Func {
+	if fn == nil {
+		return nil
+	}
+	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+		ctx, err := fn(ctx, cfg)
+		if err != nil {
+			c.failed.Store(true)
+		}
+		return ctx, err
+	}
+}
+
+// apply registers the steps at the environment
+func (c configuration) apply(testEnv env.Environment) {
+	testEnv.Setup(c.setup...)
+	c.applyHooks(testEnv)
+	testEnv.Finish(c.finish...)
+}
+
+// applyHooks registers the feature hooks at the environment
+func (c configuration) applyHooks(testEnv env.Environment) {
+	if len(c.beforeEach) > 0 {
+		testEnv.BeforeEachFeature(c.beforeEach...)
+	}
+	if len(c.afterEach) > 0 {
+		testEnv.AfterEachFeature(c.afterEach...)
+	}
+}
+
+// configuration returns the steps to set up the cluster of the given name, run the features and tear it down.
+// The logs of the cluster are written to logs/post-tests/<label> at the end, the provider logs of the features
+// to logs/<label>/<feature-name>, label is empty unless the environment is one of several.
+func (s *ClusterSetup) configuration(clusterProvider support.E2EClusterProvider, name string, reuseCluster bool, label string) configuration {
+	c := configuration{failed: &atomic.Bool{}}
 	log.V(4).Info("Cluster name: ", name)
 	firstSetup := true
-	if reuseCluster && kindClusterExists(name) {
+	if reuseCluster && cluster.Exists(clusterProvider, name) {
 		firstSetup = false
 	}
 
@@ -151,52 +333,314 @@ func (s *ClusterSetup) Configure(testEnv env.Environment, cluster *kind.Cluster)
 	// Setup uses pre-defined funcs to create kind cluster
 	// and create a namespace for the environment
 
-	testEnv.Setup(
+	c.setup = append(c.setup,
 		xpenvfuncs.ValidateTestSetup(xpenvfuncs.ValidateTestSetupOptions{
 			CrossplaneVersion: s.CrossplaneSetup.Version,
 			PackageRegistry:   s.CrossplaneSetup.Registry,
 			ControllerConfig:  s.ControllerConfig,
 		}),
-		envfuncs.CreateCluster(cluster, name),
+		s.createCluster(clusterProvider, name),
+		s.startLocalRegistry(clusterProvider, name),
+		xpenvfuncs.WithPackageLoader(s.packageLoader(clusterProvider)),
 	)
 	for _, claFunc := range s.postSetupFuncs {
-		testEnv.Setup(claFunc(name))
-	}
-	testEnv.Setup(
-		xpenvfuncs.Conditional(
-			xpenvfuncs.Compose(
-				s.CrossplaneSetup.installCrossplaneFunc(name),
-				xpenvfuncs.InstallCrossplaneProvider(
-					name, xpenvfuncs.InstallCrossplaneProviderOptions{
-						Name:                    s.ProviderName,
-						Package:                 s.Images.Package,
-						ControllerImage:         s.Images.ControllerImage,
-						ControllerConfig:        s.ControllerConfig,
-						DeploymentRuntimeConfig: s.DeploymentRuntimeConfig,
-					}),
-			), firstSetup),
+		c.setup = append(c.setup, claFunc(name))
+	}
+	crossplaneFunc := s.installCrossplaneFunc(name)
+	if !firstSetup {
+		crossplaneFunc = s.upgradeCrossplaneFunc(name)
+	}
+	c.setup = append(c.setup,
+		s.checkFingerprint(name, firstSetup),
+		xpenvfuncs.Conditional(xpenvfuncs.StartConditionWatcher, s.WatchConditions),
+		whenChanged(crossplaneComponent, crossplaneFunc),
+		s.CrossplaneSetup.applyImageConfigs(),
+		s.localRegistryImageConfigs(),
+		s.setupCassettes(clusterProvider, name),
+		whenChanged(providerComponent, s.installProvider(name, firstSetup)),
+		storeFingerprint,
+		xpenvfuncs.CreateTestNamespace,
 		setupProviderCredentials(s),
-		xpenvfuncs.ApplyProviderConfigFromDir(orDefault(s.ProviderConfigDir, "./provider")),
+		mockserver.Deploy(s.MockServers...),
+		s.applyProviderConfig(),
 		xpenvfuncs.LoadSchemas(s.AddToSchemaFuncs...),
-		xpenvfuncs.AwaitCRDsEstablished)
+		xpenvfuncs.AwaitCRDsEstablished,
+		s.snapshotManagedResources())
+
+	if s.IsolateFeatures {
+		c.beforeEach = append(c.beforeEach, resources.IsolateFeature)
+	}
+
+	if len(s.MockServers) > 0 {
+		c.beforeEach = append(c.beforeEach, mockserver.ResetRequests(s.MockServers...))
+		c.afterEach = append(c.afterEach, mockserver.LogRequests(s.MockServers...))
+	}
+
+	if cassettes := s.cassettes(clusterProvider); cassettes != nil {
+		c.beforeEach = append(c.beforeEach, cassettes.Start(name))
+		c.afterEach = append(c.afterEach, cassettes.Stop(name))
+	}
+
+	if s.CollectProviderLogs {
+		c.beforeEach = append(c.beforeEach, provider.StartLogCollectionIn(s.ProviderName, label))
+		c.afterEach = append(c.afterEach, provider.StopLogCollection(provider.DefaultLogTailLines))
+	}
+
+	var writeReport env.Func
+	if s.ReportDir != "" {
+		reporter := report.NewReporter(s.Images.Version(), s.CrossplaneSetup.Version)
+		if s.PerformanceBaseline != "" {
+			reporter.CompareTo(s.PerformanceBaseline, s.PerformanceTolerance)
+		}
+		c.beforeEach = append(c.beforeEach, reporter.StartFeature())
+		c.afterEach = append(c.afterEach, reporter.EndFeature())
+		writeReport = reporter.WriteReport(s.ReportDir)
+	}
+
+	if s.IsolateFeatures {
+		// registered last, so the other hooks still see the resources of the feature
+		c.afterEach = append(c.afterEach, resources.CleanupFeature(resources.DefaultFeatureCleanupTimeout))
+	}
 
 	// Finish uses pre-defined funcs to
-	// remove namespace, then delete cluster
-	testEnv.Finish(
-		xpenvfuncs.DumpLogs(name, "post-tests"),
+	// remove namespace of a cluster, which is kept, then delete cluster
+	keepCluster := reuseCluster || !cluster.Owned(clusterProvider)
+	c.finish = append(c.finish,
+		writeReport,
+		xpenvfuncs.DumpLogs(name, path.Join("post-tests", label)),
+		xpenvfuncs.StopConditionWatcher,
+		c.failSuite(s.detectLeakedResources()),
+		xpenvfuncs.Conditional(xpenvfuncs.DeleteTestNamespace, keepCluster),
 		xpenvfuncs.Conditional(envfuncs.DestroyCluster(name), !reuseCluster),
+		xpenvfuncs.Conditional(s.removeKindPackageCache(clusterProvider, name), !reuseCluster),
+		xpenvfuncs.Conditional(s.removeLocalRegistry(), !reuseCluster),
 	)
-	return name
+	return c
+}
+
+// installProvider returns the env.Func that installs the provider. A provider of a reused cluster is deleted first,
+// since crossplane keeps running a rebuilt package or controller image of an unchanged reference otherwise.
+func (s *ClusterSetup) installProvider(clusterName string, firstSetup bool) env.Func {
+	install := xpenvfuncs.InstallCrossplaneProvider(
+		clusterName, xpenvfuncs.InstallCrossplaneProviderOptions{
+			Name:                    s.ProviderName,
+			Package:                 s.Images.Package,
+			ControllerImage:         s.Images.ControllerImage,
+			ControllerConfig:        s.ControllerConfig,
+			DeploymentRuntimeConfig: s.deploymentRuntimeConfig(),
+		})
+	if firstSetup {
+		return install
+	}
+	return xpenvfuncs.Compose(
+		func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+			log.Infof("Deleting provider %s of reused cluster %s before reinstalling it", s.ProviderName, clusterName)
+			return ctx, deleteProvider(ctx, cfg, s.ProviderName)
+		},
+		install,
+	)
+}
+
+// deleteProvider deletes the provider and waits until it is gone, replaced by tests
+var deleteProvider = func(ctx context.Context, cfg *envconf.Config, name string) error {
+	return provider.Delete(ctx, cfg.Client().Resources(), name, wait.WithTimeout(providerDeletionTimeout))
 }
 
 // installCrossplaneFunc returns the env.Func that installs the Crossplane
 // control plane. When CrossplaneInstallFunc is non-nil, it takes precedence
-// over the bundled InstallCrossplane.
+// over the installation of CrossplaneSetup.
 func (s *ClusterSetup) installCrossplaneFunc(clusterName string) env.Func {
 	if s.CrossplaneInstallFunc != nil {
 		return s.CrossplaneInstallFunc
 	}
-	return xpenvfuncs.InstallCrossplane(clusterName, s.CrossplaneSetup.Options()...)
+	return s.CrossplaneSetup.installCrossplaneFunc(clusterName)
+}
+
+// upgradeCrossplaneFunc returns the env.Func that updates the Crossplane control plane of a reused cluster.
+// A CrossplaneInstallFunc is invoked again and needs to handle an existing installation.
+func (s *ClusterSetup) upgradeCrossplaneFunc(clusterName string) env.Func {
+	if s.CrossplaneInstallFunc != nil {
+		return s.CrossplaneInstallFunc
+	}
+	return s.CrossplaneSetup.UpgradeCrossplaneFunc(clusterName)
+}
+
+// packageLoader returns the configured PackageLoader or the default of the cluster backend
+func (s *ClusterSetup) packageLoader(clusterProvider support.E2EClusterProvider) xpenvfuncs.PackageLoader {
+	if s.PackageLoader != nil {
+		return s.PackageLoader
+	}
+	if s.LocalRegistry != nil {
+		return s.LocalRegistry
+	}
+	return cluster.DefaultPackageLoader(clusterProvider)
+}
+
+// createCluster returns the env.Func that creates the cluster, with the ClusterConfigPath if configured
+func (s *ClusterSetup) createCluster(clusterProvider support.E2EClusterProvider, name string) env.Func {
+	if s.customKindCluster(clusterProvider) {
+		return s.createKindCluster(clusterProvider, name)
+	}
+	if s.ClusterConfigPath == "" {
+		return envfuncs.CreateCluster(clusterProvider, name)
+	}
+	return envfuncs.CreateClusterWithConfig(clusterProvider, name, s.ClusterConfigPath)
+}
+
+// customKindCluster returns whether the cluster is a kind cluster with a custom configuration
+func (s *ClusterSetup) customKindCluster(clusterProvider support.E2EClusterProvider) bool {
+	_, isKind := clusterProvider.(*kind.Cluster)
+	return isKind && (s.ClusterConfigPath != "" || s.KindConfig != nil || s.KubernetesVersion != "")
+}
+
+// createKindCluster returns the env.Func that creates a kind cluster with the KindConfig or the ClusterConfigPath,
+// the node image of the KubernetesVersion and the package cache mounted from kindPackageCacheDir
+func (s *ClusterSetup) createKindCluster(clusterProvider support.E2EClusterProvider, name string) env.Func {
+	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+		config, err := s.kindConfig()
+		if err != nil {
+			return ctx, err
+		}
+		nodeImage := ""
+		if s.KubernetesVersion != "" {
+			nodeImage = cluster.KindNodeImage(s.KubernetesVersion)
+		}
+		cacheDir := kindPackageCacheDir(name)
+		if err := os.MkdirAll(cacheDir, 0o777); err != nil {
+			return ctx, err
+		}
+		// the crossplane pod writes to the cache, independent of the umask
+		if err := os.Chmod(cacheDir, 0o777); err != nil {
+			return ctx, err
+		}
+		config, err = cluster.PatchKindConfig(config, nodeImage, cluster.KindMount{HostPath: cacheDir, ContainerPath: xpenvfuncs.PackageCacheMount})
+		if err != nil {
+			return ctx, err
+		}
+		configPath := filepath.Join(filepath.Dir(cacheDir), "kind-config.yaml")
+		if err := os.WriteFile(configPath, config, 0o600); err != nil {
+			return ctx, err
+		}
+		log.V(4).Infof("Creating kind cluster %s with config %s", name, configPath)
+		return envfuncs.CreateClusterWithConfig(clusterProvider, name, configPath)(ctx, cfg)
+	}
+}
+
+// kindConfig returns the KindConfig or the content of ClusterConfigPath
+func (s *ClusterSetup) kindConfig() ([]byte, error) {
+	switch {
+	case s.KindConfig != nil && s.ClusterConfigPath != "":
+		return nil, errors.New("KindConfig and ClusterConfigPath are mutually exclusive")
+	case s.KindConfig != nil:
+		return s.KindConfig.Marshal()
+	case s.ClusterConfigPath != "":
+		return os.ReadFile(s.ClusterConfigPath)
+	default:
+		return nil, nil
+	}
+}
+
+// removeKindPackageCache returns the env.Func that removes the package cache of a custom kind cluster from the host
+func (s *ClusterSetup) removeKindPackageCache(clusterProvider support.E2EClusterProvider, name string) env.Func {
+	if !s.customKindCluster(clusterProvider) {
+		return nil
+	}
+	return func(ctx context.Context, _ *envconf.Config) (context.Context, error) {
+		dir := filepath.Dir(kindPackageCacheDir(name))
+		if err := os.RemoveAll(dir); err != nil {
+			// the packages are owned by the user of the node containers, which might not be the current user
+			log.Warningf("Failed to remove package cache %s: %s", dir, err)
+		}
+		return ctx, nil
+	}
+}
+
+// kindPackageCacheDir returns the directory of the host, which is mounted as package cache into all nodes of the kind cluster
+func kindPackageCacheDir(clusterName string) string {
+	return filepath.Join(os.TempDir(), "xp-testing", clusterName, "xpkg")
+}
+
+// startLocalRegistry returns the env.Func that starts the local registry, if configured, which requires a kind cluster
+func (s *ClusterSetup) startLocalRegistry(clusterProvider support.E2EClusterProvider, clusterName string) env.Func {
+	if s.LocalRegistry == nil {
+		return nil
+	}
+	if _, ok := clusterProvider.(*kind.Cluster); !ok {
+		return func(ctx context.Context, _ *envconf.Config) (context.Context, error) {
+			return ctx, fmt.Errorf("the local registry requires a kind cluster, got %T", clusterProvider)
+		}
+	}
+	return s.LocalRegistry.Start(clusterName)
+}
+
+// localRegistryImageConfigs returns the env.Func that applies the image configs of the local registry, if configured
+func (s *ClusterSetup) localRegistryImageConfigs() env.Func {
+	if s.LocalRegistry == nil {
+		return nil
+	}
+	return s.LocalRegistry.ImageConfigs()
+}
+
+// removeLocalRegistry returns the env.Func that removes the local registry, if configured
+func (s *ClusterSetup) removeLocalRegistry() env.Func {
+	if s.LocalRegistry == nil {
+		return nil
+	}
+	return s.LocalRegistry.Teardown()
+}
+
+// setupCassettes returns the env.Func that sets up the cassette proxy, if configured
+func (s *ClusterSetup) setupCassettes(clusterProvider support.E2EClusterProvider, clusterName string) env.Func {
+	cassettes := s.cassettes(clusterProvider)
+	if cassettes == nil {
+		return nil
+	}
+	return cassettes.Setup(clusterName)
+}
+
+// cassettes returns a copy of the configured cassettes, which resolves its node via the cluster backend, if not set
+func (s *ClusterSetup) cassettes(clusterProvider support.E2EClusterProvider) *cassette.Config {
+	if s.Cassettes == nil {
+		return nil
+	}
+	cassettes := *s.Cassettes
+	if cassettes.Node == nil {
+		cassettes.Node, _ = cluster.Node(clusterProvider)
+	}
+	return &cassettes
+}
+
+// snapshotManagedResources returns the env.Func that records the existing managed resources, if leak detection is configured
+func (s *ClusterSetup) snapshotManagedResources() env.Func {
+	if s.LeakDetection == nil {
+		return nil
+	}
+	return resources.SnapshotManagedResources
+}
+
+// detectLeakedResources returns the env.Func that fails on leaked managed resources, if leak detection is configured
+func (s *ClusterSetup) detectLeakedResources() env.Func {
+	if s.LeakDetection == nil {
+		return nil
+	}
+	return resources.DetectLeakedResources(*s.LeakDetection)
+}
+
+// deploymentRuntimeConfig returns the DeploymentRuntimeConfig of the provider, routed through the cassette proxy if configured
+func (s *ClusterSetup) deploymentRuntimeConfig() *vendored.DeploymentRuntimeConfig {
+	if s.Cassettes == nil {
+		return s.DeploymentRuntimeConfig
+	}
+	return s.Cassettes.InjectProxy(s.DeploymentRuntimeConfig)
+}
+
+// applyProviderConfig returns the env.Func that applies the ProviderConfig, rendered as template if mock servers are configured
+func (s *ClusterSetup) applyProviderConfig() env.Func {
+	dir := orDefault(s.ProviderConfigDir, "./provider")
+	if len(s.MockServers) == 0 {
+		return xpenvfuncs.ApplyProviderConfigFromDir(dir)
+	}
+	return xpenvfuncs.ApplyProviderConfigFromDirWithData(dir, mockserver.TemplateData(s.MockServers...))
 }
 
 func setupProviderCredentials(s *ClusterSetup) env.Func {
@@ -235,16 +679,3 @@ func clusterName(reuseCluster bool) string {
 
 	return envconf.RandomName(defaultPrefix, 10)
 }
-
-// TODO: Maybe part of the k8s-e2e framework?
-func kindClusterExists(name string) bool {
-	e := gexe.New()
-	envfuncs.GetClusterFromContext(context.TODO(), name)
-	clusters := e.Run("kind get clusters")
-	for _, c := range strings.Split(clusters, "\n") {
-		if c == name {
-			return true
-		}
-	}
-	return false
-}
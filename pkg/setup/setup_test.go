@@ -9,7 +9,14 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/e2e-framework/pkg/env"
 	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/support/k3d"
+	"sigs.k8s.io/e2e-framework/support/kind"
+	"sigs.k8s.io/e2e-framework/third_party/helm"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/cluster"
+	"github.com/crossplane-contrib/xp-testing/pkg/xpenvfuncs"
 )
 
 var someName = "Bar"
@@ -94,11 +101,102 @@ func TestClusterSetup_InstallCrossplaneFunc_HookOverrides(t *testing.T) {
 	require.EqualError(t, err, "sentinel")
 }
 
-func TestClusterSetup_InstallCrossplaneFunc_DefaultsToBundled(t *testing.T) {
-	s := &ClusterSetup{}
-	got := s.installCrossplaneFunc("test-cluster")
-	require.NotNil(t, got)
-	// The bundled InstallCrossplane errors when invoked outside a real test
-	// environment; asserting on that exact error couples too tightly. Just
-	// assert non-nil to confirm we delegated to the bundled installer.
+func TestClusterSetup_InstallCrossplaneFunc_UsesCrossplaneSetup(t *testing.T) {
+	var installed []string
+	record := func(entry string, clusterName string, source string) env.Func {
+		installed = append(installed, fmt.Sprintf("%s %s %s", entry, clusterName, source))
+		return nil
+	}
+	install, fromChart, fromRepo := installCrossplane, installCrossplaneFromChart, installCrossplaneFromRepo
+	defer func() {
+		installCrossplane, installCrossplaneFromChart, installCrossplaneFromRepo = install, fromChart, fromRepo
+	}()
+	installCrossplane = func(clusterName string, _ ...xpenvfuncs.CrossplaneOpt) env.Func {
+		return record("bundled", clusterName, "")
+	}
+	installCrossplaneFromChart = func(clusterName string, chartRef string, _ ...xpenvfuncs.CrossplaneOpt) env.Func {
+		return record("chart", clusterName, chartRef)
+	}
+	installCrossplaneFromRepo = func(clusterName string, chartRepoURL string, _ ...xpenvfuncs.CrossplaneOpt) env.Func {
+		return record("repo", clusterName, chartRepoURL)
+	}
+
+	for _, s := range []*ClusterSetup{
+		{},
+		{CrossplaneSetup: CrossplaneSetup{ChartRef: "oci://xpkg.crossplane.io/crossplane/crossplane"}},
+		{CrossplaneSetup: CrossplaneSetup{ChartRepoURL: "https://example.com/charts"}},
+	} {
+		s.installCrossplaneFunc("test-cluster")
+	}
+	require.Equal(t, []string{
+		"bundled test-cluster ",
+		"chart test-cluster oci://xpkg.crossplane.io/crossplane/crossplane",
+		"repo test-cluster https://example.com/charts",
+	}, installed)
+}
+
+func TestCrossplaneSetup_UpgradeCrossplaneFunc(t *testing.T) {
+	for _, s := range []CrossplaneSetup{
+		{Version: "v2.0.0"},
+		{Version: "v2.0.0", ChartRef: "oci://xpkg.crossplane.io/crossplane/crossplane"},
+		{Version: "v2.0.0", ChartRepoURL: "https://example.com/charts"},
+	} {
+		got := s.UpgradeCrossplaneFunc("test-cluster")
+		require.NotNil(t, got)
+		_, err := got(context.Background(), &envconf.Config{})
+		require.EqualError(t, err, "upgrade crossplane func: cluster 'test-cluster' doesn't exist")
+	}
+}
+
+func TestCrossplaneSetup_Options(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup CrossplaneSetup
+		args  []string
+	}{
+		{name: "no args", setup: CrossplaneSetup{Version: "v2.0.0"}},
+		{name: "registry", setup: CrossplaneSetup{Registry: "xpkg.upbound.io"}, args: []string{"--set", "args={--registry=xpkg.upbound.io}"}},
+		{name: "signature verification", setup: CrossplaneSetup{SignatureVerification: true}, args: []string{"--set", "args={--enable-signature-verification}"}},
+		{
+			name:  "registry and signature verification",
+			setup: CrossplaneSetup{Registry: "xpkg.upbound.io", SignatureVerification: true},
+			args:  []string{"--set", "args={--registry=xpkg.upbound.io,--enable-signature-verification}"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts helm.Opts
+			for _, opt := range tt.setup.Options() {
+				opt(&opts)
+			}
+			require.Equal(t, tt.setup.Version, opts.Version)
+			require.Equal(t, tt.args, opts.Args)
+		})
+	}
+}
+
+func TestClusterSetup_kindConfig(t *testing.T) {
+	require.False(t, (&ClusterSetup{}).customKindCluster(&kind.Cluster{}))
+	require.False(t, (&ClusterSetup{KubernetesVersion: "1.31.0"}).customKindCluster(&k3d.Cluster{}))
+	require.True(t, (&ClusterSetup{KubernetesVersion: "1.31.0"}).customKindCluster(&kind.Cluster{}))
+
+	config, err := (&ClusterSetup{}).kindConfig()
+	require.NoError(t, err)
+	require.Nil(t, config)
+
+	config, err = (&ClusterSetup{KindConfig: &cluster.KindConfig{Nodes: []cluster.KindNode{{Role: cluster.KindWorker}}}}).kindConfig()
+	require.NoError(t, err)
+	require.Contains(t, string(config), "role: worker")
+
+	_, err = (&ClusterSetup{KindConfig: &cluster.KindConfig{}, ClusterConfigPath: "kind.yaml"}).kindConfig()
+	require.EqualError(t, err, "KindConfig and ClusterConfigPath are mutually exclusive")
+}
+
+func TestClusterSetup_startLocalRegistry(t *testing.T) {
+	require.Nil(t, (&ClusterSetup{}).startLocalRegistry(&k3d.Cluster{}, "test"))
+
+	s := &ClusterSetup{LocalRegistry: &xpenvfuncs.LocalRegistry{}}
+	require.NotNil(t, s.startLocalRegistry(&kind.Cluster{}, "test"))
+	_, err := s.startLocalRegistry(&k3d.Cluster{}, "test")(context.Background(), nil)
+	require.EqualError(t, err, "the local registry requires a kind cluster, got *k3d.Cluster")
 }
@@ -0,0 +1,356 @@
+package setup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/pkg/env"
+	"sigs.k8s.io/e2e-framework/support"
+	"sigs.k8s.io/e2e-framework/support/k3d"
+	"sigs.k8s.io/e2e-framework/support/kind"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/cluster"
+	"github.com/crossplane-contrib/xp-testing/pkg/images"
+	"github.com/crossplane-contrib/xp-testing/pkg/vendored"
+)
+
+const (
+	suiteFileEnv     = "E2E_SUITE_FILE"
+	defaultSuiteFile = "suite.yaml"
+	imagesEnv        = "E2E_IMAGES"
+
+	kindCluster       = "kind"
+	k3dCluster        = "k3d"
+	kubeconfigCluster = "kubeconfig"
+)
+
+// Suite is the declarative configuration of a ClusterSetup, loaded from a YAML or JSON file by LoadSuite.
+// Relative paths are resolved against the directory of the file.
+//
+//	providerName: provider-nop
+//	crossplane:
+//	  version: 1.20.0
+//	images:
+//	  packageKey: package
+//	credentials:
+//	  data:
+//	    credentials: {env: PROVIDER_CREDENTIALS}
+//	providerConfigDir: ./provider
+//	cluster:
+//	  type: kind
+//	  configFile: ./kind.yaml
+type Suite struct {
+	ProviderName string          `json:"providerName"`
+	Crossplane   SuiteCrossplane `json:"crossplane,omitempty"`
+	Images       SuiteImages     `json:"images"`
+	// DeploymentRuntimeConfig and ControllerConfig are the manifests of the runtime config of the provider
+	DeploymentRuntimeConfig *vendored.DeploymentRuntimeConfig `json:"deploymentRuntimeConfig,omitempty"`
+	ControllerConfig        *vendored.ControllerConfig        `json:"controllerConfig,omitempty"`
+	Credentials             *SuiteCredentials                 `json:"credentials,omitempty"`
+	// ProviderConfigDir is the directory of the ProviderConfig manifests, defaults to ./provider
+	ProviderConfigDir   string       `json:"providerConfigDir,omitempty"`
+	Cluster             SuiteCluster `json:"cluster,omitempty"`
+	ReportDir           string       `json:"reportDir,omitempty"`
+	WatchConditions     bool         `json:"watchConditions,omitempty"`
+	CollectProviderLogs bool         `json:"collectProviderLogs,omitempty"`
+	IsolateFeatures     bool         `json:"isolateFeatures,omitempty"`
+
+	dir string
+}
+
+// SuiteCrossplane configures the crossplane installation, see CrossplaneSetup
+type SuiteCrossplane struct {
+	Version               string `json:"version,omitempty"`
+	Registry              string `json:"registry,omitempty"`
+	ChartRef              string `json:"chartRef,omitempty"`
+	ChartRepoURL          string `json:"chartRepoURL,omitempty"`
+	SignatureVerification bool   `json:"signatureVerification,omitempty"`
+}
+
+// SuiteImages configures the provider package and controller image, either directly or by their key in E2E_IMAGES,
+// see images.GetImagesFromEnvironmentOrPanic
+type SuiteImages struct {
+	Package         string `json:"package,omitempty"`
+	ControllerImage string `json:"controllerImage,omitempty"`
+	PackageKey      string `json:"packageKey,omitempty"`
+	ControllerKey   string `json:"controllerKey,omitempty"`
+}
+
+// SuiteCredentials configures the secret with the provider credentials, see ProviderCredentials
+type SuiteCredentials struct {
+	// SecretName defaults to "secret"
+	SecretName string                `json:"secretName,omitempty"`
+	Data       map[string]SuiteValue `json:"data"`
+}
+
+// SuiteValue is a value given inline, read from an environment variable or from a file. Exactly one has to be set.
+type SuiteValue struct {
+	Value string `json:"value,omitempty"`
+	Env   string `json:"env,omitempty"`
+	File  string `json:"file,omitempty"`
+}
+
+// SuiteCluster configures the cluster backend
+type SuiteCluster struct {
+	// Type is kind (default), k3d or kubeconfig for an existing cluster
+	Type string `json:"type,omitempty"`
+	// ConfigFile is the kind or k3d configuration the cluster is created with
+	ConfigFile string `json:"configFile,omitempty"`
+	// KubernetesVersion of the kind nodes, see ClusterSetup.KubernetesVersion
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+	// Kubeconfig and Context select the existing cluster of type kubeconfig, see cluster.Kubeconfig
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	Context    string `json:"context,omitempty"`
+}
+
+// LoadSuite reads and validates the suite file, all problems are reported at once with the path of the field
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suite file: %w", err)
+	}
+	s := &Suite{}
+	if err := yaml.UnmarshalStrict(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse suite file %s: %w", path, err)
+	}
+	s.dir = filepath.Dir(path)
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("invalid suite file %s:\n%w", path, err)
+	}
+	return s, nil
+}
+
+func (s *Suite) validate() error {
+	var errs []error
+	invalid := func(field string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+	if s.ProviderName == "" {
+		invalid("providerName", "is required")
+	}
+	if s.Crossplane.ChartRef != "" && s.Crossplane.ChartRepoURL != "" {
+		invalid("crossplane", "chartRef and chartRepoURL are mutually exclusive")
+	}
+	if s.Images.Package != "" && s.Images.PackageKey != "" {
+		invalid("images", "package and packageKey are mutually exclusive")
+	}
+	if s.Images.ControllerImage != "" && s.Images.ControllerKey != "" {
+		invalid("images", "controllerImage and controllerKey are mutually exclusive")
+	}
+	if _, err := s.images(); err != nil {
+		errs = append(errs, err)
+	}
+	if s.Credentials != nil {
+		if len(s.Credentials.Data) == 0 {
+			invalid("credentials.data", "is required")
+		}
+		for key, value := range s.Credentials.Data {
+			if _, err := value.resolve(s.dir); err != nil {
+				invalid("credentials.data."+key, "%s", err)
+			}
+		}
+	}
+	if s.ProviderConfigDir != "" {
+		if info, err := os.Stat(s.path(s.ProviderConfigDir)); err != nil || !info.IsDir() {
+			invalid("providerConfigDir", "%s is not a directory", s.path(s.ProviderConfigDir))
+		}
+	}
+	switch s.Cluster.Type {
+	case "", kindCluster:
+		if s.Cluster.Kubeconfig != "" || s.Cluster.Context != "" {
+			invalid("cluster", "kubeconfig and context require type %s", kubeconfigCluster)
+		}
+	case k3dCluster:
+		if s.Cluster.Kubeconfig != "" || s.Cluster.Context != "" {
+			invalid("cluster", "kubeconfig and context require type %s", kubeconfigCluster)
+		}
+		if s.Cluster.KubernetesVersion != "" {
+			invalid("cluster.kubernetesVersion", "is not supported by type %s", k3dCluster)
+		}
+	case kubeconfigCluster:
+		if s.Cluster.ConfigFile != "" {
+			invalid("cluster.configFile", "is not supported by type %s", kubeconfigCluster)
+		}
+		if s.Cluster.KubernetesVersion != "" {
+			invalid("cluster.kubernetesVersion", "is not supported by type %s", kubeconfigCluster)
+		}
+	default:
+		invalid("cluster.type", "unknown type %q, expected one of %s, %s or %s", s.Cluster.Type, kindCluster, k3dCluster, kubeconfigCluster)
+	}
+	if s.Cluster.ConfigFile != "" {
+		if _, err := os.Stat(s.path(s.Cluster.ConfigFile)); err != nil {
+			invalid("cluster.configFile", "%s", err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// images returns the provider images, looking up the keys in E2E_IMAGES
+func (s *Suite) images() (images.ProviderImages, error) {
+	imgs := images.ProviderImages{Package: s.Images.Package}
+	if s.Images.ControllerImage != "" {
+		imgs.ControllerImage = &s.Images.ControllerImage
+	}
+	if s.Images.PackageKey == "" && s.Images.ControllerKey == "" {
+		if imgs.Package == "" {
+			return imgs, errors.New("images: package or packageKey is required")
+		}
+		return imgs, nil
+	}
+	raw, ok := os.LookupEnv(imagesEnv)
+	if !ok {
+		return imgs, fmt.Errorf("images: packageKey and controllerKey require %s to be set", imagesEnv)
+	}
+	fromEnv := map[string]string{}
+	if err := json.Unmarshal([]byte(raw), &fromEnv); err != nil {
+		return imgs, fmt.Errorf("images: failed to parse %s: %w", imagesEnv, err)
+	}
+	lookup := func(field string, key string) (string, error) {
+		value, ok := fromEnv[key]
+		if !ok {
+			return "", fmt.Errorf("images.%s: key %q not found in %s", field, key, imagesEnv)
+		}
+		return value, nil
+	}
+	var errs []error
+	if s.Images.PackageKey != "" {
+		pkg, err := lookup("packageKey", s.Images.PackageKey)
+		imgs.Package = pkg
+		errs = append(errs, err)
+	} else if imgs.Package == "" {
+		errs = append(errs, errors.New("images: package or packageKey is required"))
+	}
+	if s.Images.ControllerKey != "" {
+		controller, err := lookup("controllerKey", s.Images.ControllerKey)
+		imgs.ControllerImage = &controller
+		errs = append(errs, err)
+	}
+	return imgs, errors.Join(errs...)
+}
+
+// path resolves the path relative to the directory of the suite file
+func (s *Suite) path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(s.dir, p)
+}
+
+// resolve returns the value from the source set
+func (v SuiteValue) resolve(dir string) (string, error) {
+	sources := 0
+	for _, source := range []string{v.Value, v.Env, v.File} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return "", errors.New("exactly one of value, env or file is required")
+	}
+	switch {
+	case v.Env != "":
+		value, ok := os.LookupEnv(v.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", v.Env)
+		}
+		return value, nil
+	case v.File != "":
+		if !filepath.IsAbs(v.File) {
+			v.File = filepath.Join(dir, v.File)
+		}
+		data, err := os.ReadFile(v.File)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	default:
+		return v.Value, nil
+	}
+}
+
+// ClusterSetup returns the ClusterSetup described by the suite, credentials and images are resolved from the
+// environment and files at the time of the call
+func (s *Suite) ClusterSetup() (*ClusterSetup, error) {
+	imgs, err := s.images()
+	if err != nil {
+		return nil, err
+	}
+	cs := &ClusterSetup{
+		ProviderName: s.ProviderName,
+		Images:       imgs,
+		CrossplaneSetup: CrossplaneSetup{
+			Version:               s.Crossplane.Version,
+			Registry:              s.Crossplane.Registry,
+			ChartRef:              s.Crossplane.ChartRef,
+			ChartRepoURL:          s.Crossplane.ChartRepoURL,
+			SignatureVerification: s.Crossplane.SignatureVerification,
+		},
+		ControllerConfig:        s.ControllerConfig,
+		DeploymentRuntimeConfig: s.DeploymentRuntimeConfig,
+		ClusterConfigPath:       s.path(s.Cluster.ConfigFile),
+		KubernetesVersion:       s.Cluster.KubernetesVersion,
+		ReportDir:               s.ReportDir,
+		WatchConditions:         s.WatchConditions,
+		CollectProviderLogs:     s.CollectProviderLogs,
+		IsolateFeatures:         s.IsolateFeatures,
+	}
+	if s.ProviderConfigDir != "" {
+		dir := s.path(s.ProviderConfigDir)
+		cs.ProviderConfigDir = &dir
+	}
+	if s.Credentials != nil {
+		data := make(map[string]string, len(s.Credentials.Data))
+		for key, value := range s.Credentials.Data {
+			data[key], err = value.resolve(s.dir)
+			if err != nil {
+				return nil, fmt.Errorf("credentials.data.%s: %w", key, err)
+			}
+		}
+		cs.ProviderCredential = &ProviderCredentials{SecretData: data}
+		if s.Credentials.SecretName != "" {
+			cs.ProviderCredential.SecretName = &s.Credentials.SecretName
+		}
+	}
+	return cs, nil
+}
+
+// ClusterProvider returns the cluster backend described by the suite
+func (s *Suite) ClusterProvider() support.E2EClusterProvider {
+	switch s.Cluster.Type {
+	case k3dCluster:
+		return &k3d.Cluster{}
+	case kubeconfigCluster:
+		return cluster.NewKubeconfig(s.path(s.Cluster.Kubeconfig), s.Cluster.Context)
+	default:
+		return &kind.Cluster{}
+	}
+}
+
+// RunSuite loads the suite file named by E2E_SUITE_FILE, suite.yaml by default, configures the environment with it and
+// runs the tests. It returns the exit code like env.Environment.Run, so TestMain becomes
+//
+//	func TestMain(m *testing.M) { os.Exit(setup.RunSuite(m, testenv)) }
+func RunSuite(m *testing.M, testEnv env.Environment) int {
+	path := defaultSuiteFile
+	if p, ok := os.LookupEnv(suiteFileEnv); ok {
+		path = p
+	}
+	suite, err := LoadSuite(path)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	cs, err := suite.ClusterSetup()
+	if err != nil {
+		log.Errorf("invalid suite file %s: %s", path, err)
+		return 1
+	}
+	cs.Configure(testEnv, suite.ClusterProvider())
+	return cs.Run(m, testEnv)
+}
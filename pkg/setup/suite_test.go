@@ -0,0 +1,167 @@
+package setup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/e2e-framework/support/k3d"
+	"sigs.k8s.io/e2e-framework/support/kind"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/cluster"
+)
+
+func writeSuite(t *testing.T, content string, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600))
+	}
+	path := filepath.Join(dir, "suite.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadSuite(t *testing.T) {
+	t.Setenv("E2E_IMAGES", `{"package":"xpkg.upbound.io/provider-nop:v0.2.0","controller":"provider-nop-controller:v0.2.0"}`)
+	t.Setenv("NOP_TOKEN", "token")
+	path := writeSuite(t, `
+providerName: provider-nop
+crossplane:
+  version: 1.20.0
+  chartRef: oci://xpkg.crossplane.io/crossplane/crossplane
+images:
+  packageKey: package
+  controllerKey: controller
+deploymentRuntimeConfig:
+  metadata:
+    name: provider-nop
+credentials:
+  secretName: nop-credentials
+  data:
+    token: {env: NOP_TOKEN}
+    config: {file: creds/config.json}
+    region: {value: eu}
+providerConfigDir: ./provider
+cluster:
+  configFile: kind.yaml
+isolateFeatures: true
+`, map[string]string{"creds/config.json": `{"user":"nop"}`, "provider/config.yaml": "", "kind.yaml": ""})
+	dir := filepath.Dir(path)
+
+	suite, err := LoadSuite(path)
+	require.NoError(t, err)
+	cs, err := suite.ClusterSetup()
+	require.NoError(t, err)
+
+	require.Equal(t, "provider-nop", cs.ProviderName)
+	require.Equal(t, "1.20.0", cs.CrossplaneSetup.Version)
+	require.Equal(t, "oci://xpkg.crossplane.io/crossplane/crossplane", cs.CrossplaneSetup.ChartRef)
+	require.Equal(t, "xpkg.upbound.io/provider-nop:v0.2.0", cs.Images.Package)
+	require.Equal(t, "provider-nop-controller:v0.2.0", *cs.Images.ControllerImage)
+	require.Equal(t, "provider-nop", cs.DeploymentRuntimeConfig.Name)
+	require.Equal(t, "nop-credentials", *cs.ProviderCredential.SecretName)
+	require.Equal(t, map[string]string{"token": "token", "config": `{"user":"nop"}`, "region": "eu"}, cs.ProviderCredential.SecretData)
+	require.Equal(t, filepath.Join(dir, "provider"), *cs.ProviderConfigDir)
+	require.Equal(t, filepath.Join(dir, "kind.yaml"), cs.ClusterConfigPath)
+	require.True(t, cs.IsolateFeatures)
+	require.IsType(t, &kind.Cluster{}, suite.ClusterProvider())
+}
+
+func TestLoadSuite_JSON(t *testing.T) {
+	path := writeSuite(t, `{"providerName": "provider-nop", "images": {"package": "provider-nop:v0.2.0"}, "cluster": {"type": "k3d"}}`, nil)
+
+	suite, err := LoadSuite(path)
+	require.NoError(t, err)
+	cs, err := suite.ClusterSetup()
+	require.NoError(t, err)
+	require.Equal(t, "provider-nop:v0.2.0", cs.Images.Package)
+	require.Nil(t, cs.Images.ControllerImage)
+	require.Nil(t, cs.ProviderCredential)
+	require.Nil(t, cs.ProviderConfigDir)
+	require.Empty(t, cs.ClusterConfigPath)
+	require.IsType(t, &k3d.Cluster{}, suite.ClusterProvider())
+}
+
+func TestLoadSuite_kubeconfig(t *testing.T) {
+	path := writeSuite(t, `
+providerName: provider-nop
+images: {package: provider-nop:v0.2.0}
+cluster: {type: kubeconfig, kubeconfig: kubeconfig.yaml, context: staging}
+`, nil)
+
+	suite, err := LoadSuite(path)
+	require.NoError(t, err)
+	require.Equal(t, cluster.NewKubeconfig(filepath.Join(filepath.Dir(path), "kubeconfig.yaml"), "staging"), suite.ClusterProvider())
+}
+
+func TestLoadSuite_invalid(t *testing.T) {
+	t.Setenv("E2E_IMAGES", "")
+	require.NoError(t, os.Unsetenv("E2E_IMAGES"))
+	tests := []struct {
+		name    string
+		content string
+		errs    []string
+	}{
+		{
+			name:    "unknown field",
+			content: "providerName: provider-nop\nprovider: provider-nop",
+			errs:    []string{`unknown field "provider"`},
+		},
+		{
+			name: "all problems at once",
+			content: `
+crossplane: {chartRef: ./crossplane.tgz, chartRepoURL: https://charts.example.com}
+images: {packageKey: package}
+credentials:
+  data:
+    token: {env: XP_TESTING_UNSET_ENV}
+    config: {value: a, file: config.json}
+providerConfigDir: ./missing
+cluster: {type: minikube}
+`,
+			errs: []string{
+				"providerName: is required",
+				"crossplane: chartRef and chartRepoURL are mutually exclusive",
+				"images: packageKey and controllerKey require E2E_IMAGES to be set",
+				"credentials.data.token: environment variable XP_TESTING_UNSET_ENV is not set",
+				"credentials.data.config: exactly one of value, env or file is required",
+				"providerConfigDir: ",
+				`cluster.type: unknown type "minikube"`,
+			},
+		},
+		{
+			name:    "missing package",
+			content: "providerName: provider-nop\ncluster: {configFile: kind.yaml, context: staging}",
+			errs: []string{
+				"images: package or packageKey is required",
+				"cluster: kubeconfig and context require type kubeconfig",
+				"cluster.configFile: ",
+			},
+		},
+		{
+			name:    "kubernetes version of k3d",
+			content: "providerName: provider-nop\nimages: {package: provider-nop:v0.2.0}\ncluster: {type: k3d, kubernetesVersion: 1.31.0}",
+			errs:    []string{"cluster.kubernetesVersion: is not supported by type k3d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadSuite(writeSuite(t, tt.content, nil))
+			require.Error(t, err)
+			for _, msg := range tt.errs {
+				require.ErrorContains(t, err, msg)
+			}
+		})
+	}
+}
+
+func TestLoadSuite_missingImageKey(t *testing.T) {
+	t.Setenv("E2E_IMAGES", `{"package":"provider-nop:v0.2.0"}`)
+	path := writeSuite(t, "providerName: provider-nop\nimages: {packageKey: package, controllerKey: controller}", nil)
+
+	_, err := LoadSuite(path)
+	require.ErrorContains(t, err, `images.controllerKey: key "controller" not found in E2E_IMAGES`)
+}
@@ -0,0 +1,150 @@
+package upgrade
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	fwresources "sigs.k8s.io/e2e-framework/klient/k8s/resources"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/resources"
+	"github.com/crossplane-contrib/xp-testing/pkg/setup"
+	"github.com/crossplane-contrib/xp-testing/pkg/xpconditions"
+	"github.com/crossplane-contrib/xp-testing/pkg/xpenvfuncs"
+)
+
+// CrossplaneUpgradeTest represents the data in a crossplane core upgrade scenario where a set of providers and
+// resources (managed resources as well as composites) must remain healthy when crossplane itself is upgraded
+// from version x to version y, e.g. from v1 to v2.
+type CrossplaneUpgradeTest struct {
+	// ClusterName identifies the kind cluster to use
+	ClusterName string
+	// FromCrossplane is the crossplane setup to upgrade from, it is applied via `helm upgrade --install`
+	FromCrossplane setup.CrossplaneSetup
+	// ToCrossplane is the crossplane setup to upgrade to
+	ToCrossplane setup.CrossplaneSetup
+	// Providers is the set of providers installed before the upgrade, which must stay healthy
+	Providers []xpenvfuncs.InstallCrossplaneProviderOptions
+	// ResourceDirectories is the set of directories including manifests to assert the crossplane upgrade with
+	ResourceDirectories []string
+	// RestoreCrossplane is the crossplane setup restored at teardown, defaults to FromCrossplane.
+	// Set it to the crossplane setup of the suite if the cluster is shared with other tests.
+	RestoreCrossplane *setup.CrossplaneSetup
+}
+
+// UpgradeFeatureBuilder provides a complete crossplane upgrade test feature builder that can be extended with
+// additional steps and labels.
+// The teardown deletes the resources and providers and restores crossplane, so the cluster can be shared with other tests.
+// Use this for simple upgrade scenarios or reuse the building blocks to orchestrate a custom upgrade feature.
+func (ct *CrossplaneUpgradeTest) UpgradeFeatureBuilder(featureName string, timeout time.Duration) *features.FeatureBuilder {
+	builder := features.New(featureName).
+		WithSetup("install crossplane", ApplyCrossplane(ct.ClusterName, ct.FromCrossplane))
+	for _, provider := range ct.Providers {
+		builder = builder.WithSetup("install provider "+provider.Name, ApplyProvider(ct.ClusterName, provider))
+	}
+	builder = builder.
+		WithSetup("import resources", ImportResources(ct.ResourceDirectories)).
+		Assess("verify providers before upgrade", VerifyProviders(ct.providerNames(), timeout)).
+		Assess("verify resources before upgrade", VerifyResources(ct.ResourceDirectories, timeout)).
+		Assess("upgrade crossplane", UpgradeCrossplane(UpgradeCrossplaneOptions{
+			ClusterName:         ct.ClusterName,
+			CrossplaneSetup:     ct.ToCrossplane,
+			ProviderNames:       ct.providerNames(),
+			ResourceDirectories: ct.ResourceDirectories,
+			WaitForPause:        timeout,
+			WaitForProviders:    timeout,
+		})).
+		Assess("verify providers after upgrade", VerifyProviders(ct.providerNames(), timeout)).
+		Assess("verify resources after upgrade", VerifyResources(ct.ResourceDirectories, timeout)).
+		WithTeardown("delete resources", DeleteResources(ct.ResourceDirectories, timeout))
+	for _, name := range ct.providerNames() {
+		builder = builder.WithTeardown("delete provider "+name, DeleteProvider(name))
+	}
+	return builder.WithTeardown("restore crossplane", ApplyCrossplane(ct.ClusterName, ct.restoreCrossplane()))
+}
+
+func (ct *CrossplaneUpgradeTest) restoreCrossplane() setup.CrossplaneSetup {
+	if ct.RestoreCrossplane != nil {
+		return *ct.RestoreCrossplane
+	}
+	return ct.FromCrossplane
+}
+
+func (ct *CrossplaneUpgradeTest) providerNames() []string {
+	names := make([]string, 0, len(ct.Providers))
+	for _, provider := range ct.Providers {
+		names = append(names, provider.Name)
+	}
+	return names
+}
+
+// ApplyCrossplane installs or upgrades crossplane in a cluster to the given setup via the bundled helm path.
+func ApplyCrossplane(clusterName string, crossplaneSetup setup.CrossplaneSetup) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		klog.V(4).Infof("apply crossplane: %s", crossplaneSetup.Version)
+		ctx, err := xpenvfuncs.Compose(
+			xpenvfuncs.ValidateTestSetup(xpenvfuncs.ValidateTestSetupOptions{
+				CrossplaneVersion: crossplaneSetup.Version,
+				PackageRegistry:   crossplaneSetup.Registry,
+			}),
+			crossplaneSetup.UpgradeCrossplaneFunc(clusterName),
+		)(ctx, cfg)
+		if err != nil {
+			resources.DumpManagedResources(ctx, t, cfg)
+			t.Fatalf("apply crossplane %s failed: %v", crossplaneSetup.Version, err)
+		}
+		return ctx
+	}
+}
+
+// VerifyProviders waits until each provider is installed and healthy.
+func VerifyProviders(providerNames []string, timeout time.Duration) features.Func {
+	return func(ctx context.Context, t *testing.T, c *envconf.Config) context.Context {
+		r, err := fwresources.New(c.Client().RESTConfig())
+		if err != nil {
+			t.Fatalf("failed to create controller runtime client: %v", err)
+		}
+		xpc := xpconditions.FromContext(ctx, r)
+		for _, name := range providerNames {
+			klog.V(4).Infof("verify provider %s", name)
+			for _, conditionType := range []string{"Installed", "Healthy"} {
+				if err := xpc.For(xpc.ProviderConditionMatch(name, conditionType, corev1.ConditionTrue), wait.WithTimeout(timeout)); err != nil {
+					t.Errorf("verify provider %s to be %s failed: %v", name, conditionType, err)
+				}
+			}
+		}
+		return ctx
+	}
+}
+
+// UpgradeCrossplaneOptions represents the necessary parameters to orchestrate a crossplane upgrade
+type UpgradeCrossplaneOptions struct {
+	ClusterName string
+	// CrossplaneSetup defines the crossplane setup to upgrade to
+	CrossplaneSetup setup.CrossplaneSetup
+	// ProviderNames defines the providers that must become healthy again after the upgrade
+	ProviderNames []string
+	// ResourceDirectories defines the resource directories to iterate over to pause any included object before the upgrade
+	// and resume any included object after the crossplane upgrade
+	ResourceDirectories []string
+	// WaitForPause defines the timeout to wait for all resources to match condition ReconcilePaused
+	WaitForPause time.Duration
+	// WaitForProviders defines the timeout to wait for all providers to become healthy after the upgrade
+	WaitForProviders time.Duration
+}
+
+// UpgradeCrossplane orchestrates a crossplane upgrade by first pausing all resources,
+// then upgrading crossplane, waiting for the providers to become healthy again and finally resuming all resources
+func UpgradeCrossplane(options UpgradeCrossplaneOptions) features.Func {
+	return Compose(
+		PauseResources(options.ResourceDirectories, options.WaitForPause),
+		ApplyCrossplane(options.ClusterName, options.CrossplaneSetup),
+		VerifyProviders(options.ProviderNames, options.WaitForProviders),
+		ResumeResources(options.ResourceDirectories),
+	)
+}
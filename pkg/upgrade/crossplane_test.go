@@ -0,0 +1,63 @@
+package upgrade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/e2e-framework/pkg/types"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/setup"
+	"github.com/crossplane-contrib/xp-testing/pkg/xpenvfuncs"
+)
+
+func TestCrossplaneUpgradeTest_UpgradeFeatureBuilder(t *testing.T) {
+	ct := &CrossplaneUpgradeTest{
+		ClusterName:    "e2e",
+		FromCrossplane: setup.CrossplaneSetup{Version: "1.20.0"},
+		ToCrossplane:   setup.CrossplaneSetup{Version: "2.0.0"},
+		Providers: []xpenvfuncs.InstallCrossplaneProviderOptions{
+			{Name: "provider-nop", Package: "xpkg.crossplane.io/crossplane-contrib/provider-nop:v0.4.0"},
+			{Name: "provider-helm", Package: "xpkg.crossplane.io/crossplane-contrib/provider-helm:v0.20.0"},
+		},
+		ResourceDirectories: []string{"./crs"},
+	}
+
+	feature := ct.UpgradeFeatureBuilder("upgrade crossplane", time.Minute).Feature()
+
+	type step struct {
+		level types.Level
+		name  string
+	}
+	var steps []step
+	for _, s := range feature.Steps() {
+		steps = append(steps, step{level: s.Level(), name: s.Name()})
+	}
+	require.Equal(t, []step{
+		{types.LevelSetup, "install crossplane"},
+		{types.LevelSetup, "install provider provider-nop"},
+		{types.LevelSetup, "install provider provider-helm"},
+		{types.LevelSetup, "import resources"},
+		{types.LevelAssess, "verify providers before upgrade"},
+		{types.LevelAssess, "verify resources before upgrade"},
+		{types.LevelAssess, "upgrade crossplane"},
+		{types.LevelAssess, "verify providers after upgrade"},
+		{types.LevelAssess, "verify resources after upgrade"},
+		{types.LevelTeardown, "delete resources"},
+		{types.LevelTeardown, "delete provider provider-nop"},
+		{types.LevelTeardown, "delete provider provider-helm"},
+		{types.LevelTeardown, "restore crossplane"},
+	}, steps)
+	require.Equal(t, []string{"provider-nop", "provider-helm"}, ct.providerNames())
+}
+
+func TestCrossplaneUpgradeTest_restoreCrossplane(t *testing.T) {
+	ct := &CrossplaneUpgradeTest{
+		FromCrossplane: setup.CrossplaneSetup{Version: "1.20.0"},
+		ToCrossplane:   setup.CrossplaneSetup{Version: "2.0.0"},
+	}
+	require.Equal(t, ct.FromCrossplane, ct.restoreCrossplane())
+
+	ct.RestoreCrossplane = &setup.CrossplaneSetup{Version: "2.1.0"}
+	require.Equal(t, setup.CrossplaneSetup{Version: "2.1.0"}, ct.restoreCrossplane())
+}
@@ -0,0 +1,304 @@
+// manually vendored to reduce dependency to c/c
+/*
+Copyright 2024 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vendored
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MatchType is the method used to match the image.
+type MatchType string
+
+const (
+	// Prefix is used to match the prefix of the image.
+	Prefix MatchType = "Prefix"
+)
+
+// ImageMatch defines a rule for matching image.
+type ImageMatch struct {
+	// Type is the type of match.
+	// +optional
+	// +kubebuilder:validation:Enum=Prefix
+	// +kubebuilder:default=Prefix
+	Type MatchType `json:"type,omitempty"`
+
+	// Prefix is the prefix that should be matched.
+	Prefix string `json:"prefix"`
+}
+
+// RegistryAuthentication contains the authentication information for a
+// registry.
+type RegistryAuthentication struct {
+	// PullSecretRef is a reference to a secret that contains the credentials for
+	// the registry.
+	PullSecretRef corev1.LocalObjectReference `json:"pullSecretRef"`
+}
+
+// RegistryConfig contains the configuration for the registry.
+type RegistryConfig struct {
+	// Authentication is the authentication information for the registry.
+	// +optional
+	Authentication *RegistryAuthentication `json:"authentication,omitempty"`
+}
+
+// ImageVerificationProvider is the provider used to verify the image.
+type ImageVerificationProvider string
+
+const (
+	// ImageVerificationProviderCosign is the Cosign provider.
+	ImageVerificationProviderCosign ImageVerificationProvider = "Cosign"
+)
+
+// ImageVerification parameters that are used to verify the image.
+type ImageVerification struct {
+	// Provider is the provider that should be used to verify the image.
+	// +kubebuilder:validation:Enum=Cosign
+	Provider ImageVerificationProvider `json:"provider"`
+
+	// Cosign is the configuration for verifying the image using cosign.
+	// +optional
+	Cosign *CosignVerificationConfig `json:"cosign,omitempty"`
+}
+
+// CosignVerificationConfig is the configuration for verifying an image using
+// cosign.
+type CosignVerificationConfig struct {
+	// Authorities defines the rules for discovering and validating signatures.
+	Authorities []CosignAuthority `json:"authorities"`
+}
+
+// CosignAuthority defines the rules for discovering and validating signatures.
+type CosignAuthority struct {
+	// Name is the name for this authority.
+	Name string `json:"name"`
+
+	// Key defines the type of key to validate the image.
+	// +optional
+	Key *PublicKeyRef `json:"key,omitempty"`
+
+	// Keyless sets the configuration to verify the authority against a Fulcio
+	// instance.
+	// +optional
+	Keyless *KeylessRef `json:"keyless,omitempty"`
+
+	// Attestations is a list of individual attestations for this authority,
+	// once the signature for this authority has been verified.
+	// +optional
+	Attestations []Attestation `json:"attestations,omitempty"`
+}
+
+// SecretKeySelector is a reference to a secret key in the crossplane namespace.
+type SecretKeySelector struct {
+	// Name of the secret.
+	Name string `json:"name"`
+
+	// The key to select.
+	Key string `json:"key"`
+}
+
+// PublicKeyRef references a secret containing a public key.
+type PublicKeyRef struct {
+	// SecretRef sets a reference to a secret with the key.
+	SecretRef SecretKeySelector `json:"secretRef"`
+
+	// HashAlgorithm always defaults to sha256 if the algorithm hasn't been explicitly set
+	// +optional
+	HashAlgorithm string `json:"hashAlgorithm,omitempty"`
+}
+
+// KeylessRef contains location of the validating certificate and the identities
+// against which to verify.
+type KeylessRef struct {
+	// Identities sets a list of identities.
+	Identities []CosignIdentity `json:"identities"`
+
+	// InsecureIgnoreSCT omits verifying if a certificate contains an embedded SCT
+	// +optional
+	InsecureIgnoreSCT *bool `json:"insecureIgnoreSCT,omitempty"`
+}
+
+// CosignIdentity may contain the issuer and/or the subject found in the transparency
+// log.
+type CosignIdentity struct {
+	// Issuer defines the issuer for this identity.
+	// +optional
+	Issuer string `json:"issuer,omitempty"`
+
+	// Subject defines the subject for this identity.
+	// +optional
+	Subject string `json:"subject,omitempty"`
+
+	// IssuerRegExp specifies a regular expression to match the issuer for this identity.
+	// +optional
+	IssuerRegExp string `json:"issuerRegExp,omitempty"`
+
+	// SubjectRegExp specifies a regular expression to match the subject for this identity.
+	// +optional
+	SubjectRegExp string `json:"subjectRegExp,omitempty"`
+}
+
+// Attestation defines the type of attestation to validate and optionally
+// apply a policy decision to it.
+type Attestation struct {
+	// Name of the attestation.
+	Name string `json:"name"`
+
+	// PredicateType defines which predicate type to verify.
+	PredicateType string `json:"predicateType"`
+}
+
+// ImageRewrite defines how a matched image's path should be rewritten.
+type ImageRewrite struct {
+	// Prefix is the prefix that will replace the portion of the image's path
+	// matched by the prefix in the ImageMatch.
+	Prefix string `json:"prefix"`
+}
+
+// ImageConfigSpec contains the configuration for matching images.
+type ImageConfigSpec struct {
+	// MatchImages is a list of image matching rules that should be satisfied.
+	// +kubebuilder:validation:XValidation:rule="size(self) > 0",message="matchImages should have at least one element."
+	MatchImages []ImageMatch `json:"matchImages"`
+
+	// Registry is the configuration for the registry.
+	// +optional
+	Registry *RegistryConfig `json:"registry,omitempty"`
+
+	// Verification contains the configuration for verifying the image.
+	// +optional
+	Verification *ImageVerification `json:"verification,omitempty"`
+
+	// RewriteImage defines how a matched image's path should be rewritten.
+	// +optional
+	RewriteImage *ImageRewrite `json:"rewriteImage,omitempty"`
+}
+
+// The ImageConfig resource is used to configure settings for package images.
+//
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:resource:scope=Cluster,categories={crossplane}
+type ImageConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ImageConfigSpec `json:"spec,omitempty"`
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageVerification) DeepCopyInto(out *ImageVerification) {
+	*out = *in
+	if in.Cosign != nil {
+		in, out := &in.Cosign, &out.Cosign
+		*out = new(CosignVerificationConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CosignVerificationConfig) DeepCopyInto(out *CosignVerificationConfig) {
+	*out = *in
+	if in.Authorities != nil {
+		in, out := &in.Authorities, &out.Authorities
+		*out = make([]CosignAuthority, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CosignAuthority) DeepCopyInto(out *CosignAuthority) {
+	*out = *in
+	if in.Key != nil {
+		in, out := &in.Key, &out.Key
+		*out = new(PublicKeyRef)
+		**out = **in
+	}
+	if in.Keyless != nil {
+		in, out := &in.Keyless, &out.Keyless
+		*out = new(KeylessRef)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Attestations != nil {
+		in, out := &in.Attestations, &out.Attestations
+		*out = make([]Attestation, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeylessRef) DeepCopyInto(out *KeylessRef) {
+	*out = *in
+	if in.Identities != nil {
+		in, out := &in.Identities, &out.Identities
+		*out = make([]CosignIdentity, len(*in))
+		copy(*out, *in)
+	}
+	if in.InsecureIgnoreSCT != nil {
+		in, out := &in.InsecureIgnoreSCT, &out.InsecureIgnoreSCT
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageConfigSpec) DeepCopyInto(out *ImageConfigSpec) {
+	*out = *in
+	if in.MatchImages != nil {
+		in, out := &in.MatchImages, &out.MatchImages
+		*out = make([]ImageMatch, len(*in))
+		copy(*out, *in)
+	}
+	if in.Registry != nil {
+		in, out := &in.Registry, &out.Registry
+		*out = new(RegistryConfig)
+		if (*in).Authentication != nil {
+			(*out).Authentication = new(RegistryAuthentication)
+			*(*out).Authentication = *(*in).Authentication
+		}
+	}
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = new(ImageVerification)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RewriteImage != nil {
+		in, out := &in.RewriteImage, &out.RewriteImage
+		*out = new(ImageRewrite)
+		**out = **in
+	}
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageConfig) DeepCopyInto(out *ImageConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageConfig.
+func (in *ImageConfig) DeepCopy() *ImageConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageConfig)
+	in.DeepCopyInto(out)
+	return out
+}
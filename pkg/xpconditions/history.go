@@ -0,0 +1,213 @@
+package xpconditions
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+)
+
+// DefaultTerminalReasons are the condition reasons treated as terminal, if HistoryOptions.TerminalReasons is empty
+var DefaultTerminalReasons = []string{"ReconcileError"}
+
+// DefaultStuckAfter is the default time an object may report the same terminal reason before a wait fails
+const DefaultStuckAfter = time.Minute
+
+// HistoryOptions configures how the condition history of objects is evaluated during a wait
+type HistoryOptions struct {
+	// TerminalReasons are condition reasons which are not expected to recover, defaults to DefaultTerminalReasons
+	TerminalReasons []string
+	// StuckAfter is the time an object may continuously report the same terminal reason before the wait fails,
+	// defaults to DefaultStuckAfter
+	StuckAfter time.Duration
+	// StuckPolls is the number of consecutive observations an object may report the same terminal reason before the
+	// wait fails, 0 disables the check. Waits of a Watcher observe the objects on every event as well, so a low
+	// number may let transient errors during creation fail the wait.
+	StuckPolls int
+	// MaxFlaps is the number of times an object may stop matching after it matched before the wait fails, 0 disables the check
+	MaxFlaps int
+	// Stability is the duration objects need to match continuously before the wait succeeds, 0 disables the check
+	Stability time.Duration
+}
+
+func (o HistoryOptions) terminalReasons() []string {
+	if len(o.TerminalReasons) == 0 {
+		return DefaultTerminalReasons
+	}
+	return o.TerminalReasons
+}
+
+func (o HistoryOptions) stuckAfter() time.Duration {
+	if o.StuckAfter <= 0 {
+		return DefaultStuckAfter
+	}
+	return o.StuckAfter
+}
+
+// Transition is a change of the observed state of an object
+type Transition struct {
+	Time time.Time
+	// Matched reports if the object matched at that time
+	Matched bool
+	// Conditions describes all conditions of the object, like `Synced=True, Ready=False (Creating)`
+	Conditions string
+}
+
+func (t Transition) String() string {
+	return fmt.Sprintf("%s matched=%t %s", t.Time.Format(time.RFC3339), t.Matched, t.Conditions)
+}
+
+type objectHistory struct {
+	transitions []Transition
+	flaps       int
+	// terminal is the last observed terminal condition, terminalSince the time its type and reason were first observed
+	terminal      *ConditionStatus
+	terminalSince time.Time
+	// terminalPolls is the number of consecutive observations of the terminal condition
+	terminalPolls int
+}
+
+// Tracker records the condition history of objects during a wait and detects objects that
+// flap between matching and not matching or are stuck with a terminal reason.
+type Tracker struct {
+	options HistoryOptions
+	now     func() time.Time
+
+	mu        sync.Mutex
+	histories map[string]*objectHistory
+}
+
+// NewTracker creates a Tracker with the given options
+func NewTracker(options HistoryOptions) *Tracker {
+	return &Tracker{options: options, now: time.Now, histories: map[string]*objectHistory{}}
+}
+
+// Observe records the current state of the referenced object and whether it matched.
+// It returns a *StuckError or *FlappingError, if the object is not expected to converge anymore.
+func (t *Tracker) Observe(ref string, obj *unstructured.Unstructured, matched bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.histories[ref]
+	if !ok {
+		h = &objectHistory{}
+		t.histories[ref] = h
+	}
+
+	now := t.now()
+	conditions := "not found"
+	if obj != nil {
+		conditions = describeConditions(obj)
+	}
+	if n := len(h.transitions); n == 0 || h.transitions[n-1].Conditions != conditions || h.transitions[n-1].Matched != matched {
+		if n > 0 && h.transitions[n-1].Matched && !matched {
+			h.flaps++
+		}
+		h.transitions = append(h.transitions, Transition{Time: now, Matched: matched, Conditions: conditions})
+	}
+	if t.options.MaxFlaps > 0 && h.flaps > t.options.MaxFlaps {
+		return &FlappingError{Ref: ref, Flaps: h.flaps, Transitions: h.copyTransitions()}
+	}
+
+	terminal, ok := t.terminalCondition(obj)
+	switch {
+	case matched || !ok:
+		h.terminal = nil
+		return nil
+	case h.terminal == nil || terminal.Type != h.terminal.Type || terminal.Reason != h.terminal.Reason:
+		// a changing message of the same reason, e.g. a retried error, doesn't start over
+		h.terminalSince = now
+		h.terminalPolls = 0
+	}
+	h.terminal = &terminal
+	h.terminalPolls++
+	stuckFor := now.Sub(h.terminalSince)
+	if stuckFor >= t.options.stuckAfter() || (t.options.StuckPolls > 0 && h.terminalPolls >= t.options.StuckPolls) {
+		return &StuckError{Ref: ref, Condition: terminal, Duration: stuckFor, Polls: h.terminalPolls}
+	}
+	return nil
+}
+
+// History returns the recorded transitions of the referenced object, oldest first
+func (t *Tracker) History(ref string) []Transition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.histories[ref]
+	if !ok {
+		return nil
+	}
+	return h.copyTransitions()
+}
+
+func (t *Tracker) terminalCondition(obj *unstructured.Unstructured) (ConditionStatus, bool) {
+	if obj == nil {
+		return ConditionStatus{}, false
+	}
+	conditions, _, _ := unstructured.NestedSlice(obj.UnstructuredContent(), "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditionType, _ := condition["type"].(string)
+		status, _ := GetCondition(obj, conditionType)
+		for _, reason := range t.options.terminalReasons() {
+			if status.Reason == reason {
+				return status, true
+			}
+		}
+	}
+	return ConditionStatus{}, false
+}
+
+func (h *objectHistory) copyTransitions() []Transition {
+	return append([]Transition(nil), h.transitions...)
+}
+
+// StuckError is returned if an object reported the same terminal reason for longer than HistoryOptions.StuckAfter
+// or HistoryOptions.StuckPolls observations
+type StuckError struct {
+	Ref string
+	// Condition is the last observed terminal condition
+	Condition ConditionStatus
+	Duration  time.Duration
+	// Polls is the number of consecutive observations of the terminal reason
+	Polls int
+}
+
+// Error implements error
+func (e *StuckError) Error() string {
+	return fmt.Sprintf("%s is stuck with %s for %s (%d observations)", e.Ref, e.Condition, e.Duration, e.Polls)
+}
+
+// FlappingError is returned if an object stopped matching more often than allowed
+type FlappingError struct {
+	Ref         string
+	Flaps       int
+	Transitions []Transition
+}
+
+// Error implements error
+func (e *FlappingError) Error() string {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("%s is flapping, it stopped matching %d time(s)", e.Ref, e.Flaps))
+	for _, transition := range e.Transitions {
+		b.WriteString("\n    ")
+		b.WriteString(transition.String())
+	}
+	return b.String()
+}
+
+// AwaitMatchWithHistory waits like AwaitMatch, but tracks the condition history of every object.
+// The wait fails fast with a *StuckError or *FlappingError wrapped in a *MatchError, and if
+// HistoryOptions.Stability is set, objects need to match for at least that duration.
+// Flaps are counted on every observation, also while an object isn't stable yet.
+func AwaitMatchWithHistory(w Waiter, matcher Matcher, refs []ObjectReference, options HistoryOptions, opts ...wait.Option) error {
+	var done Matcher
+	if options.Stability > 0 {
+		done = ForAtLeast(options.Stability, matcher)
+	}
+	return awaitMatch(w, matcher, done, refs, NewTracker(options), opts...)
+}
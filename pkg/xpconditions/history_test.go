@@ -0,0 +1,127 @@
+package xpconditions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+)
+
+func TestTracker_Stuck(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewTracker(HistoryOptions{StuckAfter: time.Minute})
+	tracker.now = func() time.Time { return now }
+	failing := testObject(nopGVK, "example", conditionWithReason("Synced", corev1.ConditionFalse, "ReconcileError", "boom"))
+	failingAgain := testObject(nopGVK, "example", conditionWithReason("Synced", corev1.ConditionFalse, "ReconcileError", "boom again"))
+	creating := testObject(nopGVK, "example", conditionWithReason("Ready", corev1.ConditionFalse, "Creating", ""))
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, tracker.Observe("example", failing, false), "many observations within the threshold")
+	}
+	now = now.Add(50 * time.Second)
+	require.NoError(t, tracker.Observe("example", creating, false), "recovered, the threshold starts over")
+	require.NoError(t, tracker.Observe("example", failing, false))
+	now = now.Add(30 * time.Second)
+	require.NoError(t, tracker.Observe("example", failingAgain, false))
+
+	now = now.Add(30 * time.Second)
+	err := tracker.Observe("example", failingAgain, false)
+	var stuck *StuckError
+	require.True(t, errors.As(err, &stuck), "a changing message of the same reason is still stuck")
+	require.Equal(t, "example is stuck with Synced=False (ReconcileError): boom again for 1m0s (3 observations)", err.Error())
+	require.Len(t, tracker.History("example"), 4)
+}
+
+func TestTracker_StuckPolls(t *testing.T) {
+	tracker := NewTracker(HistoryOptions{StuckAfter: time.Hour, StuckPolls: 3})
+	failing := testObject(nopGVK, "example", conditionWithReason("Synced", corev1.ConditionFalse, "ReconcileError", "boom"))
+	creating := testObject(nopGVK, "example", conditionWithReason("Ready", corev1.ConditionFalse, "Creating", ""))
+
+	require.NoError(t, tracker.Observe("example", failing, false))
+	require.NoError(t, tracker.Observe("example", failing, false))
+	require.NoError(t, tracker.Observe("example", creating, false), "recovered, the count starts over")
+	require.NoError(t, tracker.Observe("example", failing, false))
+	require.NoError(t, tracker.Observe("example", failing, false))
+
+	err := tracker.Observe("example", failing, false)
+	var stuck *StuckError
+	require.True(t, errors.As(err, &stuck))
+	require.Equal(t, 3, stuck.Polls)
+}
+
+func TestTracker_Flapping(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewTracker(HistoryOptions{MaxFlaps: 1, TerminalReasons: []string{"Unused"}})
+	tracker.now = func() time.Time { return now }
+	ready := testObject(nopGVK, "example", condition("Ready", corev1.ConditionTrue))
+	notReady := testObject(nopGVK, "example", condition("Ready", corev1.ConditionFalse))
+
+	require.NoError(t, tracker.Observe("example", ready, true))
+	require.NoError(t, tracker.Observe("example", ready, true), "unchanged state is no transition")
+	require.NoError(t, tracker.Observe("example", notReady, false))
+	require.NoError(t, tracker.Observe("example", ready, true))
+
+	err := tracker.Observe("example", notReady, false)
+	var flapping *FlappingError
+	require.True(t, errors.As(err, &flapping))
+	require.Equal(t, 2, flapping.Flaps)
+	require.Len(t, flapping.Transitions, 4)
+	require.Contains(t, err.Error(), "2024-01-01T00:00:00Z matched=false Ready=False")
+}
+
+func TestAwaitMatchWithHistory(t *testing.T) {
+	w, _ := newTestWatcher(t,
+		testObject(nopGVK, "ready", condition("Synced", corev1.ConditionTrue), condition("Ready", corev1.ConditionTrue)),
+		testObject(nopGVK, "failing", conditionWithReason("Synced", corev1.ConditionFalse, "ReconcileError", "boom")),
+	)
+	refs := []ObjectReference{RefOf(testObject(nopGVK, "ready")), RefOf(testObject(nopGVK, "failing"))}
+
+	start := time.Now()
+	err := AwaitMatchWithHistory(w, ManagedResourceReady(), refs, HistoryOptions{StuckAfter: 50 * time.Millisecond},
+		wait.WithTimeout(time.Minute), wait.WithInterval(10*time.Millisecond))
+	require.Less(t, time.Since(start), time.Minute, "stuck resources fail fast")
+	var stuck *StuckError
+	require.True(t, errors.As(err, &stuck))
+	var matchErr *MatchError
+	require.True(t, errors.As(err, &matchErr))
+	require.Contains(t, matchErr.Mismatches, refs[1].String())
+
+	require.NoError(t, AwaitMatchWithHistory(w, ManagedResourceReady(), refs[:1], HistoryOptions{Stability: 50 * time.Millisecond},
+		wait.WithTimeout(time.Second), wait.WithInterval(10*time.Millisecond)))
+}
+
+func TestAwaitMatchWithHistory_StabilityAndMaxFlaps(t *testing.T) {
+	ready := testObject(nopGVK, "example", condition("Synced", corev1.ConditionTrue), condition("Ready", corev1.ConditionTrue))
+	notReady := testObject(nopGVK, "example", condition("Synced", corev1.ConditionTrue), condition("Ready", corev1.ConditionFalse))
+	w, cl := newTestWatcher(t, ready.DeepCopy())
+	gvr := schema.GroupVersionResource{Group: nopGVK.Group, Version: nopGVK.Version, Resource: "nopresources"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		// flips faster than the stability, so the object never counts as stable
+		for i := 0; ctx.Err() == nil; i++ {
+			obj := ready
+			if i%2 == 0 {
+				obj = notReady
+			}
+			_, _ = cl.Resource(gvr).Update(ctx, obj.DeepCopy(), metav1.UpdateOptions{})
+			time.Sleep(30 * time.Millisecond)
+		}
+	}()
+
+	err := AwaitMatchWithHistory(w, ManagedResourceReady(), []ObjectReference{RefOf(ready)},
+		HistoryOptions{Stability: time.Minute, MaxFlaps: 2},
+		wait.WithTimeout(30*time.Second), wait.WithInterval(5*time.Millisecond))
+	var flapping *FlappingError
+	require.True(t, errors.As(err, &flapping), "flaps are counted before the object is stable: %v", err)
+	var matchErr *MatchError
+	require.True(t, errors.As(err, &matchErr))
+	require.Contains(t, matchErr.Description, "for at least 1m0s")
+}
@@ -0,0 +1,352 @@
+package xpconditions
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+)
+
+// Matcher matches objects, e.g. on their status conditions, and describes itself in a human-readable way.
+// Matchers can be combined with And, Or, Not and ForAtLeast.
+type Matcher interface {
+	// Matches returns if the object matches
+	Matches(obj *unstructured.Unstructured) bool
+	// Description returns a human-readable description of what is matched, used in error messages
+	Description() string
+}
+
+// ConditionMatcher matches a single status condition of an object, see HasCondition
+type ConditionMatcher struct {
+	conditionType string
+	status        *corev1.ConditionStatus
+	reason        *regexp.Regexp
+	message       string
+}
+
+// HasCondition returns a matcher for objects that have a condition of the given type.
+// The matcher can be narrowed down via WithStatus, WithReason and WithMessageContaining,
+// which return a copy, so a matcher can be reused as base of several others.
+func HasCondition(conditionType string) *ConditionMatcher {
+	return &ConditionMatcher{conditionType: conditionType}
+}
+
+// WithStatus requires the condition to have the given status
+func (m *ConditionMatcher) WithStatus(status corev1.ConditionStatus) *ConditionMatcher {
+	c := *m
+	c.status = &status
+	return &c
+}
+
+// WithReason requires the reason of the condition to match the regular expression, e.g. regexp.MustCompile("^Creat")
+func (m *ConditionMatcher) WithReason(pattern *regexp.Regexp) *ConditionMatcher {
+	c := *m
+	c.reason = pattern
+	return &c
+}
+
+// WithMessageContaining requires the message of the condition to contain the substring
+func (m *ConditionMatcher) WithMessageContaining(substr string) *ConditionMatcher {
+	c := *m
+	c.message = substr
+	return &c
+}
+
+// Matches returns if the object has a matching condition
+func (m *ConditionMatcher) Matches(obj *unstructured.Unstructured) bool {
+	condition, ok := GetCondition(obj, m.conditionType)
+	if !ok || condition.Status == "" {
+		return false
+	}
+	if m.status != nil && condition.Status != string(*m.status) {
+		return false
+	}
+	if m.reason != nil && !m.reason.MatchString(condition.Reason) {
+		return false
+	}
+	return strings.Contains(condition.Message, m.message)
+}
+
+// Description describes the matched condition, e.g. `Synced is False with reason matching "ReconcileError"`
+func (m *ConditionMatcher) Description() string {
+	b := strings.Builder{}
+	b.WriteString(m.conditionType)
+	if m.status != nil {
+		b.WriteString(fmt.Sprintf(" is %s", *m.status))
+	} else {
+		b.WriteString(" is present")
+	}
+	var details []string
+	if m.reason != nil {
+		details = append(details, fmt.Sprintf("reason matching %q", m.reason.String()))
+	}
+	if m.message != "" {
+		details = append(details, fmt.Sprintf("message containing %q", m.message))
+	}
+	if len(details) > 0 {
+		b.WriteString(" with ")
+		b.WriteString(strings.Join(details, " and "))
+	}
+	return b.String()
+}
+
+// MatchFunc adapts the matcher to the match functions used by ResourcesMatch, so it works for typed objects as well
+func MatchFunc(matcher Matcher) func(object k8s.Object) bool {
+	return func(object k8s.Object) bool {
+		us := convertToUnstructured(object)
+		return us != nil && matcher.Matches(us)
+	}
+}
+
+// ManagedResourceReady matches objects with conditions Synced = True and Ready = True
+func ManagedResourceReady() Matcher {
+	return And(
+		HasCondition("Synced").WithStatus(corev1.ConditionTrue),
+		HasCondition("Ready").WithStatus(corev1.ConditionTrue),
+	)
+}
+
+// Paused matches objects with condition Synced = False and reason ReconcilePaused
+func Paused() Matcher {
+	return HasCondition("Synced").WithStatus(corev1.ConditionFalse).WithReason(reconcilePaused)
+}
+
+var reconcilePaused = regexp.MustCompile("^ReconcilePaused$")
+
+type combinedMatcher struct {
+	matchers []Matcher
+	all      bool
+}
+
+// And matches objects that match all given matchers
+func And(matchers ...Matcher) Matcher {
+	return &combinedMatcher{matchers: matchers, all: true}
+}
+
+// Or matches objects that match at least one of the given matchers
+func Or(matchers ...Matcher) Matcher {
+	return &combinedMatcher{matchers: matchers, all: false}
+}
+
+func (m *combinedMatcher) Matches(obj *unstructured.Unstructured) bool {
+	// all matchers are evaluated, so that stateful matchers like ForAtLeast see every object
+	result := m.all
+	for _, matcher := range m.matchers {
+		if m.all {
+			result = matcher.Matches(obj) && result
+		} else {
+			result = matcher.Matches(obj) || result
+		}
+	}
+	return result
+}
+
+func (m *combinedMatcher) Description() string {
+	descriptions := make([]string, 0, len(m.matchers))
+	for _, matcher := range m.matchers {
+		descriptions = append(descriptions, matcher.Description())
+	}
+	operator := " or "
+	if m.all {
+		operator = " and "
+	}
+	if len(descriptions) == 1 {
+		return descriptions[0]
+	}
+	return "(" + strings.Join(descriptions, operator) + ")"
+}
+
+type notMatcher struct {
+	matcher Matcher
+}
+
+// Not matches objects that don't match the given matcher
+func Not(matcher Matcher) Matcher {
+	return &notMatcher{matcher: matcher}
+}
+
+func (m *notMatcher) Matches(obj *unstructured.Unstructured) bool {
+	return !m.matcher.Matches(obj)
+}
+
+func (m *notMatcher) Description() string {
+	return fmt.Sprintf("not %s", m.matcher.Description())
+}
+
+type durationMatcher struct {
+	matcher  Matcher
+	duration time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	since map[string]time.Time
+}
+
+// ForAtLeast matches objects that continuously matched the given matcher for at least the duration.
+// The matcher is stateful, it only knows about matches it observed itself, so it should be used for a single wait.
+func ForAtLeast(duration time.Duration, matcher Matcher) Matcher {
+	return &durationMatcher{matcher: matcher, duration: duration, now: time.Now, since: map[string]time.Time{}}
+}
+
+func (m *durationMatcher) Matches(obj *unstructured.Unstructured) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := objectKey(obj)
+	if !m.matcher.Matches(obj) {
+		delete(m.since, key)
+		return false
+	}
+	since, ok := m.since[key]
+	if !ok {
+		since = m.now()
+		m.since[key] = since
+	}
+	return m.now().Sub(since) >= m.duration
+}
+
+func (m *durationMatcher) Description() string {
+	return fmt.Sprintf("%s for at least %s", m.matcher.Description(), m.duration)
+}
+
+func objectKey(obj *unstructured.Unstructured) string {
+	if uid := obj.GetUID(); uid != "" {
+		return string(uid)
+	}
+	return RefOf(obj).String()
+}
+
+// ObjectReference identifies an object by its GroupVersionKind, namespace and name
+type ObjectReference struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+}
+
+// RefOf returns the reference of the given object, the object is expected to have its GroupVersionKind set
+func RefOf(obj k8s.Object) ObjectReference {
+	return ObjectReference{
+		GroupVersionKind: obj.GetObjectKind().GroupVersionKind(),
+		Namespace:        obj.GetNamespace(),
+		Name:             obj.GetName(),
+	}
+}
+
+// String returns the reference in the format of `group/version, Kind=kind/[namespace/]name`
+func (r ObjectReference) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s/%s", r.GroupVersionKind.String(), r.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", r.GroupVersionKind.String(), r.Namespace, r.Name)
+}
+
+// MatchError is returned by AwaitMatch, if not all objects matched in time
+type MatchError struct {
+	// Err is the error of the underlying wait
+	Err error
+	// Description is the description of the matcher
+	Description string
+	// Mismatches maps the references of all objects that did not match to their last observed state
+	Mismatches map[string]string
+}
+
+// Error implements error
+func (e *MatchError) Error() string {
+	refs := make([]string, 0, len(e.Mismatches))
+	for ref := range e.Mismatches {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("%d object(s) did not match %s: %v", len(refs), e.Description, e.Err))
+	for _, ref := range refs {
+		b.WriteString(fmt.Sprintf("\n  %s: %s", ref, e.Mismatches[ref]))
+	}
+	return b.String()
+}
+
+// Unwrap returns the error of the underlying wait
+func (e *MatchError) Unwrap() error {
+	return e.Err
+}
+
+// AwaitMatch waits until all referenced objects match the matcher.
+// If the wait fails, a *MatchError describing the matcher and the last observed state of every mismatching object is returned.
+func AwaitMatch(w Waiter, matcher Matcher, refs []ObjectReference, opts ...wait.Option) error {
+	return awaitMatch(w, matcher, nil, refs, nil, opts...)
+}
+
+// awaitMatch waits until all objects match. The tracker observes the result of the matcher, done, if set, decides
+// instead of the matcher when an object is done, e.g. a ForAtLeast of the matcher.
+func awaitMatch(w Waiter, matcher Matcher, done Matcher, refs []ObjectReference, tracker *Tracker, opts ...wait.Option) error {
+	description := matcher.Description()
+	if done != nil {
+		description = done.Description()
+	}
+	var mu sync.Mutex
+	mismatches := map[string]string{}
+	cond := func(ctx context.Context) (bool, error) {
+		current := map[string]string{}
+		defer func() {
+			mu.Lock()
+			mismatches = current
+			mu.Unlock()
+		}()
+		for _, ref := range refs {
+			obj, err := w.Get(ctx, ref)
+			if err != nil {
+				return false, err
+			}
+			matched := obj != nil && matcher.Matches(obj)
+			complete := matched
+			if done != nil && obj != nil {
+				complete = done.Matches(obj)
+			}
+			switch {
+			case obj == nil:
+				current[ref.String()] = "not found"
+			case !complete:
+				current[ref.String()] = describeConditions(obj)
+			}
+			if tracker != nil {
+				if err := tracker.Observe(ref.String(), obj, matched); err != nil {
+					return false, err
+				}
+			}
+		}
+		return len(current) == 0, nil
+	}
+	if err := w.For(cond, opts...); err != nil {
+		mu.Lock()
+		defer mu.Unlock()
+		return &MatchError{Err: err, Description: description, Mismatches: mismatches}
+	}
+	return nil
+}
+
+// describeConditions lists all conditions of the object, like `Synced=True, Ready=False (Creating)`
+func describeConditions(obj *unstructured.Unstructured) string {
+	conditions, _, _ := unstructured.NestedSlice(obj.UnstructuredContent(), "status", "conditions")
+	if len(conditions) == 0 {
+		return "no conditions"
+	}
+	descriptions := make([]string, 0, len(conditions))
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditionType, _ := condition["type"].(string)
+		status, _ := GetCondition(obj, conditionType)
+		descriptions = append(descriptions, status.String())
+	}
+	return strings.Join(descriptions, ", ")
+}
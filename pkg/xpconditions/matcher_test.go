@@ -0,0 +1,147 @@
+package xpconditions
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+)
+
+func conditionWithReason(conditionType string, status corev1.ConditionStatus, reason string, message string) interface{} {
+	return map[string]interface{}{"type": conditionType, "status": string(status), "reason": reason, "message": message}
+}
+
+func TestMatchers(t *testing.T) {
+	synced := testObject(nopGVK, "example",
+		conditionWithReason("Synced", corev1.ConditionTrue, "ReconcileSuccess", ""),
+		conditionWithReason("Ready", corev1.ConditionFalse, "Creating", "waiting for external resource"),
+	)
+
+	tests := []struct {
+		name        string
+		matcher     Matcher
+		want        bool
+		description string
+	}{
+		{
+			name:        "status",
+			matcher:     HasCondition("Synced").WithStatus(corev1.ConditionTrue),
+			want:        true,
+			description: "Synced is True",
+		},
+		{
+			name:        "presence",
+			matcher:     HasCondition("Healthy"),
+			want:        false,
+			description: "Healthy is present",
+		},
+		{
+			name:        "reason and message",
+			matcher:     HasCondition("Ready").WithStatus(corev1.ConditionFalse).WithReason(regexp.MustCompile("^Creat")).WithMessageContaining("external"),
+			want:        true,
+			description: `Ready is False with reason matching "^Creat" and message containing "external"`,
+		},
+		{
+			name:        "reason mismatch",
+			matcher:     HasCondition("Ready").WithReason(regexp.MustCompile("^Available$")),
+			want:        false,
+			description: `Ready is present with reason matching "^Available$"`,
+		},
+		{
+			name:        "and",
+			matcher:     ManagedResourceReady(),
+			want:        false,
+			description: "(Synced is True and Ready is True)",
+		},
+		{
+			name:        "or",
+			matcher:     Or(HasCondition("Ready").WithStatus(corev1.ConditionTrue), HasCondition("Synced").WithStatus(corev1.ConditionTrue)),
+			want:        true,
+			description: "(Ready is True or Synced is True)",
+		},
+		{
+			name:        "not",
+			matcher:     Not(Paused()),
+			want:        true,
+			description: `not Synced is False with reason matching "^ReconcilePaused$"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.matcher.Matches(synced))
+			require.Equal(t, tt.description, tt.matcher.Description())
+		})
+	}
+}
+
+func TestConditionMatcher_copies(t *testing.T) {
+	base := HasCondition("Ready")
+	notReady := base.WithStatus(corev1.ConditionFalse)
+	creating := notReady.WithReason(regexp.MustCompile("^Creating$"))
+
+	require.Equal(t, "Ready is present", base.Description())
+	require.Equal(t, "Ready is False", notReady.Description())
+	require.Equal(t, `Ready is False with reason matching "^Creating$"`, creating.Description())
+}
+
+func TestForAtLeast(t *testing.T) {
+	now := time.Now()
+	m := ForAtLeast(time.Minute, HasCondition("Ready").WithStatus(corev1.ConditionTrue)).(*durationMatcher)
+	m.now = func() time.Time { return now }
+
+	ready := testObject(nopGVK, "example", condition("Ready", corev1.ConditionTrue))
+	notReady := testObject(nopGVK, "example", condition("Ready", corev1.ConditionFalse))
+
+	require.False(t, m.Matches(ready), "just became ready")
+	now = now.Add(time.Minute)
+	require.True(t, m.Matches(ready), "ready for a minute")
+	require.False(t, m.Matches(notReady), "flapped")
+	require.False(t, m.Matches(ready), "ready again, duration starts over")
+	require.Equal(t, "Ready is True for at least 1m0s", m.Description())
+}
+
+func TestMatchFunc(t *testing.T) {
+	ds := &v1.DaemonSet{Status: v1.DaemonSetStatus{Conditions: []v1.DaemonSetCondition{
+		{Type: "Synced", Status: corev1.ConditionTrue},
+		{Type: "Ready", Status: corev1.ConditionTrue},
+	}}}
+	require.True(t, MatchFunc(ManagedResourceReady())(ds))
+}
+
+func TestAwaitMatch(t *testing.T) {
+	w, _ := newTestWatcher(t,
+		testObject(nopGVK, "ready", condition("Synced", corev1.ConditionTrue), condition("Ready", corev1.ConditionTrue)),
+		testObject(nopGVK, "failing", conditionWithReason("Synced", corev1.ConditionFalse, "ReconcileError", "boom")),
+	)
+
+	ready := RefOf(testObject(nopGVK, "ready"))
+	require.NoError(t, AwaitMatch(w, ManagedResourceReady(), []ObjectReference{ready}, wait.WithTimeout(time.Second)))
+
+	err := AwaitMatch(w, ManagedResourceReady(), []ObjectReference{
+		ready,
+		RefOf(testObject(nopGVK, "failing")),
+		{GroupVersionKind: nopGVK, Name: "missing"},
+	}, wait.WithTimeout(100*time.Millisecond))
+
+	var matchErr *MatchError
+	require.True(t, errors.As(err, &matchErr))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, `2 object(s) did not match (Synced is True and Ready is True): context deadline exceeded
+  nop.crossplane.io/v1alpha1, Kind=NopResource/failing: Synced=False (ReconcileError): boom
+  nop.crossplane.io/v1alpha1, Kind=NopResource/missing: not found`, err.Error())
+}
+
+func TestObjectReference_String(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(nopGVK)
+	obj.SetName("example")
+	obj.SetNamespace("default")
+	require.Equal(t, "nop.crossplane.io/v1alpha1, Kind=NopResource/default/example", RefOf(obj).String())
+}
@@ -0,0 +1,378 @@
+package xpconditions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apimachinerywait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+)
+
+const (
+	defaultWatchTimeout = 5 * time.Minute
+	// defaultResyncInterval re-evaluates a condition even without changes, e.g. for objects of kinds
+	// whose CRDs have not been established when the wait started
+	defaultResyncInterval = 5 * time.Second
+)
+
+// ErrWatcherStopped is returned by waits of a Watcher, which has been stopped
+var ErrWatcherStopped = errors.New("watcher: stopped")
+
+var providerGVK = schema.GroupVersionKind{Group: "pkg.crossplane.io", Version: "v1", Kind: "Provider"}
+
+// Waiter waits for conditions on crossplane objects.
+// It is implemented by the polling Conditions and the informer based Watcher.
+type Waiter interface {
+	// ProviderConditionMatch checks if a Provider has a matching condition
+	ProviderConditionMatch(name string, conditionType string, conditionStatus corev1.ConditionStatus) apimachinerywait.ConditionWithContextFunc
+	// ResourcesMatch checks if all objects of the list exist and match the given function
+	ResourcesMatch(list k8s.ObjectList, matchFetcher func(object k8s.Object) bool) apimachinerywait.ConditionWithContextFunc
+	// ManagedResourcesReadyAndReady checks if a list of ManagedResources has a matching condition
+	ManagedResourcesReadyAndReady(list k8s.ObjectList) apimachinerywait.ConditionWithContextFunc
+	// IsManagedResourceReadyAndReady returns if a managed resource has condtions Synced = True and Ready = True
+	IsManagedResourceReadyAndReady(object k8s.Object) bool
+	// IsPaused returns if a managed resource has condition Synced = False with reason ReconcilePaused
+	IsPaused(object k8s.Object) bool
+	// For waits until the condition is met, it accepts the same options as wait.For
+	For(cond apimachinerywait.ConditionWithContextFunc, opts ...wait.Option) error
+	// Get returns the current state of the referenced object, nil if it doesn't exist
+	Get(ctx context.Context, ref ObjectReference) (*unstructured.Unstructured, error)
+}
+
+var (
+	_ Waiter = &Conditions{}
+	_ Waiter = &Watcher{}
+)
+
+// For waits until the condition is met by polling, see wait.For
+func (c *Conditions) For(cond apimachinerywait.ConditionWithContextFunc, opts ...wait.Option) error {
+	return wait.For(cond, opts...)
+}
+
+// Get returns the current state of the referenced object from the API server, nil if it doesn't exist
+func (c *Conditions) Get(ctx context.Context, ref ObjectReference) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(ref.GroupVersionKind)
+	err := c.resources.Get(ctx, ref.Name, ref.Namespace, obj)
+	if kerrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+type watcherContextKey struct{}
+
+// WithWatcher stores the watcher in the context, so that waits of this library use it instead of polling
+func WithWatcher(ctx context.Context, w *Watcher) context.Context {
+	return context.WithValue(ctx, watcherContextKey{}, w)
+}
+
+// WatcherFromContext returns the watcher stored in the context, if any
+func WatcherFromContext(ctx context.Context) (*Watcher, bool) {
+	w, ok := ctx.Value(watcherContextKey{}).(*Watcher)
+	return w, ok && w != nil
+}
+
+// FromContext returns the Watcher stored in the context or falls back to polling Conditions
+func FromContext(ctx context.Context, r *resources.Resources) Waiter {
+	if w, ok := WatcherFromContext(ctx); ok {
+		return w
+	}
+	return New(r)
+}
+
+// Watcher helps with matching resources on conditions like Conditions does, but evaluates
+// the conditions against shared informer caches instead of requesting the API server on every poll.
+// There is one dynamic informer per GroupVersionResource, which is started on first use.
+// Waits started via For are re-evaluated whenever a watched object changes.
+type Watcher struct {
+	Conditions
+	factory dynamicinformer.DynamicSharedInformerFactory
+	mapper  meta.RESTMapper
+	scheme  *runtime.Scheme
+	stop    chan struct{}
+
+	mu          sync.Mutex
+	informers   map[schema.GroupVersionResource]informers.GenericInformer
+	subscribers map[chan struct{}]struct{}
+}
+
+// NewWatcher is constructor for Watcher. Stop has to be called to release the informers.
+func NewWatcher(r *resources.Resources) (*Watcher, error) {
+	cl, err := dynamic.NewForConfig(r.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+	w := newWatcher(cl, r.GetControllerRuntimeClient().RESTMapper(), r.GetScheme())
+	w.Conditions = *New(r)
+	return w, nil
+}
+
+func newWatcher(cl dynamic.Interface, mapper meta.RESTMapper, scheme *runtime.Scheme) *Watcher {
+	return &Watcher{
+		factory:     dynamicinformer.NewDynamicSharedInformerFactory(cl, 0),
+		mapper:      mapper,
+		scheme:      scheme,
+		stop:        make(chan struct{}),
+		informers:   map[schema.GroupVersionResource]informers.GenericInformer{},
+		subscribers: map[chan struct{}]struct{}{},
+	}
+}
+
+// Stop stops all informers of the watcher and waits for them to terminate.
+// Running and later waits return ErrWatcherStopped.
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	select {
+	case <-w.stop:
+	default:
+		close(w.stop)
+	}
+	w.mu.Unlock()
+	// outside the lock, the event handlers of the terminating informers notify the subscribers
+	w.factory.Shutdown()
+}
+
+// stopped returns whether Stop has been called
+func (w *Watcher) stopped() bool {
+	select {
+	case <-w.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// ProviderConditionMatch checks if a Provider has a matching condition
+func (w *Watcher) ProviderConditionMatch(
+	name string,
+	conditionType string,
+	conditionStatus corev1.ConditionStatus,
+) apimachinerywait.ConditionWithContextFunc {
+	return func(ctx context.Context) (done bool, err error) {
+		klog.V(4).Infof("Awaiting provider %s to be ready", name)
+		providerObject, err := w.get(ctx, providerGVK, "", name)
+		if err != nil || providerObject == nil {
+			return false, err
+		}
+		return checkCondition(providerObject, conditionType, conditionStatus), nil
+	}
+}
+
+// ResourcesMatch checks if all named objects of the list exist and match the given function.
+// Like its polling counterpart, it updates the objects of the list with their current state.
+func (w *Watcher) ResourcesMatch(list k8s.ObjectList, matchFetcher func(object k8s.Object) bool) apimachinerywait.ConditionWithContextFunc {
+	metaList, err := meta.ExtractList(list)
+	if err != nil {
+		return func(ctx context.Context) (done bool, err error) { return false, err }
+	}
+	objects := make([]k8s.Object, 0, len(metaList))
+	for _, o := range metaList {
+		obj, ok := o.(k8s.Object)
+		if !ok {
+			return func(ctx context.Context) (done bool, err error) {
+				return false, fmt.Errorf("condition: unexpected type %T in list, does not satisfy k8s.Object", o)
+			}
+		}
+		if obj.GetName() != "" {
+			objects = append(objects, obj)
+		}
+	}
+	return func(ctx context.Context) (done bool, err error) {
+		for _, obj := range objects {
+			matched, err := w.match(ctx, obj, matchFetcher)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+}
+
+// ManagedResourcesReadyAndReady checks if a list of ManagedResources has a matching condition
+func (w *Watcher) ManagedResourcesReadyAndReady(list k8s.ObjectList) apimachinerywait.ConditionWithContextFunc {
+	return w.ResourcesMatch(list, w.IsManagedResourceReadyAndReady)
+}
+
+// For waits until the condition is met. The condition is evaluated immediately, whenever a watched object
+// changes and at the latest after the configured interval. It accepts the same options as wait.For.
+func (w *Watcher) For(cond apimachinerywait.ConditionWithContextFunc, opts ...wait.Option) error {
+	options := &wait.Options{Timeout: defaultWatchTimeout, Interval: defaultResyncInterval}
+	for _, fn := range opts {
+		fn(options)
+	}
+	ctx := options.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, options.Timeout)
+	defer cancel()
+
+	changed := w.subscribe()
+	defer w.unsubscribe(changed)
+	ticker := time.NewTicker(options.Interval)
+	defer ticker.Stop()
+
+	for {
+		if w.stopped() {
+			return ErrWatcherStopped
+		}
+		done, err := cond(ctx)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.stop:
+			return ErrWatcherStopped
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Get returns the current state of the referenced object from the informer cache, nil if it doesn't exist
+func (w *Watcher) Get(ctx context.Context, ref ObjectReference) (*unstructured.Unstructured, error) {
+	return w.get(ctx, ref.GroupVersionKind, ref.Namespace, ref.Name)
+}
+
+// match fetches the current state of obj from the informer cache into obj and applies the match function
+func (w *Watcher) match(ctx context.Context, obj k8s.Object, matchFetcher func(object k8s.Object) bool) (bool, error) {
+	gvks, _, err := w.scheme.ObjectKinds(obj)
+	if err != nil {
+		return false, err
+	}
+	current, err := w.get(ctx, gvks[0], obj.GetNamespace(), obj.GetName())
+	if err != nil || current == nil {
+		return false, err
+	}
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		current.DeepCopyInto(u)
+	} else if err := runtime.DefaultUnstructuredConverter.FromUnstructured(current.UnstructuredContent(), obj); err != nil {
+		return false, err
+	}
+	return matchFetcher(obj), nil
+}
+
+// get returns the object from the informer cache, nil if it doesn't exist (yet)
+func (w *Watcher) get(ctx context.Context, gvk schema.GroupVersionKind, namespace string, name string) (*unstructured.Unstructured, error) {
+	mapping, err := w.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		// the CRD might not have been established yet
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	informer, err := w.informerFor(ctx, mapping.Resource)
+	if err != nil {
+		return nil, err
+	}
+	var obj runtime.Object
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		obj, err = informer.Lister().ByNamespace(namespace).Get(name)
+	} else {
+		obj, err = informer.Lister().Get(name)
+	}
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("watcher: unexpected type %T in informer cache", obj)
+	}
+	return u.DeepCopy(), nil
+}
+
+// informerFor returns the shared informer of the resource, starting it on first use
+func (w *Watcher) informerFor(ctx context.Context, gvr schema.GroupVersionResource) (informers.GenericInformer, error) {
+	w.mu.Lock()
+	if w.stopped() {
+		w.mu.Unlock()
+		return nil, ErrWatcherStopped
+	}
+	informer, ok := w.informers[gvr]
+	if !ok {
+		informer = w.factory.ForResource(gvr)
+		_, err := informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(interface{}) { w.notify() },
+			UpdateFunc: func(interface{}, interface{}) { w.notify() },
+			DeleteFunc: func(interface{}) { w.notify() },
+		})
+		if err != nil {
+			w.mu.Unlock()
+			return nil, err
+		}
+		w.informers[gvr] = informer
+		w.factory.Start(w.stop)
+		klog.V(4).Infof("Started informer for %s", gvr.String())
+	}
+	w.mu.Unlock()
+
+	syncCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.stop:
+			cancel()
+		case <-syncCtx.Done():
+		}
+	}()
+	if !cache.WaitForCacheSync(syncCtx.Done(), informer.Informer().HasSynced) {
+		if w.stopped() {
+			return nil, ErrWatcherStopped
+		}
+		return nil, fmt.Errorf("watcher: cache of %s did not sync: %w", gvr.String(), ctx.Err())
+	}
+	return informer, nil
+}
+
+func (w *Watcher) subscribe() chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ch := make(chan struct{}, 1)
+	w.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (w *Watcher) unsubscribe(ch chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.subscribers, ch)
+}
+
+// notify wakes up all waits without blocking, a pending notification is sufficient
+func (w *Watcher) notify() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
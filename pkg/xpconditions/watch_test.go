@@ -0,0 +1,134 @@
+package xpconditions
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/e2e-framework/klient/k8s"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+)
+
+var nopGVK = schema.GroupVersionKind{Group: "nop.crossplane.io", Version: "v1alpha1", Kind: "NopResource"}
+
+type objectList struct {
+	metav1.ListInterface
+	runtime.Object
+	Items []k8s.Object
+}
+
+func testObject(gvk schema.GroupVersionKind, name string, conditions ...interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{"conditions": conditions},
+	}}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName(name)
+	return obj
+}
+
+func condition(conditionType string, status corev1.ConditionStatus) interface{} {
+	return map[string]interface{}{"type": conditionType, "status": string(status)}
+}
+
+func newTestWatcher(t *testing.T, objects ...runtime.Object) (*Watcher, *dynamicfake.FakeDynamicClient) {
+	scheme := runtime.NewScheme()
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(nopGVK, meta.RESTScopeRoot)
+	mapper.Add(providerGVK, meta.RESTScopeRoot)
+	cl := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme, map[schema.GroupVersionResource]string{
+		{Group: nopGVK.Group, Version: nopGVK.Version, Resource: "nopresources"}: "NopResourceList",
+		providerSchema: "ProviderList",
+	}, objects...)
+	w := newWatcher(cl, mapper, scheme)
+	t.Cleanup(w.Stop)
+	return w, cl
+}
+
+func TestWatcher_ManagedResourcesReadyAndReady(t *testing.T) {
+	obj := testObject(nopGVK, "example", condition("Synced", corev1.ConditionTrue))
+	w, cl := newTestWatcher(t, obj.DeepCopy())
+
+	list := &objectList{Items: []k8s.Object{testObject(nopGVK, "example")}}
+	cond := w.ManagedResourcesReadyAndReady(list)
+
+	done, err := cond(context.Background())
+	require.NoError(t, err)
+	require.False(t, done)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var waitErr error
+	go func() {
+		defer wg.Done()
+		waitErr = w.For(cond, wait.WithTimeout(10*time.Second), wait.WithInterval(time.Minute))
+	}()
+
+	ready := testObject(nopGVK, "example", condition("Synced", corev1.ConditionTrue), condition("Ready", corev1.ConditionTrue))
+	gvr := schema.GroupVersionResource{Group: nopGVK.Group, Version: nopGVK.Version, Resource: "nopresources"}
+	_, err = cl.Resource(gvr).Update(context.Background(), ready, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	wg.Wait()
+	require.NoError(t, waitErr)
+	require.True(t, w.IsManagedResourceReadyAndReady(list.Items[0]), "list items are updated with the cached state")
+}
+
+func TestWatcher_ProviderConditionMatch(t *testing.T) {
+	w, _ := newTestWatcher(t, testObject(providerGVK, "provider-nop", condition("Healthy", corev1.ConditionTrue)))
+
+	done, err := w.ProviderConditionMatch("provider-nop", "Healthy", corev1.ConditionTrue)(context.Background())
+	require.NoError(t, err)
+	require.True(t, done)
+
+	done, err = w.ProviderConditionMatch("provider-missing", "Healthy", corev1.ConditionTrue)(context.Background())
+	require.NoError(t, err)
+	require.False(t, done)
+}
+
+func TestWatcher_ForTimesOut(t *testing.T) {
+	w, _ := newTestWatcher(t)
+	err := w.For(w.ProviderConditionMatch("provider-nop", "Healthy", corev1.ConditionTrue), wait.WithTimeout(100*time.Millisecond))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWatcher_Stop(t *testing.T) {
+	w, _ := newTestWatcher(t, testObject(providerGVK, "provider-nop"))
+	cond := w.ProviderConditionMatch("provider-nop", "Healthy", corev1.ConditionTrue)
+
+	errs := make(chan error, 1)
+	go func() { errs <- w.For(cond, wait.WithTimeout(time.Minute), wait.WithInterval(time.Minute)) }()
+	require.Eventually(t, func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return len(w.subscribers) == 1
+	}, 10*time.Second, 10*time.Millisecond)
+	w.Stop()
+	select {
+	case err := <-errs:
+		require.ErrorIs(t, err, ErrWatcherStopped)
+	case <-time.After(10 * time.Second):
+		t.Fatal("running wait did not return after Stop")
+	}
+
+	start := time.Now()
+	require.ErrorIs(t, w.For(cond, wait.WithTimeout(time.Minute)), ErrWatcherStopped)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestFromContext(t *testing.T) {
+	_, ok := FromContext(context.Background(), nil).(*Conditions)
+	require.True(t, ok)
+
+	w, _ := newTestWatcher(t)
+	got := FromContext(WithWatcher(context.Background(), w), nil)
+	require.Same(t, w, got)
+}
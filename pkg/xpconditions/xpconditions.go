@@ -2,6 +2,7 @@ package xpconditions
 
 import (
 	"context"
+	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
@@ -38,14 +39,14 @@ func (c *Conditions) ProviderConditionMatch(
 	conditionType string,
 	conditionStatus corev1.ConditionStatus,
 ) apimachinerywait.ConditionWithContextFunc {
+	cl, err := dynamic.NewForConfig(c.resources.GetConfig())
+	if err != nil {
+		return func(ctx context.Context) (done bool, err error) { return false, err }
+	}
+	res := cl.Resource(providerSchema)
 	return func(ctx context.Context) (done bool, err error) {
 		klog.V(4).Infof("Awaiting provider %s to be ready", name)
 
-		cl, err := dynamic.NewForConfig(c.resources.GetConfig())
-		if err != nil {
-			return false, err
-		}
-		res := cl.Resource(providerSchema)
 		providerObject, err := res.Get(ctx, name, metav1.GetOptions{})
 		if err != nil {
 			return false, ignoreNotFound(err)
@@ -68,55 +69,85 @@ func checkCondition(unstruc *unstructured.Unstructured, desiredType string, desi
 }
 
 func checkConditionWithReason(unstruc *unstructured.Unstructured, desiredType string, desiredStatus corev1.ConditionStatus, desiredReason string) bool {
-
-	conditions, ok, err := unstructured.NestedSlice(unstruc.UnstructuredContent(), "status", "conditions")
-	if err != nil {
-		klog.V(4).Infof("Could not extract conditions of (%s) %s, %s", unstruc.GroupVersionKind().String(), unstruc.GetName(), err.Error())
-		return false
-	} else if !ok {
-		klog.V(4).Infof("Object (%s) %s doesnt have conditions", unstruc.GroupVersionKind().String(), unstruc.GetName())
+	condition, ok := GetCondition(unstruc, desiredType)
+	if !ok {
 		return false
 	}
-
-	status := ""
-	message := ""
-	reason := ""
-	for _, condition := range conditions {
-		c := condition.(map[string]interface{})
-		curType := c["type"]
-		if curType == desiredType {
-			status = c["status"].(string)
-			msg, convertible := c["message"].(string)
-			if convertible {
-				message = msg
-			}
-			if c["reason"] != nil {
-				reason = c["reason"].(string)
-			}
-		}
-	}
 	matchedConditionStatus := false
-	if status == string(desiredStatus) {
+	if condition.Status == string(desiredStatus) {
 		matchedConditionStatus = true
 	}
 	matchedConditionReason := true
 	if desiredReason != "" {
-		matchedConditionReason = reason == desiredReason
+		matchedConditionReason = condition.Reason == desiredReason
 	}
 
 	klog.V(4).Infof("Object (%s) %s, condition: %s: %s, matched: %t, message: %s, reason: %s",
 		unstruc.GroupVersionKind().String(),
 		unstruc.GetName(),
 		desiredType,
-		status,
+		condition.Status,
 		matchedConditionStatus,
-		message,
-		reason,
+		condition.Message,
+		condition.Reason,
 	)
 
 	return matchedConditionStatus && matchedConditionReason
 }
 
+// ConditionStatus holds the state of a single status condition of an object
+type ConditionStatus struct {
+	Type    string
+	Status  string
+	Reason  string
+	Message string
+}
+
+// String returns a short human-readable representation like `Ready=False (Creating): message`
+func (c ConditionStatus) String() string {
+	s := fmt.Sprintf("%s=%s", c.Type, c.Status)
+	if c.Status == "" {
+		s = fmt.Sprintf("%s=<none>", c.Type)
+	}
+	if c.Reason != "" {
+		s = fmt.Sprintf("%s (%s)", s, c.Reason)
+	}
+	if c.Message != "" {
+		s = fmt.Sprintf("%s: %s", s, c.Message)
+	}
+	return s
+}
+
+// GetCondition returns the condition of the desired type of an unstructured object.
+// If the object has no conditions at all, false is returned. If the object has conditions,
+// but none of the desired type, a ConditionStatus with an empty Status is returned.
+func GetCondition(unstruc *unstructured.Unstructured, desiredType string) (ConditionStatus, bool) {
+	result := ConditionStatus{Type: desiredType}
+	if unstruc == nil {
+		return result, false
+	}
+
+	conditions, ok, err := unstructured.NestedSlice(unstruc.UnstructuredContent(), "status", "conditions")
+	if err != nil {
+		klog.V(4).Infof("Could not extract conditions of (%s) %s, %s", unstruc.GroupVersionKind().String(), unstruc.GetName(), err.Error())
+		return result, false
+	} else if !ok {
+		klog.V(4).Infof("Object (%s) %s doesnt have conditions", unstruc.GroupVersionKind().String(), unstruc.GetName())
+		return result, false
+	}
+
+	for _, condition := range conditions {
+		c, ok := condition.(map[string]interface{})
+		if !ok || c["type"] != desiredType {
+			continue
+		}
+		result.Status, _ = c["status"].(string)
+		result.Reason, _ = c["reason"].(string)
+		result.Message, _ = c["message"].(string)
+	}
+	return result, true
+}
+
 // IsManagedResourceReadyAndReady returns if a managed resource has condtions Synced = True and Ready = True
 func (c *Conditions) IsManagedResourceReadyAndReady(object k8s.Object) bool {
 
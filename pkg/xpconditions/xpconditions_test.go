@@ -3,6 +3,7 @@ package xpconditions
 import (
 	"testing"
 
+	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -161,3 +162,32 @@ func TestConditions_IsManagedResourceReadyAndReady(t *testing.T) {
 		})
 	}
 }
+
+func TestGetCondition(t *testing.T) {
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":    "Synced",
+						"status":  "False",
+						"reason":  "ReconcileError",
+						"message": "cannot observe external resource",
+					},
+				},
+			},
+		},
+	}
+
+	got, ok := GetCondition(obj, "Synced")
+	require.True(t, ok)
+	require.Equal(t, ConditionStatus{Type: "Synced", Status: "False", Reason: "ReconcileError", Message: "cannot observe external resource"}, got)
+	require.Equal(t, "Synced=False (ReconcileError): cannot observe external resource", got.String())
+
+	got, ok = GetCondition(obj, "Ready")
+	require.True(t, ok)
+	require.Equal(t, "Ready=<none>", got.String())
+
+	_, ok = GetCondition(&unstructured.Unstructured{}, "Ready")
+	require.False(t, ok)
+}
@@ -0,0 +1,114 @@
+package xpenvfuncs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/klient/k8s/resources"
+	"sigs.k8s.io/e2e-framework/pkg/env"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/vendored"
+)
+
+var imageConfigSchema = schema.GroupVersionResource{Group: "pkg.crossplane.io", Version: "v1beta1", Resource: "imageconfigs"}
+
+// RewriteImages returns an ImageConfig, which rewrites the prefix of matching package images, e.g. to pull from a mirror
+func RewriteImages(name string, prefix string, rewritePrefix string) vendored.ImageConfig {
+	return vendored.ImageConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: vendored.ImageConfigSpec{
+			MatchImages:  []vendored.ImageMatch{{Type: vendored.Prefix, Prefix: prefix}},
+			RewriteImage: &vendored.ImageRewrite{Prefix: rewritePrefix},
+		},
+	}
+}
+
+// PullSecret returns an ImageConfig, which pulls matching package images with the given secret of the crossplane namespace
+func PullSecret(name string, prefix string, secretName string) vendored.ImageConfig {
+	return vendored.ImageConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: vendored.ImageConfigSpec{
+			MatchImages: []vendored.ImageMatch{{Type: vendored.Prefix, Prefix: prefix}},
+			Registry: &vendored.RegistryConfig{Authentication: &vendored.RegistryAuthentication{
+				PullSecretRef: corev1.LocalObjectReference{Name: secretName},
+			}},
+		},
+	}
+}
+
+// DockerConfigSecret returns a pull secret in the crossplane namespace with the credentials for the registry
+func DockerConfigSecret(name string, registry string, username string, password string) (*corev1.Secret, error) {
+	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	config, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			registry: map[string]string{"username": username, "password": password, "auth": auth},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: CrossplaneNamespace},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: config},
+	}, nil
+}
+
+// ApplyImageConfigs returns an env.Func, which creates the pull secrets and image configs or updates existing ones.
+// It needs to run after crossplane is installed and before packages are installed, which should be affected.
+func ApplyImageConfigs(secrets []*corev1.Secret, configs ...vendored.ImageConfig) env.Func {
+	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+		if len(secrets) == 0 && len(configs) == 0 {
+			return ctx, nil
+		}
+		r, err := resources.New(cfg.Client().RESTConfig())
+		if err != nil {
+			return ctx, err
+		}
+		for _, secret := range secrets {
+			if err := r.Create(ctx, secret.DeepCopy()); err != nil && !apierrors.IsAlreadyExists(err) {
+				return ctx, err
+			}
+		}
+		cl, err := dynamic.NewForConfig(cfg.Client().RESTConfig())
+		if err != nil {
+			return ctx, err
+		}
+		res := cl.Resource(imageConfigSchema)
+		for i := range configs {
+			klog.V(4).Infof("Applying ImageConfig %s", configs[i].Name)
+			config := configs[i].DeepCopy()
+			config.TypeMeta.Kind = "ImageConfig"
+			config.TypeMeta.APIVersion = imageConfigSchema.GroupVersion().Identifier()
+			data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(config)
+			if err != nil {
+				return ctx, err
+			}
+			unstruc := unstructured.Unstructured{Object: data}
+			_, err = res.Create(ctx, &unstruc, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				existing, getErr := res.Get(ctx, unstruc.GetName(), metav1.GetOptions{})
+				if getErr != nil {
+					return ctx, getErr
+				}
+				unstruc.SetResourceVersion(existing.GetResourceVersion())
+				_, err = res.Update(ctx, &unstruc, metav1.UpdateOptions{})
+			}
+			if err != nil {
+				return ctx, fmt.Errorf("failed to apply ImageConfig %s: %w", unstruc.GetName(), err)
+			}
+		}
+		return ctx, nil
+	}
+}
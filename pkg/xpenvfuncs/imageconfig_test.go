@@ -0,0 +1,45 @@
+package xpenvfuncs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/vendored"
+)
+
+func TestRewriteImages(t *testing.T) {
+	config := RewriteImages("mirror", "xpkg.upbound.io/", "registry.example.com/mirror/")
+	require.Equal(t, "mirror", config.Name)
+	require.Equal(t, []vendored.ImageMatch{{Type: vendored.Prefix, Prefix: "xpkg.upbound.io/"}}, config.Spec.MatchImages)
+	require.Equal(t, "registry.example.com/mirror/", config.Spec.RewriteImage.Prefix)
+	require.Nil(t, config.Spec.Registry)
+}
+
+func TestPullSecret(t *testing.T) {
+	config := PullSecret("private", "registry.example.com/", "pull-secret")
+	require.Equal(t, "private", config.Name)
+	require.Equal(t, "registry.example.com/", config.Spec.MatchImages[0].Prefix)
+	require.Equal(t, "pull-secret", config.Spec.Registry.Authentication.PullSecretRef.Name)
+	require.Nil(t, config.Spec.RewriteImage)
+}
+
+func TestDockerConfigSecret(t *testing.T) {
+	secret, err := DockerConfigSecret("pull-secret", "registry.example.com", "user", "pass")
+	require.NoError(t, err)
+	require.Equal(t, "pull-secret", secret.Name)
+	require.Equal(t, CrossplaneNamespace, secret.Namespace)
+	require.Equal(t, corev1.SecretTypeDockerConfigJson, secret.Type)
+	require.JSONEq(t,
+		`{"auths":{"registry.example.com":{"username":"user","password":"pass","auth":"dXNlcjpwYXNz"}}}`,
+		string(secret.Data[corev1.DockerConfigJsonKey]))
+}
+
+func TestApplyImageConfigs_nothingToApply(t *testing.T) {
+	// without secrets and configs the cluster must not be contacted
+	_, err := ApplyImageConfigs(nil)(context.Background(), envconf.New())
+	require.NoError(t, err)
+}
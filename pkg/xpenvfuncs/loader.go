@@ -0,0 +1,179 @@
+package xpenvfuncs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/pkg/env"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/envfuncs"
+
+	"github.com/crossplane-contrib/xp-testing/internal/docker"
+)
+
+const (
+	// PackageCacheMount is the directory of the package cache on the node, which backs the PVC of NodeCopyLoader
+	PackageCacheMount = "/cache/xpkg"
+
+	packageCacheName = "package-cache"
+)
+
+// PackageLoader makes locally built provider packages and controller images available to the cluster.
+// The strategy depends on the cluster backend, e.g. copying into the node container or pushing to a registry.
+type PackageLoader interface {
+	// PackageCache returns the name of the PVC backing the package cache of crossplane, empty for crossplane's default cache
+	PackageCache() string
+	// SetupPackageCache prepares the PVC returned by PackageCache
+	SetupPackageCache(ctx context.Context, cfg *envconf.Config, clusterName string) error
+	// LoadPackage makes the package available and returns the reference and pull policy the provider is installed with
+	LoadPackage(ctx context.Context, cfg *envconf.Config, clusterName string, pkg string) (string, corev1.PullPolicy, error)
+	// LoadImage makes the image available to the cluster
+	LoadImage(ctx context.Context, cfg *envconf.Config, clusterName string, image string) error
+}
+
+type packageLoaderKey struct{}
+
+// WithPackageLoader returns an env.Func, which configures the PackageLoader used by
+// InstallCrossplane, UpgradeCrossplane and InstallCrossplaneProvider, the default is KindPackageLoader
+func WithPackageLoader(loader PackageLoader) env.Func {
+	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+		return context.WithValue(ctx, packageLoaderKey{}, loader), nil
+	}
+}
+
+// PackageLoaderFromContext returns the PackageLoader configured by WithPackageLoader or KindPackageLoader
+func PackageLoaderFromContext(ctx context.Context) PackageLoader {
+	if loader, ok := ctx.Value(packageLoaderKey{}).(PackageLoader); ok {
+		return loader
+	}
+	return KindPackageLoader()
+}
+
+// NodeCopyLoader copies packages into the package cache on a node container of the cluster,
+// the provider is installed with pull policy Never
+type NodeCopyLoader struct {
+	// Node returns the name of the docker container of the node hosting the package cache
+	Node func(clusterName string) string
+}
+
+// KindPackageLoader copies packages into the control plane node of a kind cluster
+func KindPackageLoader() NodeCopyLoader {
+	return NodeCopyLoader{Node: getClusterControlPlaneName}
+}
+
+// K3dPackageLoader copies packages into the first server node of a k3d cluster
+func K3dPackageLoader() NodeCopyLoader {
+	return NodeCopyLoader{Node: func(clusterName string) string {
+		return fmt.Sprintf("k3d-%s-server-0", clusterName)
+	}}
+}
+
+// PackageCache returns the name of the PVC backed by the node's /cache/xpkg
+func (l NodeCopyLoader) PackageCache() string {
+	return packageCacheName
+}
+
+// SetupPackageCache creates the cache directory on the node and the PV and PVC backed by it
+func (l NodeCopyLoader) SetupPackageCache(ctx context.Context, cfg *envconf.Config, clusterName string) error {
+	_, err := setupCrossplanePackageCache(l.Node(clusterName), packageCacheName)(ctx, cfg)
+	return err
+}
+
+// LoadPackage copies the package from the local docker daemon into the package cache on the node
+func (l NodeCopyLoader) LoadPackage(ctx context.Context, _ *envconf.Config, clusterName string, pkg string) (string, corev1.PullPolicy, error) {
+	if err := loadCrossplanePackageToNode(ctx, l.Node(clusterName), pkg); err != nil {
+		return "", "", err
+	}
+	return pkg, corev1.PullNever, nil
+}
+
+// LoadImage loads the image with the cluster provider native workflow, e.g. kind load docker-image
+func (l NodeCopyLoader) LoadImage(ctx context.Context, cfg *envconf.Config, clusterName string, image string) error {
+	_, err := envfuncs.LoadImageToCluster(clusterName, image)(ctx, cfg)
+	return err
+}
+
+// RegistryLoader pushes packages and images to a registry the cluster pulls from,
+// for backends without access to the nodes, e.g. an existing cluster from a kubeconfig or a vcluster.
+type RegistryLoader struct {
+	// Registry is the host, and optionally a path, the packages are pushed to, e.g. localhost:5000.
+	// If empty, the packages aren't pushed and need to be published already.
+	Registry string
+}
+
+// RegistryPackageLoader returns a RegistryLoader pushing to the given registry
+func RegistryPackageLoader(registry string) RegistryLoader {
+	return RegistryLoader{Registry: registry}
+}
+
+// PackageCache returns an empty name, crossplane uses its default cache
+func (l RegistryLoader) PackageCache() string {
+	return ""
+}
+
+// SetupPackageCache is a no-op
+func (l RegistryLoader) SetupPackageCache(context.Context, *envconf.Config, string) error {
+	return nil
+}
+
+// LoadPackage pushes the package to the registry and returns its reference in the registry
+func (l RegistryLoader) LoadPackage(_ context.Context, _ *envconf.Config, _ string, pkg string) (string, corev1.PullPolicy, error) {
+	ref, err := l.push(pkg)
+	if err != nil {
+		return "", "", err
+	}
+	return ref, corev1.PullIfNotPresent, nil
+}
+
+// LoadImage pushes the image to the registry, the package has to reference the image in the registry
+func (l RegistryLoader) LoadImage(_ context.Context, _ *envconf.Config, _ string, image string) error {
+	_, err := l.push(image)
+	return err
+}
+
+// push tags the image with the registry and pushes it, the repository and tag of the image are kept
+func (l RegistryLoader) push(image string) (string, error) {
+	if l.Registry == "" {
+		return image, nil
+	}
+	target, err := registryReference(l.Registry, image)
+	if err != nil {
+		return "", err
+	}
+	klog.V(4).Infof("Pushing %s to %s", image, target)
+	if err := docker.Tag(image, target); err != nil {
+		return "", err
+	}
+	if err := docker.Push(target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// registryReference returns the reference of the image in the given registry
+func registryReference(registry string, image string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	tag := ref.Identifier()
+	if digest, ok := ref.(name.Digest); ok {
+		// docker can't tag by digest
+		tag = strings.Replace(digest.DigestStr(), ":", "-", 1)
+	}
+	return fmt.Sprintf("%s/%s:%s", registry, repositoryOf(ref), tag), nil
+}
+
+// repositoryOf returns the repository of the reference without registry
+func repositoryOf(ref name.Reference) string {
+	repository := ref.Context().RepositoryStr()
+	if ref.Context().RegistryStr() == name.DefaultRegistry {
+		// local images like provider-nop:latest are parsed as docker hub library images
+		repository = strings.TrimPrefix(repository, "library/")
+	}
+	return repository
+}
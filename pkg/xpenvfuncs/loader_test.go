@@ -0,0 +1,55 @@
+package xpenvfuncs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPackageLoaderFromContext(t *testing.T) {
+	loader := PackageLoaderFromContext(context.Background())
+	require.Equal(t, packageCacheName, loader.PackageCache())
+	require.Equal(t, "my-cluster-control-plane", loader.(NodeCopyLoader).Node("my-cluster"))
+
+	ctx, err := WithPackageLoader(RegistryPackageLoader("localhost:5000"))(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, RegistryLoader{Registry: "localhost:5000"}, PackageLoaderFromContext(ctx))
+}
+
+func TestK3dPackageLoader(t *testing.T) {
+	require.Equal(t, "k3d-my-cluster-server-0", K3dPackageLoader().Node("my-cluster"))
+}
+
+func TestRegistryLoader_withoutRegistry(t *testing.T) {
+	loader := RegistryPackageLoader("")
+	require.Empty(t, loader.PackageCache())
+
+	ref, pullPolicy, err := loader.LoadPackage(context.Background(), nil, "cluster", "xpkg.upbound.io/org/provider:v1.0.0")
+	require.NoError(t, err)
+	require.Equal(t, "xpkg.upbound.io/org/provider:v1.0.0", ref)
+	require.Equal(t, corev1.PullIfNotPresent, pullPolicy)
+}
+
+func Test_registryReference(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{image: "provider-nop:latest", expected: "localhost:5000/provider-nop:latest"},
+		{image: "xpkg.upbound.io/org/provider:v1.0.0", expected: "localhost:5000/org/provider:v1.0.0"},
+		{image: "org/provider", expected: "localhost:5000/org/provider:latest"},
+		{
+			image:    "org/provider@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			expected: "localhost:5000/org/provider:sha256-0000000000000000000000000000000000000000000000000000000000000000",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			ref, err := registryReference("localhost:5000", test.image)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, ref)
+		})
+	}
+}
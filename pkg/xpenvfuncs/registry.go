@@ -0,0 +1,429 @@
+package xpenvfuncs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/daemon"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/e2e-framework/pkg/env"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+
+	"github.com/crossplane-contrib/xp-testing/internal/docker"
+	"github.com/crossplane-contrib/xp-testing/pkg/vendored"
+)
+
+const (
+	kindNetwork          = "kind"
+	kindClusterLabel     = "io.x-k8s.kind.cluster"
+	registryPort         = 5000
+	containerdHostsDir   = "/etc/containerd/certs.d"
+	localRegistryHosting = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: local-registry-hosting
+  namespace: kube-public
+data:
+  localRegistryHosting.v1: |
+    host: "localhost:{{.HostPort}}"
+    hostFromClusterNetwork: "{{.Host}}"
+    help: "https://github.com/crossplane-contrib/xp-testing"
+`
+	containerdHostsTemplate = `server = "http://%[1]s"
+
+[host."http://%[1]s"]
+  capabilities = ["pull", "resolve"]
+`
+	// containerdMirrorTemplate makes containerd pull the images of a registry from the local registry first
+	containerdMirrorTemplate = `server = "%[1]s"
+
+[host."http://%[2]s"]
+  capabilities = ["pull", "resolve"]
+`
+	dockerHub       = "docker.io"
+	dockerHubServer = "https://registry-1.docker.io"
+)
+
+// LocalRegistry is an OCI registry container attached to the kind network, which packages are pushed to and pulled from.
+// Unlike the NodeCopyLoader, it supports package dependencies, Function packages and image config resolution,
+// since crossplane pulls the packages by digest like from any other registry. Only kind clusters are supported.
+//
+// Crossplane and containerd access the registry by its IP on the kind network, which crossplane treats as insecure registry,
+// containerd is configured for it with a hosts.toml in /etc/containerd/certs.d, the default config path of kind.
+//
+// Images are pushed to the same repository in the registry, e.g. xpkg.upbound.io/crossplane-contrib/provider-nop:v0.2.0
+// to <Host>/crossplane-contrib/provider-nop. A controller image loaded with LoadImage keeps its name, containerd of the
+// nodes pulls its registry through the local registry. Packages referencing other images, like dependencies, still pull
+// them from their original registry, unless it is listed in Mirror.
+type LocalRegistry struct {
+	// Name of the registry container, defaults to xp-testing-registry
+	Name string
+	// HostPort the registry is published on at localhost, defaults to 5001
+	HostPort int
+	// Image of the registry, defaults to registry:2
+	Image string
+	// Mirror lists registries, e.g. xpkg.upbound.io, whose package images crossplane pulls from the local registry instead,
+	// see ImageConfigs. The images, e.g. dependencies, need to be pushed with Push first. Requires crossplane v2.
+	Mirror []string
+	// Remove the registry container when the environment finishes, see Remove
+	Remove bool
+
+	// mu serialises starting and removing the registry, the environments of a Matrix or Pool share it
+	mu   sync.Mutex
+	host string
+}
+
+var _ PackageLoader = &LocalRegistry{}
+
+var nonNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+func (r *LocalRegistry) name() string {
+	if r.Name == "" {
+		return "xp-testing-registry"
+	}
+	return r.Name
+}
+
+func (r *LocalRegistry) hostPort() int {
+	if r.HostPort == 0 {
+		return 5001
+	}
+	return r.HostPort
+}
+
+func (r *LocalRegistry) image() string {
+	if r.Image == "" {
+		return "registry:2"
+	}
+	return r.Image
+}
+
+// Host returns the address of the registry in the cluster, available after Start
+func (r *LocalRegistry) Host() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.host
+}
+
+// Start returns an env.Func, which starts the registry container, unless it is running already, attaches it to the
+// kind network and configures containerd of all nodes of the given cluster to pull from it.
+// Environments set up concurrently can share the registry, it is only started once.
+func (r *LocalRegistry) Start(clusterName string) env.Func {
+	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+		cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+		if err != nil {
+			return ctx, err
+		}
+		defer func() {
+			_ = cli.Close()
+		}()
+		host, err := r.ensureRunning(func() (string, error) {
+			return r.startContainer(ctx, cli)
+		})
+		if err != nil {
+			return ctx, err
+		}
+
+		nodes, err := kindNodes(ctx, cli, clusterName)
+		if err != nil {
+			return ctx, err
+		}
+		for _, node := range nodes {
+			if err := writeHostsTOML(node, host, fmt.Sprintf(containerdHostsTemplate, host)); err != nil {
+				return ctx, err
+			}
+		}
+
+		rendered, err := renderTemplate(localRegistryHosting, struct {
+			HostPort int
+			Host     string
+		}{HostPort: r.hostPort(), Host: host})
+		if err != nil {
+			return ctx, err
+		}
+		return applyResources(ctx, cfg, rendered)
+	}
+}
+
+// ensureRunning returns the address of the registry, start is only called if the registry isn't started yet
+func (r *LocalRegistry) ensureRunning(start func() (string, error)) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.host != "" {
+		return r.host, nil
+	}
+	host, err := start()
+	if err != nil {
+		return "", err
+	}
+	r.host = host
+	klog.V(4).Infof("Local registry %s available at localhost:%d and %s", r.name(), r.hostPort(), r.host)
+	return r.host, nil
+}
+
+// startContainer ensures the registry container is running and returns its address in the kind network
+func (r *LocalRegistry) startContainer(ctx context.Context, cli *client.Client) (string, error) {
+	if err := r.ensureContainer(ctx, cli); err != nil {
+		return "", err
+	}
+	inspect, err := cli.ContainerInspect(ctx, r.name())
+	if err != nil {
+		return "", err
+	}
+	endpoint, ok := inspect.NetworkSettings.Networks[kindNetwork]
+	if !ok || endpoint.IPAddress == "" {
+		return "", fmt.Errorf("registry %s has no address in network %s", r.name(), kindNetwork)
+	}
+	return fmt.Sprintf("%s:%d", endpoint.IPAddress, registryPort), nil
+}
+
+// ensureContainer creates and starts the registry container and connects it to the kind network, if required
+func (r *LocalRegistry) ensureContainer(ctx context.Context, cli *client.Client) error {
+	inspect, err := cli.ContainerInspect(ctx, r.name())
+	if client.IsErrNotFound(err) {
+		klog.V(4).Infof("Creating local registry %s", r.name())
+		pull, err := cli.ImagePull(ctx, r.image(), image.PullOptions{})
+		if err != nil {
+			return err
+		}
+		_, _ = io.Copy(io.Discard, pull)
+		_ = pull.Close()
+		port := nat.Port(fmt.Sprintf("%d/tcp", registryPort))
+		_, err = cli.ContainerCreate(ctx,
+			&container.Config{Image: r.image(), ExposedPorts: nat.PortSet{port: struct{}{}}},
+			&container.HostConfig{
+				RestartPolicy: container.RestartPolicy{Name: container.RestartPolicyAlways},
+				PortBindings:  nat.PortMap{port: []nat.PortBinding{{HostIP: "127.0.0.1", HostPort: fmt.Sprint(r.hostPort())}}},
+			},
+			&network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{kindNetwork: {}}},
+			nil, r.name())
+		if err != nil {
+			return err
+		}
+		return cli.ContainerStart(ctx, r.name(), container.StartOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := inspect.NetworkSettings.Networks[kindNetwork]; !ok {
+		if err := cli.NetworkConnect(ctx, kindNetwork, r.name(), nil); err != nil {
+			return err
+		}
+	}
+	if !inspect.State.Running {
+		return cli.ContainerStart(ctx, r.name(), container.StartOptions{})
+	}
+	return nil
+}
+
+// ImageConfigs returns an env.Func, which applies an ImageConfig per Mirror rewriting its package images to the registry.
+// It needs to run after crossplane is installed and the registry is started.
+func (r *LocalRegistry) ImageConfigs() env.Func {
+	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+		if len(r.Mirror) == 0 {
+			return ctx, nil
+		}
+		host := r.Host()
+		if host == "" {
+			return ctx, fmt.Errorf("local registry %s is not started", r.name())
+		}
+		return ApplyImageConfigs(nil, r.imageConfigs(host)...)(ctx, cfg)
+	}
+}
+
+func (r *LocalRegistry) imageConfigs(host string) []vendored.ImageConfig {
+	configs := make([]vendored.ImageConfig, 0, len(r.Mirror))
+	for _, registry := range r.Mirror {
+		registry = strings.TrimSuffix(registry, "/")
+		name := fmt.Sprintf("%s-%s", r.name(), nonNameChars.ReplaceAllString(registry, "-"))
+		configs = append(configs, RewriteImages(name, registry+"/", host+"/"))
+	}
+	return configs
+}
+
+// Teardown returns an env.Func, which removes the registry container, if Remove is set
+func (r *LocalRegistry) Teardown() env.Func {
+	return func(ctx context.Context, _ *envconf.Config) (context.Context, error) {
+		if !r.Remove {
+			return ctx, nil
+		}
+		cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+		if err != nil {
+			return ctx, err
+		}
+		defer func() {
+			_ = cli.Close()
+		}()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		klog.V(4).Infof("Removing local registry %s", r.name())
+		err = cli.ContainerRemove(ctx, r.name(), container.RemoveOptions{Force: true})
+		if err != nil && !client.IsErrNotFound(err) {
+			return ctx, err
+		}
+		r.host = ""
+		return ctx, nil
+	}
+}
+
+// kindNodes returns the names of the node containers of the kind cluster
+func kindNodes(ctx context.Context, cli *client.Client, clusterName string) ([]string, error) {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{Filters: filters.NewArgs(filters.Arg("label", kindClusterLabel+"="+clusterName))})
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("no nodes of kind cluster %s found, the local registry supports kind clusters only", clusterName)
+	}
+	nodes := make([]string, 0, len(containers))
+	for _, node := range containers {
+		nodes = append(nodes, strings.TrimPrefix(node.Names[0], "/"))
+	}
+	return nodes, nil
+}
+
+// mirrorHostsTOML returns the directory in the containerd config path and the hosts.toml, which make containerd pull
+// the images of the registry of the reference from the local registry at host, falling back to the registry itself
+func mirrorHostsTOML(ref name.Reference, host string) (string, string) {
+	registry := ref.Context().RegistryStr()
+	server := "https://" + registry
+	if registry == name.DefaultRegistry {
+		// containerd names docker hub docker.io
+		registry, server = dockerHub, dockerHubServer
+	}
+	return registry, fmt.Sprintf(containerdMirrorTemplate, server, host)
+}
+
+// writeHostsTOML writes the containerd hosts.toml of the registry to the node
+func writeHostsTOML(node string, registry string, content string) error {
+	dir := filepath.Join(containerdHostsDir, registry)
+	f, err := os.CreateTemp("", "hosts.toml")
+	if err != nil {
+		return err
+	}
+	defer func(name string) {
+		_ = os.Remove(name)
+	}(f.Name())
+	if _, err := f.WriteString(content); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := docker.Exec(node, "mkdir", "-p", dir); err != nil {
+		return err
+	}
+	return docker.Cp(f.Name(), fmt.Sprintf("%s:%s", node, filepath.Join(dir, "hosts.toml")))
+}
+
+// Push pushes the image from the local docker daemon to the registry and returns its reference by digest in the cluster,
+// e.g. to push dependencies or Function packages
+func (r *LocalRegistry) Push(ctx context.Context, img string) (string, error) {
+	host := r.Host()
+	if host == "" {
+		return "", fmt.Errorf("local registry %s is not started", r.name())
+	}
+	src, err := name.ParseReference(img)
+	if err != nil {
+		return "", err
+	}
+	digest, err := r.push(ctx, src, repositoryOf(src))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s@%s", host, repositoryOf(src), digest), nil
+}
+
+// push pushes the image from the local docker daemon to the repository of the registry and returns its digest
+func (r *LocalRegistry) push(ctx context.Context, src name.Reference, repository string) (string, error) {
+	local, err := daemon.Image(src, daemon.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	tag := src.Identifier()
+	if digest, ok := src.(name.Digest); ok {
+		tag = strings.Replace(digest.DigestStr(), ":", "-", 1)
+	}
+	dst, err := name.ParseReference(fmt.Sprintf("localhost:%d/%s:%s", r.hostPort(), repository, tag), name.Insecure)
+	if err != nil {
+		return "", err
+	}
+	klog.V(4).Infof("Pushing %s to %s", src, dst)
+	if err := remote.Write(dst, local, remote.WithContext(ctx)); err != nil {
+		return "", err
+	}
+	digest, err := local.Digest()
+	if err != nil {
+		return "", err
+	}
+	return digest.String(), nil
+}
+
+// PackageCache returns an empty name, crossplane uses its default cache
+func (r *LocalRegistry) PackageCache() string {
+	return ""
+}
+
+// SetupPackageCache is a no-op
+func (r *LocalRegistry) SetupPackageCache(context.Context, *envconf.Config, string) error {
+	return nil
+}
+
+// LoadPackage pushes the package to the registry and returns its reference by digest
+func (r *LocalRegistry) LoadPackage(ctx context.Context, _ *envconf.Config, _ string, pkg string) (string, corev1.PullPolicy, error) {
+	ref, err := r.Push(ctx, pkg)
+	if err != nil {
+		return "", "", err
+	}
+	return ref, corev1.PullIfNotPresent, nil
+}
+
+// LoadImage pushes the image to its repository in the registry, including the registry path containerd requests
+// it by, e.g. library/ for docker hub, and configures containerd of the nodes to pull the images of its registry from the
+// local registry first, so the provider can reference the image by its original name
+func (r *LocalRegistry) LoadImage(ctx context.Context, _ *envconf.Config, clusterName string, img string) error {
+	host := r.Host()
+	if host == "" {
+		return fmt.Errorf("local registry %s is not started", r.name())
+	}
+	src, err := name.ParseReference(img)
+	if err != nil {
+		return err
+	}
+	if _, err := r.push(ctx, src, src.Context().RepositoryStr()); err != nil {
+		return err
+	}
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Close()
+	}()
+	nodes, err := kindNodes(ctx, cli, clusterName)
+	if err != nil {
+		return err
+	}
+	registry, hostsTOML := mirrorHostsTOML(src, host)
+	for _, node := range nodes {
+		if err := writeHostsTOML(node, registry, hostsTOML); err != nil {
+			return err
+		}
+	}
+	return nil
+}
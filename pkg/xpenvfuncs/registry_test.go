@@ -0,0 +1,109 @@
+package xpenvfuncs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalRegistry_defaults(t *testing.T) {
+	r := &LocalRegistry{}
+	require.Equal(t, "xp-testing-registry", r.name())
+	require.Equal(t, 5001, r.hostPort())
+	require.Equal(t, "registry:2", r.image())
+	require.Empty(t, r.PackageCache())
+
+	r = &LocalRegistry{Name: "registry", HostPort: 5555, Image: "registry:3"}
+	require.Equal(t, "registry", r.name())
+	require.Equal(t, 5555, r.hostPort())
+	require.Equal(t, "registry:3", r.image())
+}
+
+func TestLocalRegistry_Push_notStarted(t *testing.T) {
+	_, err := (&LocalRegistry{}).Push(context.Background(), "provider-nop:latest")
+	require.EqualError(t, err, "local registry xp-testing-registry is not started")
+}
+
+func TestLocalRegistry_ensureRunning(t *testing.T) {
+	r := &LocalRegistry{}
+	var starts atomic.Int32
+	hosts := make([]string, 8)
+	errs := make([]error, len(hosts))
+	var wg sync.WaitGroup
+	for i := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hosts[i], errs[i] = r.ensureRunning(func() (string, error) {
+				starts.Add(1)
+				return "172.18.0.5:5000", nil
+			})
+			_ = r.Host()
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, errors.Join(errs...))
+	require.Equal(t, int32(1), starts.Load(), "the registry is started once by concurrent environments")
+	for _, host := range hosts {
+		require.Equal(t, "172.18.0.5:5000", host)
+	}
+
+	failing := &LocalRegistry{}
+	_, err := failing.ensureRunning(func() (string, error) { return "", errors.New("conflict") })
+	require.EqualError(t, err, "conflict")
+	require.Empty(t, failing.Host())
+}
+
+func Test_containerdHostsTemplate(t *testing.T) {
+	require.Equal(t, `server = "http://172.18.0.5:5000"
+
+[host."http://172.18.0.5:5000"]
+  capabilities = ["pull", "resolve"]
+`, fmt.Sprintf(containerdHostsTemplate, "172.18.0.5:5000"))
+}
+
+func Test_mirrorHostsTOML(t *testing.T) {
+	registry, hostsTOML := mirrorHostsTOML(name.MustParseReference("ghcr.io/crossplane-contrib/provider-nop-controller:v0.2.0"), "172.18.0.5:5000")
+	require.Equal(t, "ghcr.io", registry)
+	require.Equal(t, `server = "https://ghcr.io"
+
+[host."http://172.18.0.5:5000"]
+  capabilities = ["pull", "resolve"]
+`, hostsTOML)
+
+	registry, hostsTOML = mirrorHostsTOML(name.MustParseReference("provider-nop-controller:latest"), "172.18.0.5:5000")
+	require.Equal(t, "docker.io", registry)
+	require.Contains(t, hostsTOML, `server = "https://registry-1.docker.io"`)
+}
+
+func TestLocalRegistry_LoadImage_notStarted(t *testing.T) {
+	err := (&LocalRegistry{}).LoadImage(context.Background(), nil, "test", "provider-nop-controller:latest")
+	require.EqualError(t, err, "local registry xp-testing-registry is not started")
+}
+
+func Test_localRegistryHosting(t *testing.T) {
+	rendered, err := renderTemplate(localRegistryHosting, struct {
+		HostPort int
+		Host     string
+	}{HostPort: 5001, Host: "172.18.0.5:5000"})
+	require.NoError(t, err)
+	require.Contains(t, rendered, `host: "localhost:5001"`)
+	require.Contains(t, rendered, `hostFromClusterNetwork: "172.18.0.5:5000"`)
+}
+
+func TestLocalRegistry_imageConfigs(t *testing.T) {
+	r := &LocalRegistry{Mirror: []string{"xpkg.upbound.io", "ghcr.io/"}}
+	configs := r.imageConfigs("172.18.0.5:5000")
+	require.Len(t, configs, 2)
+	require.Equal(t, RewriteImages("xp-testing-registry-xpkg-upbound-io", "xpkg.upbound.io/", "172.18.0.5:5000/"), configs[0])
+	require.Equal(t, RewriteImages("xp-testing-registry-ghcr-io", "ghcr.io/", "172.18.0.5:5000/"), configs[1])
+
+	_, err := (&LocalRegistry{Mirror: []string{"xpkg.upbound.io"}}).ImageConfigs()(context.Background(), nil)
+	require.EqualError(t, err, "local registry xp-testing-registry is not started")
+}
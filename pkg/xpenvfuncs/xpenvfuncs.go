@@ -78,7 +78,7 @@ metadata:
   name: {{.Name}}
 spec:
   package: {{.Package}}
-  packagePullPolicy: Never
+  packagePullPolicy: {{.PullPolicy}}
   {{- if .ControllerConfig }}
   controllerConfigRef:
     name: {{.ControllerConfig}}
@@ -175,12 +175,13 @@ func InstallCrossplaneFromRepo(clusterName string, chartRepoURL string, opts ...
 // default https://charts.crossplane.io/stable repository URL passed to
 // `helm repo add`.
 func installCrossplaneCore(clusterName string, chartRef string, chartRepoURL string, opts ...CrossplaneOpt) env.Func {
-	cacheName := "package-cache"
-
 	return Compose(
 		envfuncs.CreateNamespace(CrossplaneNamespace),
-		setupCrossplanePackageCache(clusterName, cacheName),
 		func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+			return ctx, PackageLoaderFromContext(ctx).SetupPackageCache(ctx, cfg, clusterName)
+		},
+		func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+			cacheName := PackageLoaderFromContext(ctx).PackageCache()
 			kindCluster, ok := envfuncs.GetClusterFromContext(ctx, clusterName)
 			if !ok {
 				return ctx, fmt.Errorf("install crossplane func: cluster '%s' doesn't exist", clusterName)
@@ -188,22 +189,8 @@ func installCrossplaneCore(clusterName string, chartRef string, chartRepoURL str
 
 			manager := helm.New(kindCluster.GetKubeconfig())
 
-			if chartRef == "" {
-				// Pull the chart from a helm repository — defaulting to the
-				// upstream Crossplane stable chart repo unless overridden.
-				if err := manager.RunRepo(
-					helm.WithArgs(
-						"add",
-						helmRepoName,
-						resolveCrossplaneChartRepoURL(chartRepoURL),
-						"--force-update",
-					),
-				); err != nil {
-					return ctx, errors.Wrap(err, "install crossplane func: failed to add crossplane helm chart repo")
-				}
-				if err := manager.RunRepo(helm.WithArgs("update")); err != nil {
-					return ctx, errors.Wrap(err, "install crossplane func: failed to upgrade helm repo")
-				}
+			if err := addCrossplaneHelmRepo(manager, chartRef, chartRepoURL); err != nil {
+				return ctx, errors.Wrap(err, "install crossplane func")
 			}
 
 			if err := manager.RunInstall(buildCrossplaneHelmInstallOpts(chartRef, cacheName, opts)...); err != nil {
@@ -215,6 +202,75 @@ func installCrossplaneCore(clusterName string, chartRef string, chartRepoURL str
 	)
 }
 
+// UpgradeCrossplane returns an env.Func that upgrades the crossplane installation of the given cluster
+// via `helm upgrade`. The chart is pulled from the upstream Crossplane stable chart repository.
+// The release is installed if it doesn't exist yet, which allows using this func to pin a specific
+// crossplane version before an upgrade test.
+func UpgradeCrossplane(clusterName string, opts ...CrossplaneOpt) env.Func {
+	return upgradeCrossplaneCore(clusterName, "", "", opts...)
+}
+
+// UpgradeCrossplaneFromChart returns an env.Func that upgrades crossplane from a caller-supplied chart reference.
+// See InstallCrossplaneFromChart for the accepted chart references.
+func UpgradeCrossplaneFromChart(clusterName string, chartRef string, opts ...CrossplaneOpt) env.Func {
+	return upgradeCrossplaneCore(clusterName, chartRef, "", opts...)
+}
+
+// UpgradeCrossplaneFromRepo returns an env.Func that upgrades crossplane from a custom helm chart repository URL.
+func UpgradeCrossplaneFromRepo(clusterName string, chartRepoURL string, opts ...CrossplaneOpt) env.Func {
+	return upgradeCrossplaneCore(clusterName, "", chartRepoURL, opts...)
+}
+
+// upgradeCrossplaneCore is the shared implementation used by UpgradeCrossplane,
+// UpgradeCrossplaneFromChart and UpgradeCrossplaneFromRepo.
+// It reuses the package cache set up by installCrossplaneCore, so the upgraded
+// crossplane keeps reading side-loaded packages.
+func upgradeCrossplaneCore(clusterName string, chartRef string, chartRepoURL string, opts ...CrossplaneOpt) env.Func {
+	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+		cacheName := PackageLoaderFromContext(ctx).PackageCache()
+		kindCluster, ok := envfuncs.GetClusterFromContext(ctx, clusterName)
+		if !ok {
+			return ctx, fmt.Errorf("upgrade crossplane func: cluster '%s' doesn't exist", clusterName)
+		}
+
+		manager := helm.New(kindCluster.GetKubeconfig())
+
+		if err := addCrossplaneHelmRepo(manager, chartRef, chartRepoURL); err != nil {
+			return ctx, errors.Wrap(err, "upgrade crossplane func")
+		}
+
+		upgradeOpts := append(buildCrossplaneHelmInstallOpts(chartRef, cacheName, opts), helm.WithArgs("--install"))
+		if err := manager.RunUpgrade(upgradeOpts...); err != nil {
+			return ctx, errors.Wrap(err, "upgrade crossplane func: failed to upgrade crossplane Helm chart")
+		}
+
+		return ctx, nil
+	}
+}
+
+// addCrossplaneHelmRepo adds and updates the crossplane helm repository, unless a chartRef is given.
+func addCrossplaneHelmRepo(manager *helm.Manager, chartRef string, chartRepoURL string) error {
+	if chartRef != "" {
+		return nil
+	}
+	// Pull the chart from a helm repository — defaulting to the
+	// upstream Crossplane stable chart repo unless overridden.
+	if err := manager.RunRepo(
+		helm.WithArgs(
+			"add",
+			helmRepoName,
+			resolveCrossplaneChartRepoURL(chartRepoURL),
+			"--force-update",
+		),
+	); err != nil {
+		return errors.Wrap(err, "failed to add crossplane helm chart repo")
+	}
+	if err := manager.RunRepo(helm.WithArgs("update")); err != nil {
+		return errors.Wrap(err, "failed to upgrade helm repo")
+	}
+	return nil
+}
+
 // buildCrossplaneHelmInstallOpts constructs the helm.Option list passed to
 // `helm install`. When chartRef is non-empty the chart is installed directly
 // from that reference (file path, OCI URL, or `repo/name`). Otherwise the
@@ -234,8 +290,10 @@ func buildCrossplaneHelmInstallOpts(chartRef string, cacheName string, opts []Cr
 	} else {
 		helmInstallOpts = append(helmInstallOpts, helm.WithReleaseName(helmRepoName+"/crossplane"))
 	}
+	if cacheName != "" {
+		helmInstallOpts = append(helmInstallOpts, helm.WithArgs("--set", fmt.Sprintf("packageCache.pvc=%s", cacheName)))
+	}
 	helmInstallOpts = append(helmInstallOpts,
-		helm.WithArgs("--set", fmt.Sprintf("packageCache.pvc=%s", cacheName)),
 		helm.WithTimeout("10m"),
 		helm.WithWait(),
 	)
@@ -286,12 +344,25 @@ type InstallCrossplaneProviderOptions struct {
 }
 
 // InstallCrossplaneProvider returns an env.Func that is used to
-// install a crossplane provider into the active cluster
+// install a crossplane provider into the active cluster.
+// The package and controller image are loaded with the PackageLoader configured by WithPackageLoader.
 func InstallCrossplaneProvider(clusterName string, opts InstallCrossplaneProviderOptions) env.Func {
 	return Compose(
-		loadCrossplanePackageToCluster(clusterName, opts.Package),
-		loadCrossplaneControllerImageToCluster(clusterName, opts.ControllerImage),
-		installCrossplaneProviderEnvFunc(clusterName, opts),
+		func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+			loader := PackageLoaderFromContext(ctx)
+			ref, pullPolicy, err := loader.LoadPackage(ctx, cfg, clusterName, opts.Package)
+			if err != nil {
+				return ctx, err
+			}
+			if opts.ControllerImage != nil {
+				if err := loader.LoadImage(ctx, cfg, clusterName, *opts.ControllerImage); err != nil {
+					return ctx, err
+				}
+			}
+			loaded := opts
+			loaded.Package = ref
+			return installCrossplaneProviderEnvFunc(clusterName, loaded, pullPolicy)(ctx, cfg)
+		},
 		awaitProviderHealthy(opts.Name),
 	)
 }
@@ -315,6 +386,45 @@ func ApplyProviderConfigFromDir(dir string) env.Func {
 	}
 }
 
+// ApplyProviderConfigFromDirWithData applies the files from given folder like ApplyProviderConfigFromDir,
+// but renders them as text/template with the given data first, e.g. to inject the URL of a mock server.
+// Existing objects are updated and any failure to apply a file is returned.
+func ApplyProviderConfigFromDirWithData(dir string, data interface{}) env.Func {
+	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+		r, err := resources.New(cfg.Client().RESTConfig())
+		if err != nil {
+			return ctx, err
+		}
+		klog.Info("Apply ProviderConfig")
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return ctx, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return ctx, err
+			}
+			rendered, err := renderTemplate(string(content), data)
+			if err != nil {
+				return ctx, errors.Wrapf(err, "failed to render %s", entry.Name())
+			}
+			err = decoder.DecodeEach(
+				ctx, strings.NewReader(rendered),
+				resHelper.CreateOrUpdateHandler(r),
+				decoder.MutateNamespace(cfg.Namespace()),
+			)
+			if err != nil {
+				return ctx, errors.Wrapf(err, "failed to apply %s", entry.Name())
+			}
+		}
+		return ctx, nil
+	}
+}
+
 // LoadSchemas prepares the kubernetes client with additional schemas
 func LoadSchemas(addToSchemaFuncs ...func(s *runtime.Scheme) error) env.Func {
 	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
@@ -340,13 +450,11 @@ func LoadSchemas(addToSchemaFuncs ...func(s *runtime.Scheme) error) env.Func {
 	}
 }
 
-// setupCrossplanePackageCache prepares the crossplane package-cache in the given clusters control plane
-func setupCrossplanePackageCache(clusterName string, cacheName string) env.Func {
-	cacheMount := "/cache/xpkg"
+// setupCrossplanePackageCache prepares the crossplane package-cache on the given node container
+func setupCrossplanePackageCache(node string, cacheName string) env.Func {
+	cacheMount := PackageCacheMount
 	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
-		controlPlaneName := getClusterControlPlaneName(clusterName)
-
-		if err := docker.Exec(controlPlaneName, "mkdir", "-m", "777", "-p", cacheMount); err != nil {
+		if err := docker.Exec(node, "mkdir", "-m", "777", "-p", cacheMount); err != nil {
 			return ctx, err
 		}
 
@@ -368,52 +476,48 @@ func setupCrossplanePackageCache(clusterName string, cacheName string) env.Func
 	}
 }
 
-// loadCrossplanePackageToCluster loads the crossplane config package into the given clusters package cache folder (/cache)
-func loadCrossplanePackageToCluster(clusterName string, pkg string) env.Func {
-	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
-		f, err := os.CreateTemp("", "xpkg")
-		if err != nil {
-			return ctx, err
-		}
-		defer func(name string) {
-			_ = os.Remove(name)
-		}(f.Name())
+// loadCrossplanePackageToNode loads the crossplane config package into the package cache folder (/cache) of the given node container
+func loadCrossplanePackageToNode(ctx context.Context, node string, pkg string) error {
+	f, err := os.CreateTemp("", "xpkg")
+	if err != nil {
+		return err
+	}
+	defer func(name string) {
+		_ = os.Remove(name)
+	}(f.Name())
+
+	if err = xpkg.SavePackage(pkg, f.Name()); err != nil {
+		return err
+	}
 
-		clusterControlPlaneName := getClusterControlPlaneName(clusterName)
+	ref, err := name.ParseReference(pkg)
+	if err != nil {
+		return err
+	}
 
-		if err = xpkg.SavePackage(pkg, f.Name()); err != nil {
-			return ctx, err
-		}
+	digest, err := retrieveDigest(ctx, pkg)
+	if err != nil {
+		return err
+	}
 
-		ref, err := name.ParseReference(pkg)
-		if err != nil {
-			return ctx, err
-		}
+	cacheKeys := []string{
+		fullyQualifiedPathName(PackageCacheMount+"/", pkg, ".gz"),
+		fullyQualifiedPathName(PackageCacheMount+"/", friendlyID(parsePackageSourceFromReference(ref), digest), ".gz"),
+	}
 
-		digest, err := retrieveDigest(ctx, pkg)
-		if err != nil {
-			return ctx, err
+	for _, key := range cacheKeys {
+		if err := docker.Exec(node, "mkdir", "-m", "777", "-p", filepath.Dir(key)); err != nil {
+			return err
 		}
-
-		cacheKeys := []string{
-			fullyQualifiedPathName("/cache/xpkg/", pkg, ".gz"),
-			fullyQualifiedPathName("/cache/xpkg/", friendlyID(parsePackageSourceFromReference(ref), digest), ".gz"),
+		if err := docker.Cp(f.Name(), fmt.Sprintf("%s:%s", node, key)); err != nil {
+			return err
 		}
-
-		for _, key := range cacheKeys {
-			if err := docker.Exec(clusterControlPlaneName, "mkdir", "-m", "777", "-p", filepath.Dir(key)); err != nil {
-				return ctx, err
-			}
-			if err := docker.Cp(f.Name(), fmt.Sprintf("%s:%s", clusterControlPlaneName, key)); err != nil {
-				return ctx, err
-			}
-			if err := docker.Exec(clusterControlPlaneName, "chmod", "644", key); err != nil {
-				return ctx, err
-			}
+		if err := docker.Exec(node, "chmod", "644", key); err != nil {
+			return err
 		}
-
-		return ctx, nil
 	}
+
+	return nil
 }
 
 // (from crossplane internal/xpkg)
@@ -486,30 +590,21 @@ func truncate(str string, num int) string {
 	return t
 }
 
-// loadCrossplaneControllerImageToCluster loads the controller image into the oci cache of the given cluster
-func loadCrossplaneControllerImageToCluster(clusterName string, image *string) env.Func {
-	if image == nil {
-		// no-op
-		return func(ctx context.Context, config *envconf.Config) (context.Context, error) {
-			return ctx, nil
-		}
-	}
-	return envfuncs.LoadDockerImageToCluster(clusterName, *image)
-}
-
 // installCrossplaneProviderEnvFunc is an env.Func to install a crossplane provider into the given cluster
-func installCrossplaneProviderEnvFunc(_ string, opts InstallCrossplaneProviderOptions) env.Func {
+func installCrossplaneProviderEnvFunc(_ string, opts InstallCrossplaneProviderOptions, pullPolicy corev1.PullPolicy) env.Func {
 	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
 		klog.V(4).Infof("Installing crossplane provider %s: %s", opts.Name, opts.Package)
 
 		data := struct {
 			Name             string
 			Package          string
+			PullPolicy       corev1.PullPolicy
 			ControllerConfig string
 			RuntimeConfig    string
 		}{
-			Name:    opts.Name,
-			Package: opts.Package,
+			Name:       opts.Name,
+			Package:    opts.Package,
+			PullPolicy: pullPolicy,
 		}
 
 		if opts.ControllerConfig != nil {
@@ -558,6 +653,16 @@ func applyControllerConfig(ctx context.Context, cfg *envconf.Config, opts Instal
 	}
 	unstruc := unstructured.Unstructured{Object: data}
 	_, err = res.Create(ctx, &unstruc, metav1.CreateOptions{})
+	if err != nil && apierrors.IsAlreadyExists(err) {
+		// replace the config if it already exists, e.g. when the provider is updated in a reused cluster
+		obj, err := res.Get(ctx, unstruc.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		unstruc.SetResourceVersion(obj.GetResourceVersion())
+		_, err = res.Update(ctx, &unstruc, metav1.UpdateOptions{})
+		return err
+	}
 	return err
 }
 
@@ -598,8 +703,9 @@ func awaitProviderHealthy(name string) env.Func {
 		if err != nil {
 			return ctx, err
 		}
-		err = wait.For(
-			xpconditions.New(r).ProviderConditionMatch(
+		c := xpconditions.FromContext(ctx, r)
+		err = c.For(
+			c.ProviderConditionMatch(
 				name,
 				"Healthy",
 				corev1.ConditionTrue,
@@ -684,10 +790,10 @@ func IgnoreMatchedErr(fn env.Func, errorMatcher func(err error) bool) env.Func {
 
 }
 
-// Conditional executes a fn based on conditional
+// Conditional executes a fn based on conditional, a nil fn is skipped
 func Conditional(fn env.Func, condition bool) env.Func {
 	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
-		if condition {
+		if condition && fn != nil {
 			return fn(ctx, cfg)
 		}
 		return ctx, nil
@@ -720,8 +826,8 @@ func AwaitCRDsEstablished(ctx context.Context, cfg *envconf.Config) (context.Con
 		return ctx, err
 	}
 
-	c := xpconditions.New(client)
-	err = wait.For(
+	c := xpconditions.FromContext(ctx, client)
+	err = c.For(
 		c.ResourcesMatch(&crds, crdIsEstablished), wait.WithTimeout(time.Minute),
 	)
 	return ctx, err
@@ -753,6 +859,29 @@ func crdIsEstablished(object k8s.Object) bool {
 	return false
 }
 
+// StartConditionWatcher starts a shared, informer based xpconditions.Watcher and stores it in the context.
+// Subsequent waits of this library evaluate their conditions on changes of the watched objects
+// instead of polling the API server.
+func StartConditionWatcher(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+	r, err := resources.New(cfg.Client().RESTConfig())
+	if err != nil {
+		return ctx, err
+	}
+	w, err := xpconditions.NewWatcher(r)
+	if err != nil {
+		return ctx, err
+	}
+	return xpconditions.WithWatcher(ctx, w), nil
+}
+
+// StopConditionWatcher stops the watcher started by StartConditionWatcher, if any
+func StopConditionWatcher(ctx context.Context, _ *envconf.Config) (context.Context, error) {
+	if w, ok := xpconditions.WatcherFromContext(ctx); ok {
+		w.Stop()
+	}
+	return ctx, nil
+}
+
 // DumpLogs Dumps the logs of the cluster to `$PWD/logs` using kind export func
 func DumpLogs(clusterName string, dir string) env.Func {
 	return func(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
@@ -773,13 +902,25 @@ func DumpLogs(clusterName string, dir string) env.Func {
 	}
 }
 
-// CreateTestNamespace Creates the test namespace, name comes from kubernetes-e2e
+// CreateTestNamespace Creates the test namespace, name comes from kubernetes-e2e.
+// It does nothing if no namespace is configured or it already exists, e.g. in a reused cluster
 func CreateTestNamespace(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
-	return envfuncs.CreateNamespace(cfg.Namespace())(ctx, cfg)
+	if cfg.Namespace() == "" {
+		return ctx, nil
+	}
+	newCtx, err := envfuncs.CreateNamespace(cfg.Namespace())(ctx, cfg)
+	if apierrors.IsAlreadyExists(err) {
+		return ctx, nil
+	}
+	return newCtx, err
 }
 
-// DeleteTestNamespace Deletes the test namespace, name comes from kubernetes-e2e
+// DeleteTestNamespace Deletes the test namespace, name comes from kubernetes-e2e.
+// It does nothing if no namespace is configured
 func DeleteTestNamespace(ctx context.Context, cfg *envconf.Config) (context.Context, error) {
+	if cfg.Namespace() == "" {
+		return ctx, nil
+	}
 	return envfuncs.DeleteNamespace(cfg.Namespace())(ctx, cfg)
 }
 
@@ -795,11 +936,30 @@ func Version(version string) CrossplaneOpt {
 
 // Registry configures the registry crossplane uses by adding it to the args values
 func Registry(registry string) CrossplaneOpt {
+	return Args(fmt.Sprintf("--registry=%s", registry))
+}
+
+// EnableSignatureVerification enables the verification of package signatures configured by ImageConfigs
+func EnableSignatureVerification() CrossplaneOpt {
+	return Args("--enable-signature-verification")
+}
+
+// Args adds args to the args values of crossplane, e.g. combined with Registry and EnableSignatureVerification
+func Args(args ...string) CrossplaneOpt {
 	return func(opts *helm.Opts) {
-		opts.Args = append(opts.Args, "--set", fmt.Sprintf("args={--registry=%s}", registry))
+		for i := 0; i+1 < len(opts.Args); i++ {
+			if opts.Args[i] == "--set" && strings.HasPrefix(opts.Args[i+1], crossplaneArgsPrefix) {
+				existing := strings.TrimSuffix(strings.TrimPrefix(opts.Args[i+1], crossplaneArgsPrefix), "}")
+				opts.Args[i+1] = fmt.Sprintf("%s%s,%s}", crossplaneArgsPrefix, existing, strings.Join(args, ","))
+				return
+			}
+		}
+		opts.Args = append(opts.Args, "--set", fmt.Sprintf("%s%s}", crossplaneArgsPrefix, strings.Join(args, ",")))
 	}
 }
 
+const crossplaneArgsPrefix = "args={"
+
 // ChartRef returns a CrossplaneOpt that sets the helm chart reference passed to
 // `helm install`. It is a thin wrapper around helm.WithChart and accepts a file
 // path, OCI URL (oci://...), or `repo/name`. When threading the chart ref
@@ -3,6 +3,7 @@ package xpenvfuncs
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/crossplane-contrib/xp-testing/pkg/vendored"
@@ -319,6 +320,13 @@ func TestConditional(t *testing.T) {
 			require.NoError(t, err)
 		},
 	)
+	t.Run(
+		"skips nil", func(t *testing.T) {
+			conditionalFn := Conditional(nil, true)
+			_, err := conditionalFn(nil, nil)
+			require.NoError(t, err)
+		},
+	)
 }
 
 func TestIgnoreErr(t *testing.T) {
@@ -464,6 +472,13 @@ func TestBuildCrossplaneHelmInstallOpts(t *testing.T) {
 		require.Empty(t, got.ReleaseName, "ReleaseName must be empty for OCI installs")
 	})
 
+	t.Run("empty cache name keeps the default package cache", func(t *testing.T) {
+		got := applyHelmOpts(buildCrossplaneHelmInstallOpts("", "", nil))
+
+		require.NotContains(t, got.Args, "packageCache.pvc=")
+		require.NotContains(t, got.Args, "--set")
+	})
+
 	t.Run("caller-supplied opts override defaults and are appended last", func(t *testing.T) {
 		// Version() is a CrossplaneOpt that pushes onto helm.Opts.Version.
 		// Registry() appends to helm.Opts.Args.
@@ -477,6 +492,34 @@ func TestBuildCrossplaneHelmInstallOpts(t *testing.T) {
 		require.Contains(t, got.Args, "args={--registry=xpkg.upbound.io}")
 	})
 
+	t.Run("Args and EnableSignatureVerification set crossplane args", func(t *testing.T) {
+		got := applyHelmOpts(buildCrossplaneHelmInstallOpts("", cacheName, []CrossplaneOpt{
+			Args("--registry=xpkg.upbound.io", "--enable-signature-verification"),
+		}))
+		require.Contains(t, got.Args, "args={--registry=xpkg.upbound.io,--enable-signature-verification}")
+
+		got = applyHelmOpts(buildCrossplaneHelmInstallOpts("", cacheName, []CrossplaneOpt{
+			EnableSignatureVerification(),
+		}))
+		require.Contains(t, got.Args, "args={--enable-signature-verification}")
+	})
+
+	t.Run("crossplane args of multiple opts are combined", func(t *testing.T) {
+		got := applyHelmOpts(buildCrossplaneHelmInstallOpts("", cacheName, []CrossplaneOpt{
+			Registry("xpkg.upbound.io"),
+			EnableSignatureVerification(),
+			Args("--debug"),
+		}))
+		require.Contains(t, got.Args, "args={--registry=xpkg.upbound.io,--enable-signature-verification,--debug}")
+		count := 0
+		for _, arg := range got.Args {
+			if strings.HasPrefix(arg, "args=") {
+				count++
+			}
+		}
+		require.Equal(t, 1, count, "the last args value would win")
+	})
+
 	t.Run("ChartRef CrossplaneOpt sets the Chart field via helm.WithChart", func(t *testing.T) {
 		const chartRef = "oci://xpkg.crossplane.io/crossplane/crossplane"
 		// Pass via CrossplaneOpt rather than the chartRef parameter.
@@ -503,3 +546,22 @@ func TestInstallCrossplaneEntryPoints(t *testing.T) {
 	require.NotNil(t, InstallCrossplaneFromChart("cluster", "/tmp/c.tgz", Version("v1.16.0")))
 	require.NotNil(t, InstallCrossplaneFromRepo("cluster", "https://example.com/charts", Version("v1.16.0")))
 }
+
+func TestUpgradeCrossplaneEntryPoints(t *testing.T) {
+	// Same as for the install entry points, invoking the funcs requires a real
+	// kind cluster + helm binary, so only the wiring is checked here.
+	require.NotNil(t, UpgradeCrossplane("cluster", Version("v2.0.0")))
+	require.NotNil(t, UpgradeCrossplaneFromChart("cluster", "oci://xpkg.crossplane.io/crossplane/crossplane", Version("v2.0.0")))
+	require.NotNil(t, UpgradeCrossplaneFromRepo("cluster", "https://example.com/charts", Version("v2.0.0")))
+
+	_, err := UpgradeCrossplane("cluster")(context.Background(), &envconf.Config{})
+	require.EqualError(t, err, "upgrade crossplane func: cluster 'cluster' doesn't exist")
+}
+
+func TestTestNamespace_withoutNamespace(t *testing.T) {
+	// without a configured namespace the cluster must not be contacted
+	_, err := CreateTestNamespace(context.Background(), envconf.New())
+	require.NoError(t, err)
+	_, err = DeleteTestNamespace(context.Background(), envconf.New())
+	require.NoError(t, err)
+}
@@ -0,0 +1,36 @@
+//go:build upgrade
+
+package upgrade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crossplane-contrib/xp-testing/pkg/setup"
+	"github.com/crossplane-contrib/xp-testing/pkg/upgrade"
+	"github.com/crossplane-contrib/xp-testing/pkg/xpenvfuncs"
+)
+
+const fromCrossplane = "2.0.0"
+const toCrossplane = "2.1.0"
+
+// TestUpgradeCrossplaneFeature demonstrates usage of the crossplane UpgradeFeatureBuilder
+func TestUpgradeCrossplaneFeature(t *testing.T) {
+	upgradeTest := upgrade.CrossplaneUpgradeTest{
+		ClusterName:    kindClusterName,
+		FromCrossplane: setup.CrossplaneSetup{Version: fromCrossplane},
+		ToCrossplane:   setup.CrossplaneSetup{Version: toCrossplane},
+		Providers: []xpenvfuncs.InstallCrossplaneProviderOptions{
+			{Name: "provider-nop", Package: fromPackage},
+		},
+		ResourceDirectories: []string{
+			"../e2e/crs/Nop",
+		},
+		RestoreCrossplane: &crossplaneSetup,
+	}
+
+	// the feature installs crossplane fromCrossplane via helm upgrade --install, so it doesn't depend on the version of the setup,
+	// and restores the crossplane setup of the suite at teardown, as the cluster is shared with the provider upgrade tests
+	upgradeFeature := upgradeTest.UpgradeFeatureBuilder("upgrade crossplane from "+fromCrossplane+" to "+toCrossplane, time.Minute*3)
+	testenv.Test(t, upgradeFeature.Feature())
+}
@@ -23,6 +23,9 @@ import (
 var testenv env.Environment
 var kindClusterName string
 
+// crossplaneSetup is the crossplane setup of the suite, upgrade tests restore it at teardown
+var crossplaneSetup setup.CrossplaneSetup
+
 const fromPackage = "xpkg.upbound.io/crossplane-contrib/provider-nop:v0.2.0"
 const toPackage = "xpkg.crossplane.io/crossplane-contrib/provider-nop:v0.4.0"
 
@@ -46,6 +49,7 @@ func TestMain(m *testing.M) {
 		ProviderName: "provider-nop",
 		Images:       imgs,
 	}
+	crossplaneSetup = clusterSetup.CrossplaneSetup
 	clusterSetup.PostCreate(func(clusterName string) env.Func {
 		kindClusterName = clusterName
 		return func(ctx context.Context, config *envconf.Config) (context.Context, error) {